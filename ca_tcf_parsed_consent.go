@@ -0,0 +1,210 @@
+package iabconsent
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CaTcfSID is the GPP Section ID of the Canadian TCF (tcfcav1) section.
+const CaTcfSID = 5
+
+// CaTcfParsedConsent represents data extracted from a Canadian TCF v1 (tcfcav1) GPP section.
+// Unlike the EU TCF, the legal bases are express and implied consent, rather than consent and
+// legitimate interest.
+type CaTcfParsedConsent struct {
+	// The version of this section specification used to encode the string.
+	Version int
+	// Epoch deciseconds when this TC String was first created (should not be changed
+	// unless a new TCString is created from scratch).
+	Created time.Time
+	// Epoch deciseconds when TC String was last updated (Must be updated any time a
+	// value is changed).
+	LastUpdated time.Time
+	// Consent Management Platform ID that last updated the TC String.
+	CMPID int
+	// Consent Management Platform version of the CMP that last updated this TC String.
+	CMPVersion int
+	// CMP Screen number at which consent was given for a user with the CMP that last
+	// updated this TC String.
+	ConsentScreen int
+	// Two-letter ISO 639-1 language code in which the CMP UI was presented.
+	ConsentLanguage string
+	// Number corresponds to GVL vendorListVersion.
+	VendorListVersion int
+	// Version of policy used within GVL.
+	TCFPolicyVersion int
+	// Setting this to true means that a publisher-run CMP – that is still IAB Canada
+	// registered – is using customized Stack descriptions and not the standard stack
+	// descriptions defined in the Policies.
+	UseNonStandardStacks bool
+	// The user’s express consent value for each Special Feature, keyed by Special Feature number.
+	SpecialFeaturesExpressConsent map[int]bool
+	// The user’s express consent value for each Purpose, keyed by Purpose number.
+	PurposesExpressConsent map[int]bool
+	// The user’s implied consent value for each Purpose, keyed by Purpose number.
+	PurposesImpliedConsent map[int]bool
+	// The Vendors the user has given express consent to, keyed by Vendor ID.
+	VendorExpressConsent map[int]bool
+	// The Vendors the user has given implied consent to, keyed by Vendor ID.
+	VendorImpliedConsent map[int]bool
+
+	// The following fields are from the optional Publisher Purposes segment.
+
+	// The user’s express consent value for each Purpose established by the publisher.
+	PubPurposesExpressConsent map[int]bool
+	// The user’s implied consent value for each Purpose established by the publisher.
+	PubPurposesImpliedConsent map[int]bool
+	// The number of Custom Purposes.
+	NumCustomPurposes int
+	// The user’s express consent value for each Custom Purpose established by the publisher.
+	CustomPurposesExpressConsent map[int]bool
+	// The user’s implied consent value for each Custom Purpose established by the publisher.
+	CustomPurposesImpliedConsent map[int]bool
+}
+
+// PurposeAllowed returns true if the user has given express or implied consent to
+// purpose number ps.
+func (p *CaTcfParsedConsent) PurposeAllowed(ps int) bool {
+	return p.PurposesExpressConsent[ps] || p.PurposesImpliedConsent[ps]
+}
+
+// VendorAllowed returns true if the user has given express or implied consent to
+// VendorID |v|.
+func (p *CaTcfParsedConsent) VendorAllowed(v int) bool {
+	return p.VendorExpressConsent[v] || p.VendorImpliedConsent[v]
+}
+
+// SpecialFeatureOptIn returns true if the user has given express consent to Special
+// Feature |sf|. Special Features always require express consent.
+func (p *CaTcfParsedConsent) SpecialFeatureOptIn(sf SpecialFeature) bool {
+	return p.SpecialFeaturesExpressConsent[int(sf)]
+}
+
+// SuitableToProcess returns true if VendorID |v| may process every purpose number in ps.
+// Each purpose must be consented to on the same legal basis as the vendor, either express
+// or implied consent.
+func (p *CaTcfParsedConsent) SuitableToProcess(ps []int, v int) bool {
+	for _, rp := range ps {
+		var express = p.PurposesExpressConsent[rp] && p.VendorExpressConsent[v]
+		var implied = p.PurposesImpliedConsent[rp] && p.VendorImpliedConsent[v]
+		if !express && !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// TcfCaV1 is the GppSectionParser for the Canadian TCF v1 section.
+type TcfCaV1 struct {
+	GppSection
+}
+
+// ParseConsent parses the Canadian TCF v1 section into a CaTcfParsedConsent.
+func (t *TcfCaV1) ParseConsent() (GppParsedConsent, error) {
+	return ParseCaTcf(t.sectionValue)
+}
+
+// ParseCaTcf takes a base64 Raw URL Encoded string which represents a Canadian TCF v1
+// (tcfcav1) GPP section, and returns a CaTcfParsedConsent with its fields populated with
+// the values stored in the string. Any core segment fields following VendorImpliedConsent,
+// such as publisher restrictions, are not parsed.
+func ParseCaTcf(s string) (*CaTcfParsedConsent, error) {
+	var segments = strings.Split(s, ".")
+
+	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "parse tcfcav1 consent string")
+	}
+
+	var r = NewConsentReader(b)
+
+	// This block of code directly describes the format of the payload.
+	// The spec for the consent string can be found here:
+	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/blob/main/Sections/Canada/TCF%20Canada%20v1.md
+	var p = &CaTcfParsedConsent{}
+	p.Version, _ = r.ReadInt(6)
+	if p.Version != 1 {
+		return nil, ErrUnsupportedVersion{SectionID: CaTcfSID, Version: p.Version}
+	}
+	p.Created, _ = r.ReadTime()
+	p.LastUpdated, _ = r.ReadTime()
+	p.CMPID, _ = r.ReadInt(12)
+	p.CMPVersion, _ = r.ReadInt(12)
+	p.ConsentScreen, _ = r.ReadInt(6)
+	p.ConsentLanguage, _ = r.ReadString(2)
+	p.VendorListVersion, _ = r.ReadInt(12)
+	p.TCFPolicyVersion, _ = r.ReadInt(6)
+	p.UseNonStandardStacks, _ = r.ReadBool()
+	p.SpecialFeaturesExpressConsent, _ = r.ReadBitField(12)
+	p.PurposesExpressConsent, _ = r.ReadBitField(24)
+	p.PurposesImpliedConsent, _ = r.ReadBitField(24)
+	if p.VendorExpressConsent, err = r.ReadOptimizedFibonacciRange(); err != nil {
+		return nil, errors.Wrap(err, "parse tcfcav1 consent string")
+	}
+	if p.VendorImpliedConsent, err = r.ReadOptimizedFibonacciRange(); err != nil {
+		return nil, errors.Wrap(err, "parse tcfcav1 consent string")
+	}
+
+	// Parse remaining segments if they exist. Only the Publisher Purposes segment is
+	// supported, and other segments are skipped.
+	for i, segment := range segments[1:] {
+		b, err = base64.RawURLEncoding.DecodeString(segment)
+		if err != nil {
+			return nil, errors.Wrap(err, "parsing segment "+strconv.Itoa(i+1))
+		}
+		// A single byte is too short for any segment, the shortest of which is an empty
+		// Disclosed Vendors segment of 20 bits, but is the length of a GPC subsection.
+		if len(b) == 1 && GppSubSectionTypes(b[0]>>6) == SubSectGpc {
+			return nil, ErrUnexpectedSubsection
+		}
+		r = NewConsentReader(b)
+		var segmentType, _ = r.ReadInt(3)
+		if segmentType != int(PublisherTC) {
+			continue
+		}
+		p.PubPurposesExpressConsent, _ = r.ReadBitField(24)
+		p.PubPurposesImpliedConsent, _ = r.ReadBitField(24)
+		p.NumCustomPurposes, _ = r.ReadInt(6)
+		p.CustomPurposesExpressConsent, _ = r.ReadBitField(uint(p.NumCustomPurposes))
+		p.CustomPurposesImpliedConsent, _ = r.ReadBitField(uint(p.NumCustomPurposes))
+		if r.Err != nil {
+			return nil, errors.Wrap(r.Err, "parsing segment "+strconv.Itoa(i+1))
+		}
+	}
+
+	return p, nil
+}
+
+// ReadOptimizedFibonacciRange reads a GPP OptimizedRange of IDs, which is the maximum ID as
+// an int(16), followed by a Boolean representing whether the IDs are encoded as a Fibonacci
+// range (1/true) or a bit field (0/false) of the maximum ID length. ErrVendorRangeOutOfBounds is
+// returned if the range contains IDs greater than the maximum ID.
+func (r *ConsentReader) ReadOptimizedFibonacciRange() (map[int]bool, error) {
+	var maxID, err = r.ReadInt(16)
+	if err != nil {
+		return nil, errors.WithMessage(err, "optimized range max id")
+	}
+	var isRange bool
+	if isRange, err = r.ReadBool(); err != nil {
+		return nil, errors.WithMessage(err, "optimized range encoding")
+	}
+	if !isRange {
+		return r.ReadBitField(uint(maxID))
+	}
+	var ids []int
+	if ids, err = r.ReadFibonacciRange(); err != nil {
+		return nil, err
+	}
+	var m = make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if id > maxID {
+			return nil, ErrVendorRangeOutOfBounds
+		}
+		m[id] = true
+	}
+	return m, nil
+}
@@ -0,0 +1,152 @@
+package iabconsent_test
+
+import (
+	"time"
+
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type CaTcfSuite struct{}
+
+var _ = check.Suite(&CaTcfSuite{})
+
+var caTcfTestTime = time.Date(2023, 5, 6, 23, 30, 0, 0, time.UTC)
+
+var caTcfConsentFixtures = map[string]*iabconsent.CaTcfParsedConsent{
+	// Core segment only, with vendor express consents as a bit field, and vendor implied
+	// consents as a Fibonacci range.
+	"BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA": {
+		Version:                       1,
+		Created:                       caTcfTestTime,
+		LastUpdated:                   caTcfTestTime,
+		CMPID:                         31,
+		CMPVersion:                    2,
+		ConsentScreen:                 1,
+		ConsentLanguage:               "FR",
+		VendorListVersion:             178,
+		TCFPolicyVersion:              2,
+		UseNonStandardStacks:          false,
+		SpecialFeaturesExpressConsent: map[int]bool{1: true},
+		PurposesExpressConsent:        map[int]bool{1: true, 2: true, 3: true},
+		PurposesImpliedConsent:        map[int]bool{2: true, 7: true, 10: true},
+		VendorExpressConsent:          map[int]bool{2: true, 6: true, 8: true},
+		VendorImpliedConsent:          map[int]bool{12: true, 13: true, 14: true, 15: true, 40: true},
+	},
+	// Core segment, and Publisher Purposes segment.
+	"BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA.eAAAAAgAAUg": {
+		Version:                       1,
+		Created:                       caTcfTestTime,
+		LastUpdated:                   caTcfTestTime,
+		CMPID:                         31,
+		CMPVersion:                    2,
+		ConsentScreen:                 1,
+		ConsentLanguage:               "FR",
+		VendorListVersion:             178,
+		TCFPolicyVersion:              2,
+		UseNonStandardStacks:          false,
+		SpecialFeaturesExpressConsent: map[int]bool{1: true},
+		PurposesExpressConsent:        map[int]bool{1: true, 2: true, 3: true},
+		PurposesImpliedConsent:        map[int]bool{2: true, 7: true, 10: true},
+		VendorExpressConsent:          map[int]bool{2: true, 6: true, 8: true},
+		VendorImpliedConsent:          map[int]bool{12: true, 13: true, 14: true, 15: true, 40: true},
+		PubPurposesExpressConsent:     map[int]bool{1: true, 2: true},
+		PubPurposesImpliedConsent:     map[int]bool{10: true},
+		NumCustomPurposes:             2,
+		CustomPurposesExpressConsent:  map[int]bool{1: true},
+		CustomPurposesImpliedConsent:  map[int]bool{2: true},
+	},
+}
+
+func (s *CaTcfSuite) TestParseCaTcf(c *check.C) {
+	for k, v := range caTcfConsentFixtures {
+		c.Log(k)
+
+		var p, err = iabconsent.ParseCaTcf(k)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.DeepEquals, v)
+	}
+}
+
+func (s *CaTcfSuite) TestParseCaTcfError(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		consent  string
+		expected string
+	}{
+		{
+			desc:     "Unsupported version.",
+			consent:  "CPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA",
+			expected: "unsupported version: 2",
+		},
+		{
+			desc:     "Truncated core segment.",
+			consent:  "BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAA",
+			expected: "parse tcfcav1 consent string: .*",
+		},
+		{
+			desc:     "Invalid base64.",
+			consent:  "BPrZN2wPrZN2w*",
+			expected: "parse tcfcav1 consent string: .*",
+		},
+		{
+			desc:     "Implied consent vendors 12-13 with a max vendor ID of 10.",
+			consent:  "BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAKgA68",
+			expected: "parse tcfcav1 consent string: vendor range out of bounds",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.ParseCaTcf(tc.consent)
+		c.Check(p, check.IsNil)
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+
+	// The Canadian TCF section does not define a GPC subsection, which would otherwise be
+	// misread as the type of a TCF segment, e.g. 011 (Publisher TC) for "YA".
+	for _, gpc := range []string{".YA", ".QA", ".eAAAAAgAAUg.YA"} {
+		c.Log(gpc)
+
+		var p, err = iabconsent.ParseCaTcf("BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA" + gpc)
+		c.Check(p, check.IsNil)
+		c.Check(err, check.Equals, iabconsent.ErrUnexpectedSubsection)
+	}
+}
+
+func (s *CaTcfSuite) TestLookups(c *check.C) {
+	var p = caTcfConsentFixtures["BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA"]
+
+	c.Check(p.PurposeAllowed(1), check.Equals, true)
+	c.Check(p.PurposeAllowed(7), check.Equals, true)
+	c.Check(p.PurposeAllowed(4), check.Equals, false)
+
+	c.Check(p.VendorAllowed(6), check.Equals, true)
+	c.Check(p.VendorAllowed(40), check.Equals, true)
+	c.Check(p.VendorAllowed(7), check.Equals, false)
+
+	c.Check(p.SpecialFeatureOptIn(iabconsent.UsePreciseGeolocation), check.Equals, true)
+	c.Check(p.SpecialFeatureOptIn(iabconsent.ActivelyScanDevice), check.Equals, false)
+
+	// Express consent for both purpose and vendor.
+	c.Check(p.SuitableToProcess([]int{1, 3}, 2), check.Equals, true)
+	// Implied consent for both purpose and vendor.
+	c.Check(p.SuitableToProcess([]int{7, 10}, 12), check.Equals, true)
+	// Purpose 2 has both legal bases.
+	c.Check(p.SuitableToProcess([]int{2}, 2), check.Equals, true)
+	c.Check(p.SuitableToProcess([]int{2}, 12), check.Equals, true)
+	// Mismatched legal bases.
+	c.Check(p.SuitableToProcess([]int{1}, 12), check.Equals, false)
+	c.Check(p.SuitableToProcess([]int{7}, 2), check.Equals, false)
+}
+
+func (s *CaTcfSuite) TestParseGppConsent(c *check.C) {
+	var k = "BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA.eAAAAAgAAUg"
+
+	var p, err = iabconsent.ParseGppConsent("DBABjw~" + k + "~1YNN")
+	c.Check(err, check.IsNil)
+	c.Check(p, check.DeepEquals, map[int]iabconsent.GppParsedConsent{
+		iabconsent.CaTcfSID: caTcfConsentFixtures[k],
+	})
+}
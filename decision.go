@@ -0,0 +1,98 @@
+package iabconsent
+
+// personalizedAdsPurposes are the TCF v2 Purposes required to serve personalized ads:
+// 1 Store and/or access information on a device, 3 Create a personalised ads profile, and
+// 4 Select personalised ads.
+var personalizedAdsPurposes = []int{1, 3, 4}
+
+// CanServePersonalizedAds returns true if the consent signals of a request allow personalized
+// ads to be served, applying the rules of each framework that is present:
+//   - TCF v2 (the EU): every Purpose in personalizedAdsPurposes must have consent. Vendors are
+//     not checked; use CanVendorServePersonalizedAds to also check a vendor's signals. The TC
+//     string is ignored when the gdpr signal is "0", as GDPR does not apply.
+//   - MSPA sections of GPP (the US): the user must not have opted out of targeted advertising,
+//     and notice of the opt-out must have been provided, or be not applicable. California has
+//     no targeted advertising fields, so its sharing opt-out, which covers cross-context
+//     behavioral advertising, is used instead. GPC signaled as true is treated as an opt-out.
+//   - The legacy US Privacy String: the user must not have opted out of sale.
+//
+// Every framework that is present must allow personalized ads. Signals that are absent do not
+// restrict them, but the decision defaults to false for a nil ConsolidatedConsent.
+func CanServePersonalizedAds(c *ConsolidatedConsent) bool {
+	return canServePersonalizedAds(c, 0)
+}
+
+// CanVendorServePersonalizedAds is CanServePersonalizedAds for a single TCF vendor, which also
+// requires that vendor v is allowed to process data for every Purpose in
+// personalizedAdsPurposes, see V2ParsedConsent.VendorPurposeAllowed. Vendors have no signals
+// in the other frameworks, so their rules are unchanged.
+func CanVendorServePersonalizedAds(c *ConsolidatedConsent, v int) bool {
+	return canServePersonalizedAds(c, v)
+}
+
+// canServePersonalizedAds implements CanServePersonalizedAds, and also checks the signals of
+// TCF vendor v when it is not 0.
+func canServePersonalizedAds(c *ConsolidatedConsent, v int) bool {
+	if c == nil {
+		return false
+	}
+	if c.TcfV2 != nil && c.Gdpr != "0" && !tcfAllowsPersonalizedAds(c.TcfV2, v) {
+		return false
+	}
+	for sid, section := range c.GppSections {
+		if m, ok := section.(*MspaParsedConsent); ok && !mspaAllowsPersonalizedAds(sid, m) {
+			return false
+		}
+	}
+	if c.CCPA != nil && c.CCPA.OptOutSale == MspaYes {
+		return false
+	}
+	return true
+}
+
+// GeolocationAllowed returns true if the consent signals of a request allow the precise
+// geolocation of the user to be used, e.g. to target ads. In the EU, TCF v2 Special Feature 1,
+// UsePreciseGeolocation, must be opted in to, unless the gdpr signal is "0", as GDPR does not
+// apply. It is a separate decision from CanServePersonalizedAds, which does not require it;
+// combine the two to serve ads personalized by precise geolocation. Signals that are absent do
+// not restrict it, but the decision defaults to false for a nil ConsolidatedConsent.
+func (c *ConsolidatedConsent) GeolocationAllowed() bool {
+	if c == nil {
+		return false
+	}
+	if c.TcfV2 != nil && c.Gdpr != "0" && !c.TcfV2.SpecialFeatureOptIn(UsePreciseGeolocation) {
+		return false
+	}
+	return true
+}
+
+// tcfAllowsPersonalizedAds returns true if every Purpose in personalizedAdsPurposes has
+// consent, and vendor v, if not 0, is allowed to process data for them.
+func tcfAllowsPersonalizedAds(p *V2ParsedConsent, v int) bool {
+	if !p.EveryPurposeAllowed(personalizedAdsPurposes) {
+		return false
+	}
+	if v == 0 {
+		return true
+	}
+	for _, ps := range personalizedAdsPurposes {
+		if !p.VendorPurposeAllowed(ps, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// mspaAllowsPersonalizedAds returns true if the MSPA section of the given Section ID allows
+// targeted advertising. Invalid field values do not allow it.
+func mspaAllowsPersonalizedAds(sid int, m *MspaParsedConsent) bool {
+	if m.Gpc {
+		return false
+	}
+	var notice, optOut = m.TargetedAdvertisingOptOutNotice, m.TargetedAdvertisingOptOut
+	if sid == UsCaliforniaSID {
+		notice, optOut = m.SharingOptOutNotice, m.SharingOptOut
+	}
+	return (notice == NoticeNotApplicable || notice.IsProvided()) &&
+		(optOut == OptOutNotApplicable || optOut == NotOptedOut)
+}
@@ -0,0 +1,184 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type DecisionSuite struct{}
+
+var _ = check.Suite(&DecisionSuite{})
+
+func (s *DecisionSuite) TestCanServePersonalizedAds(c *check.C) {
+	var tcf = func(purposes ...int) *iabconsent.V2ParsedConsent {
+		var p = &iabconsent.V2ParsedConsent{
+			PurposesConsent:  make(map[int]bool),
+			ConsentedVendors: map[int]bool{2: true},
+		}
+		for _, ps := range purposes {
+			p.PurposesConsent[ps] = true
+		}
+		return p
+	}
+	var usnat = func(notice iabconsent.MspaNotice, optOut iabconsent.MspaOptout) map[int]iabconsent.GppParsedConsent {
+		return map[int]iabconsent.GppParsedConsent{iabconsent.UsNationalSID: &iabconsent.MspaParsedConsent{
+			TargetedAdvertisingOptOutNotice: notice,
+			TargetedAdvertisingOptOut:       optOut,
+		}}
+	}
+
+	var tcs = []struct {
+		desc     string
+		consent  *iabconsent.ConsolidatedConsent
+		expected bool
+	}{
+		{
+			desc:     "Nil consent.",
+			expected: false,
+		},
+		{
+			desc:     "No signals.",
+			consent:  &iabconsent.ConsolidatedConsent{},
+			expected: true,
+		},
+		{
+			desc:     "TCF with consent to Purposes 1, 3, and 4.",
+			consent:  &iabconsent.ConsolidatedConsent{TcfV2: tcf(1, 3, 4)},
+			expected: true,
+		},
+		{
+			desc:     "TCF without consent to Purpose 4.",
+			consent:  &iabconsent.ConsolidatedConsent{TcfV2: tcf(1, 2, 3)},
+			expected: false,
+		},
+		{
+			desc:     "MSPA did not opt out.",
+			consent:  &iabconsent.ConsolidatedConsent{GppSections: usnat(iabconsent.NoticeProvided, iabconsent.NotOptedOut)},
+			expected: true,
+		},
+		{
+			desc:     "MSPA not applicable.",
+			consent:  &iabconsent.ConsolidatedConsent{GppSections: usnat(iabconsent.NoticeNotApplicable, iabconsent.OptOutNotApplicable)},
+			expected: true,
+		},
+		{
+			desc:     "MSPA opted out.",
+			consent:  &iabconsent.ConsolidatedConsent{GppSections: usnat(iabconsent.NoticeProvided, iabconsent.OptedOut)},
+			expected: false,
+		},
+		{
+			desc:     "MSPA notice not provided.",
+			consent:  &iabconsent.ConsolidatedConsent{GppSections: usnat(iabconsent.NoticeNotProvided, iabconsent.OptOutNotApplicable)},
+			expected: false,
+		},
+		{
+			desc:     "MSPA invalid opt-out.",
+			consent:  &iabconsent.ConsolidatedConsent{GppSections: usnat(iabconsent.NoticeProvided, 3)},
+			expected: false,
+		},
+		{
+			desc: "MSPA with GPC.",
+			consent: &iabconsent.ConsolidatedConsent{GppSections: map[int]iabconsent.GppParsedConsent{
+				iabconsent.UsNationalSID: &iabconsent.MspaParsedConsent{Gpc: true, GpcPresent: true},
+			}},
+			expected: false,
+		},
+		{
+			desc: "California sharing opt-out.",
+			consent: &iabconsent.ConsolidatedConsent{GppSections: map[int]iabconsent.GppParsedConsent{
+				iabconsent.UsCaliforniaSID: &iabconsent.MspaParsedConsent{
+					SharingOptOutNotice: iabconsent.NoticeProvided,
+					SharingOptOut:       iabconsent.OptedOut,
+				},
+			}},
+			expected: false,
+		},
+		{
+			desc: "Empty GPP section.",
+			consent: &iabconsent.ConsolidatedConsent{GppSections: map[int]iabconsent.GppParsedConsent{
+				iabconsent.UsNationalSID: &iabconsent.EmptyGppSection{SectionID: iabconsent.UsNationalSID},
+			}},
+			expected: true,
+		},
+		{
+			desc:     "Legacy US Privacy opted out of sale.",
+			consent:  &iabconsent.ConsolidatedConsent{CCPA: &iabconsent.CCPAConsent{Version: 1, OptOutSale: iabconsent.MspaYes}},
+			expected: false,
+		},
+		{
+			desc:     "Legacy US Privacy did not opt out of sale.",
+			consent:  &iabconsent.ConsolidatedConsent{CCPA: &iabconsent.CCPAConsent{Version: 1, OptOutSale: iabconsent.MspaNo}},
+			expected: true,
+		},
+		{
+			desc: "Every framework must allow.",
+			consent: &iabconsent.ConsolidatedConsent{
+				TcfV2:       tcf(1, 3, 4),
+				GppSections: usnat(iabconsent.NoticeProvided, iabconsent.OptedOut),
+			},
+			expected: false,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		c.Check(iabconsent.CanServePersonalizedAds(tc.consent), check.Equals, tc.expected)
+	}
+
+	// Vendor 2 has consent, but vendor 3 does not.
+	var consent = &iabconsent.ConsolidatedConsent{TcfV2: tcf(1, 3, 4)}
+	c.Check(iabconsent.CanVendorServePersonalizedAds(consent, 2), check.Equals, true)
+	c.Check(iabconsent.CanVendorServePersonalizedAds(consent, 3), check.Equals, false)
+	c.Check(iabconsent.CanVendorServePersonalizedAds(&iabconsent.ConsolidatedConsent{TcfV2: tcf(1, 3)}, 2), check.Equals, false)
+	c.Check(iabconsent.CanVendorServePersonalizedAds(nil, 2), check.Equals, false)
+}
+
+func (s *DecisionSuite) TestGeolocationAllowed(c *check.C) {
+	var tcf = func(optIn bool) *iabconsent.V2ParsedConsent {
+		return &iabconsent.V2ParsedConsent{
+			PurposesConsent:      map[int]bool{1: true, 3: true, 4: true},
+			SpecialFeaturesOptIn: map[int]bool{int(iabconsent.UsePreciseGeolocation): optIn},
+		}
+	}
+
+	var tcs = []struct {
+		desc     string
+		consent  *iabconsent.ConsolidatedConsent
+		expected bool
+	}{
+		{
+			desc:     "Nil consent.",
+			expected: false,
+		},
+		{
+			desc:     "No signals.",
+			consent:  &iabconsent.ConsolidatedConsent{},
+			expected: true,
+		},
+		{
+			desc:     "TCF opted in to precise geolocation.",
+			consent:  &iabconsent.ConsolidatedConsent{Gdpr: "1", TcfV2: tcf(true)},
+			expected: true,
+		},
+		{
+			desc:     "TCF not opted in to precise geolocation.",
+			consent:  &iabconsent.ConsolidatedConsent{Gdpr: "1", TcfV2: tcf(false)},
+			expected: false,
+		},
+		{
+			desc:     "TCF not opted in to precise geolocation, but GDPR does not apply.",
+			consent:  &iabconsent.ConsolidatedConsent{Gdpr: "0", TcfV2: tcf(false)},
+			expected: true,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		c.Check(tc.consent.GeolocationAllowed(), check.Equals, tc.expected)
+	}
+
+	// Personalized ads do not require precise geolocation.
+	var consent = &iabconsent.ConsolidatedConsent{Gdpr: "1", TcfV2: tcf(false)}
+	c.Check(iabconsent.CanServePersonalizedAds(consent), check.Equals, true)
+}
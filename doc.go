@@ -9,6 +9,13 @@ https://github.com/InteractiveAdvertisingBureau/GDPR-Transparency-and-Consent-Fr
 The IAB Transparency and Consent String 2 Spec which can be found here:
 https://github.com/InteractiveAdvertisingBureau/GDPR-Transparency-and-Consent-Framework/blob/47b45ab362515310183bb3572a367b8391ef4613/TCFv2/IAB%20Tech%20Lab%20-%20Consent%20string%20and%20vendor%20list%20formats%20v2.md#about-the-transparency--consent-string-tc-string
 
+The parsing functions keep no state between calls, and only read the package-level tables of
+the specs, so they are safe to call from multiple goroutines. The methods that read parsed
+values do not modify them, including those of a MspaParsedConsent parsed with ParseMspaLazy or
+ParseMspaCompact, which read the Sensitive Data Processing fields from the undecoded core
+segment, so a parsed value can be shared by goroutines that only read it. Parsed values are
+not safe to modify concurrently, e.g. with the Set methods or ParseMspaReuse.
+
 Copyright (c) 2020 LiveRamp. All rights reserved.
 
 Written by Andy Day, Software Engineer @ LiveRamp for use in the LiveRamp Pixel Server.
@@ -0,0 +1,141 @@
+package iabconsent
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ConsentWriter is the inverse of ConsentReader, and writes Consent String-specific
+// values into a bit buffer. The first error encountered is kept in Err, which allows
+// callers to write a full payload and check for errors once, as is done when reading.
+type ConsentWriter struct {
+	buf  []byte
+	size uint
+	Err  error
+}
+
+// NewConsentWriter returns a new, empty ConsentWriter.
+func NewConsentWriter() *ConsentWriter {
+	return &ConsentWriter{}
+}
+
+// Size returns the number of bits written.
+func (w *ConsentWriter) Size() int {
+	return int(w.size)
+}
+
+// Bytes returns the written bits, with the final byte padded with 0s if needed.
+func (w *ConsentWriter) Bytes() []byte {
+	return w.buf
+}
+
+// WriteInt writes v as the next n bits.
+func (w *ConsentWriter) WriteInt(v int, n uint) error {
+	if v < 0 || (n < 64 && uint64(v) >= 1<<n) {
+		return w.fail(errors.New("write int: value " + fmt.Sprint(v) + " overflows " + fmt.Sprint(n) + " bits"))
+	}
+	for i := n; i > 0; i-- {
+		w.writeBit(uint64(v)>>(i-1)&1 == 1)
+	}
+	return nil
+}
+
+// WriteBool writes b as the next bit.
+func (w *ConsentWriter) WriteBool(b bool) error {
+	w.writeBit(b)
+	return nil
+}
+
+// WritePadding writes 0s until the writer contains n bits. It is used to pad a payload
+// to its valid string length.
+func (w *ConsentWriter) WritePadding(n int) error {
+	if w.Size() > n {
+		return w.fail(errors.New("write padding: " + fmt.Sprint(w.Size()) + " bits exceeds length " + fmt.Sprint(n)))
+	}
+	for w.Size() < n {
+		w.writeBit(false)
+	}
+	return nil
+}
+
+// WriteFibonacciInt writes v using Fibonacci Encoding, which is the inverse of
+// ReadFibonacciInt. Only positive values can be encoded.
+func (w *ConsentWriter) WriteFibonacciInt(v int) error {
+	if v < 1 {
+		return w.fail(errors.New("write fibonacci int: value " + fmt.Sprint(v) + " is not positive"))
+	}
+	// Find the Fibonacci values to sum, starting from the largest, as 1, 2, 3, 5, ... are at
+	// indexes 2, 3, 4, 5, ... of the sequence.
+	var fibs = []int{1, 2}
+	for fibs[len(fibs)-1] <= v-fibs[len(fibs)-2] {
+		fibs = append(fibs, fibs[len(fibs)-1]+fibs[len(fibs)-2])
+	}
+	var bits = make([]bool, len(fibs))
+	for i := len(fibs) - 1; i >= 0; i-- {
+		if fibs[i] <= v {
+			bits[i] = true
+			v -= fibs[i]
+		}
+	}
+	var last = len(bits) - 1
+	for !bits[last] {
+		last--
+	}
+	for _, b := range bits[:last+1] {
+		w.writeBit(b)
+	}
+	// The final 1 terminates the encoding.
+	w.writeBit(true)
+	return nil
+}
+
+// WriteFibonacciRange writes ids as a Fibonacci range, the inverse of ReadFibonacciRange.
+// The IDs must be positive and strictly increasing. Runs of consecutive IDs are written as a
+// group, and other IDs individually, as the offset from the previous ID.
+func (w *ConsentWriter) WriteFibonacciRange(ids []int) error {
+	type entry struct{ start, end int }
+	var entries []entry
+	for i, id := range ids {
+		if id < 1 || (i > 0 && id <= ids[i-1]) {
+			return w.fail(errors.New("write fibonacci range: ids must be positive and strictly increasing"))
+		}
+		if len(entries) > 0 && entries[len(entries)-1].end == id-1 {
+			entries[len(entries)-1].end = id
+		} else {
+			entries = append(entries, entry{start: id, end: id})
+		}
+	}
+
+	if err := w.WriteInt(len(entries), 12); err != nil {
+		return err
+	}
+	var lastSeen int
+	for _, e := range entries {
+		w.WriteBool(e.start != e.end)
+		w.WriteFibonacciInt(e.start - lastSeen)
+		if e.start != e.end {
+			w.WriteFibonacciInt(e.end - e.start)
+		}
+		lastSeen = e.end
+	}
+	return w.Err
+}
+
+func (w *ConsentWriter) writeBit(b bool) {
+	if w.size%8 == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if b {
+		w.buf[w.size/8] |= 0x80 >> (w.size % 8)
+	}
+	w.size++
+}
+
+// fail records err as the writer's first error, and returns it.
+func (w *ConsentWriter) fail(err error) error {
+	if w.Err == nil {
+		w.Err = err
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type EncodeSuite struct{}
+
+var _ = check.Suite(&EncodeSuite{})
+
+func (s *EncodeSuite) TestConsentWriter_WriteInt(c *check.C) {
+	var tests = []struct {
+		value int
+		n     uint
+	}{
+		{1, 1},
+		{0, 1},
+		{5, 3},
+		{2, 3},
+	}
+
+	var w = iabconsent.NewConsentWriter()
+	for _, t := range tests {
+		c.Check(w.WriteInt(t.value, t.n), check.IsNil)
+	}
+	c.Check(w.Size(), check.Equals, 8)
+	c.Check(w.Bytes(), check.DeepEquals, []byte{0xaa})
+}
+
+func (s *EncodeSuite) TestConsentWriter_WriteIntError(c *check.C) {
+	var w = iabconsent.NewConsentWriter()
+
+	c.Check(w.WriteInt(4, 2), check.ErrorMatches, "write int: value 4 overflows 2 bits")
+	c.Check(w.WriteInt(-1, 2), check.ErrorMatches, "write int: value -1 overflows 2 bits")
+	// Only the first error is kept.
+	c.Check(w.Err, check.ErrorMatches, "write int: value 4 overflows 2 bits")
+	c.Check(w.Size(), check.Equals, 0)
+}
+
+func (s *EncodeSuite) TestConsentWriter_WritePadding(c *check.C) {
+	var w = iabconsent.NewConsentWriter()
+	w.WriteBool(true)
+	w.WriteBool(true)
+
+	c.Check(w.WritePadding(12), check.IsNil)
+	c.Check(w.Size(), check.Equals, 12)
+	c.Check(w.Bytes(), check.DeepEquals, []byte{0b11000000, 0b00000000})
+	c.Check(w.WritePadding(8), check.ErrorMatches, "write padding: 12 bits exceeds length 8")
+}
+
+func (s *EncodeSuite) TestConsentWriter_ReadBack(c *check.C) {
+	var w = iabconsent.NewConsentWriter()
+	w.WriteInt(3, 6)
+	w.WriteMspaNotice(iabconsent.NoticeNotProvided)
+	w.WriteMspaBitfieldConsent(map[int]iabconsent.MspaConsent{1: iabconsent.Consent}, 3)
+	w.WriteMspaNaYesNo(iabconsent.MspaYes)
+	c.Check(w.Err, check.IsNil)
+
+	var r = iabconsent.NewConsentReader(w.Bytes())
+	var v, _ = r.ReadInt(6)
+	c.Check(v, check.Equals, 3)
+	var n, _ = r.ReadMspaNotice()
+	c.Check(n, check.Equals, iabconsent.NoticeNotProvided)
+	var bc, _ = r.ReadMspaBitfieldConsent(3)
+	c.Check(bc, check.DeepEquals, map[int]iabconsent.MspaConsent{
+		0: iabconsent.ConsentNotApplicable,
+		1: iabconsent.Consent,
+		2: iabconsent.ConsentNotApplicable,
+	})
+	var nyn, _ = r.ReadMspaNaYesNo()
+	c.Check(nyn, check.Equals, iabconsent.MspaYes)
+	c.Check(r.Err, check.IsNil)
+}
+
+func (s *EncodeSuite) TestConsentWriter_WriteFibonacci(c *check.C) {
+	var w = iabconsent.NewConsentWriter()
+	for v := 1; v <= 100; v++ {
+		c.Check(w.WriteFibonacciInt(v), check.IsNil)
+	}
+	var ranges = [][]int{nil, {7}, {2, 6, 7, 8, 9, 12}, {1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}}
+	for _, ids := range ranges {
+		c.Check(w.WriteFibonacciRange(ids), check.IsNil)
+	}
+
+	var r = iabconsent.NewConsentReader(w.Bytes())
+	for v := 1; v <= 100; v++ {
+		var got, err = r.ReadFibonacciInt()
+		c.Assert(err, check.IsNil)
+		c.Check(got, check.Equals, v)
+	}
+	for _, ids := range ranges {
+		var got, err = r.ReadFibonacciRange()
+		c.Assert(err, check.IsNil)
+		c.Check(got, check.DeepEquals, ids)
+	}
+
+	c.Check(iabconsent.NewConsentWriter().WriteFibonacciInt(0), check.ErrorMatches,
+		"write fibonacci int: value 0 is not positive")
+	c.Check(iabconsent.NewConsentWriter().WriteFibonacciRange([]int{3, 3}), check.ErrorMatches,
+		"write fibonacci range: ids must be positive and strictly increasing")
+}
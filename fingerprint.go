@@ -0,0 +1,155 @@
+package iabconsent
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"sort"
+)
+
+// Fingerprint returns a stable 64-bit hash of every notice, opt-out, consent, and MSPA field of
+// the parsed consent, e.g. for keying a cache of consent-based decisions. Consents that are
+// parsed from equivalent strings have the same fingerprint, including those parsed with
+// ParseMspaCompact or ParseMspaLazy. GpcPresent is not included, as it does not change any
+// decision. The fingerprint is not a cryptographic hash.
+func (m *MspaParsedConsent) Fingerprint() uint64 {
+	var f = newFingerprinter()
+	f.writeInt(m.Version)
+	for _, n := range []MspaNotice{m.SharingNotice, m.SaleOptOutNotice, m.SharingOptOutNotice,
+		m.TargetedAdvertisingOptOutNotice, m.SensitiveDataProcessingOptOutNotice, m.SensitiveDataLimitUseNotice} {
+		f.writeInt(int(n))
+	}
+	for _, o := range []MspaOptout{m.SaleOptOut, m.SharingOptOut, m.TargetedAdvertisingOptOut} {
+		f.writeInt(int(o))
+	}
+	var sd = m.SensitiveDataSlice()
+	f.writeInt(len(sd))
+	for _, c := range sd {
+		f.writeInt(int(c))
+	}
+	var optOuts = m.sensitiveDataOptOuts()
+	var sdo = make(map[int]int, len(optOuts))
+	for k, o := range optOuts {
+		sdo[k] = int(o)
+	}
+	f.writeIntMap(sdo)
+	var kc = make(map[int]int, len(m.KnownChildSensitiveDataConsents))
+	for k, c := range m.KnownChildSensitiveDataConsents {
+		kc[k] = int(c)
+	}
+	f.writeIntMap(kc)
+	f.writeInt(int(m.PersonalDataConsents))
+	for _, v := range []MspaNaYesNo{m.MspaCoveredTransaction, m.MspaOptOutOptionMode, m.MspaServiceProviderMode} {
+		f.writeInt(int(v))
+	}
+	f.writeBool(m.Gpc)
+	return f.h.Sum64()
+}
+
+// Fingerprint returns a stable 64-bit hash of the decision-relevant fields of the parsed
+// consent, e.g. for keying a cache of consent-based decisions. Metadata that does not change
+// any decision, such as the Created and LastUpdated times and the CMP that created the string,
+// is ignored. Vendors are hashed by whether they are allowed, so bit field and range encodings
+// of the same vendors have the same fingerprint. The OOB vendor segments are not included. The
+// fingerprint is not a cryptographic hash.
+func (p *V2ParsedConsent) Fingerprint() uint64 {
+	var f = newFingerprinter()
+	f.writeInt(p.Version)
+	f.writeInt(p.TCFPolicyVersion)
+	f.writeBool(p.IsServiceSpecific)
+	f.writeBitField(p.SpecialFeaturesOptIn)
+	f.writeBitField(p.PurposesConsent)
+	f.writeBitField(p.PurposesLITransparency)
+	f.writeBool(p.PurposeOneTreatment)
+	f.writeString(p.PublisherCC)
+
+	f.writeVendorSet(p.ConsentedVendorSet())
+	f.writeVendorSet(p.InterestsVendorSet())
+
+	f.writeInt(len(p.PubRestrictionEntries))
+	for _, pr := range p.PubRestrictionEntries {
+		f.writeInt(pr.PurposeID)
+		f.writeInt(int(pr.RestrictionType))
+		f.writeInt(len(pr.RestrictionsRange))
+		for _, re := range pr.RestrictionsRange {
+			f.writeInt(re.StartVendorID)
+			f.writeInt(re.EndVendorID)
+		}
+	}
+
+	f.writeBool(p.PublisherTCEntry != nil)
+	if p.PublisherTCEntry != nil {
+		f.writeBitField(p.PubPurposesConsent)
+		f.writeBitField(p.PubPurposesLITransparency)
+		f.writeInt(p.NumCustomPurposes)
+		f.writeBitField(p.CustomPurposesConsent)
+		f.writeBitField(p.CustomPurposesLITransparency)
+	}
+	return f.h.Sum64()
+}
+
+// fingerprinter writes values to a hash in a fixed-width, unambiguous encoding.
+type fingerprinter struct {
+	h   hash.Hash64
+	buf [8]byte
+}
+
+func newFingerprinter() *fingerprinter {
+	return &fingerprinter{h: fnv.New64a()}
+}
+
+func (f *fingerprinter) writeInt(v int) {
+	binary.LittleEndian.PutUint64(f.buf[:], uint64(v))
+	f.h.Write(f.buf[:])
+}
+
+func (f *fingerprinter) writeBool(b bool) {
+	if b {
+		f.writeInt(1)
+	} else {
+		f.writeInt(0)
+	}
+}
+
+func (f *fingerprinter) writeString(s string) {
+	f.writeInt(len(s))
+	f.h.Write([]byte(s))
+}
+
+// writeBitField writes the sorted keys of m that are true, so that false and missing keys
+// are equivalent.
+func (f *fingerprinter) writeBitField(m map[int]bool) {
+	var keys = make([]int, 0, len(m))
+	for k, v := range m {
+		if v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Ints(keys)
+	f.writeInt(len(keys))
+	for _, k := range keys {
+		f.writeInt(k)
+	}
+}
+
+// writeVendorSet writes the sorted Vendor IDs of s.
+func (f *fingerprinter) writeVendorSet(s VendorSet) {
+	f.writeInt(s.Len())
+	for _, id := range s.ids {
+		f.writeInt(id)
+	}
+}
+
+// writeIntMap writes the key value pairs of m, sorted by key.
+func (f *fingerprinter) writeIntMap(m map[int]int) {
+	var keys = make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	f.writeInt(len(keys))
+	for _, k := range keys {
+		f.writeInt(k)
+		f.writeInt(m[k])
+	}
+}
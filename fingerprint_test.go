@@ -0,0 +1,123 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type FingerprintSuite struct{}
+
+var _ = check.Suite(&FingerprintSuite{})
+
+func (s *FingerprintSuite) TestMspaFingerprint(c *check.C) {
+	// Fingerprints are stable across parses, and unique across fixtures with different values.
+	var seen = make(map[uint64]*iabconsent.MspaParsedConsent)
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, expected := range fixtures {
+			c.Log(sid, k)
+
+			var p, err = iabconsent.NewMspa(sid, k).ParseConsent()
+			c.Assert(err, check.IsNil)
+			var f = p.(*iabconsent.MspaParsedConsent).Fingerprint()
+			c.Check(f, check.Equals, expected.Fingerprint())
+
+			var compact *iabconsent.MspaParsedConsent
+			compact, err = iabconsent.ParseMspaCompact(sid, k)
+			c.Assert(err, check.IsNil)
+			c.Check(compact.Fingerprint(), check.Equals, f)
+
+			if other, ok := seen[f]; ok {
+				// Only a GPC subsection of false, and an absent one have the same fingerprint.
+				var e = *expected
+				e.GpcPresent = other.GpcPresent
+				c.Check(&e, check.DeepEquals, other)
+			}
+			seen[f] = expected
+		}
+	}
+
+	// Every opt-out and consent field is covered.
+	var base = mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]
+	var changes = []func(m *iabconsent.MspaParsedConsent){
+		func(m *iabconsent.MspaParsedConsent) { m.SaleOptOut = iabconsent.OptedOut },
+		func(m *iabconsent.MspaParsedConsent) { m.SharingOptOut = iabconsent.OptedOut },
+		func(m *iabconsent.MspaParsedConsent) { m.TargetedAdvertisingOptOut = iabconsent.OptedOut },
+		func(m *iabconsent.MspaParsedConsent) { m.SensitiveDataLimitUseNotice = iabconsent.NoticeNotProvided },
+		func(m *iabconsent.MspaParsedConsent) { m.PersonalDataConsents = iabconsent.Consent },
+		func(m *iabconsent.MspaParsedConsent) { m.MspaOptOutOptionMode = iabconsent.MspaYes },
+		func(m *iabconsent.MspaParsedConsent) { m.Gpc = true },
+		func(m *iabconsent.MspaParsedConsent) {
+			m.SensitiveDataProcessingConsents = map[int]iabconsent.MspaConsent{0: iabconsent.Consent}
+		},
+		func(m *iabconsent.MspaParsedConsent) {
+			m.KnownChildSensitiveDataConsents = map[int]iabconsent.MspaConsent{0: iabconsent.Consent}
+		},
+		func(m *iabconsent.MspaParsedConsent) {
+			m.SensitiveDataProcessingOptOuts = map[int]iabconsent.MspaOptout{0: iabconsent.OptedOut}
+		},
+	}
+	for i, change := range changes {
+		c.Log(i)
+
+		var m = *base
+		change(&m)
+		c.Check(m.Fingerprint(), check.Not(check.Equals), base.Fingerprint())
+	}
+}
+
+func (s *FingerprintSuite) TestV2Fingerprint(c *check.C) {
+	// Vendors 2 and 7 with range encoding, purposes 1-3, and created by CMP 10.
+	var base = "COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAgABAAHAAAAAA"
+	var tcs = []struct {
+		desc  string
+		tcf   string
+		equal bool
+	}{
+		{
+			desc:  "Different created and last updated times, and CMP.",
+			tcf:   "CO5rKAAO5rKAAALABBENAyCAAOAAAAAAAAAAAFQAgABAAHAAAAAA",
+			equal: true,
+		},
+		{
+			desc:  "Same vendors with bit field encoding.",
+			tcf:   "COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAEEIAAAAAA",
+			equal: true,
+		},
+		{
+			desc:  "Vendor 8 instead of vendor 7.",
+			tcf:   "COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAgABAAIAAAAAA",
+			equal: false,
+		},
+		{
+			desc:  "No consent to purpose 2.",
+			tcf:   "COEB7cAOEB7cAAKABBENAyCAAKAAAAAAAAAAAFQAgABAAHAAAAAA",
+			equal: false,
+		},
+	}
+
+	var p, err = iabconsent.ParseV2(base)
+	c.Assert(err, check.IsNil)
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var other *iabconsent.V2ParsedConsent
+		other, err = iabconsent.ParseV2(tc.tcf)
+		c.Assert(err, check.IsNil)
+		c.Check(other.Fingerprint() == p.Fingerprint(), check.Equals, tc.equal)
+	}
+
+	// Fingerprints are stable across parses, and unique across fixtures.
+	var seen = make(map[uint64]string)
+	for k := range v2ConsentFixtures {
+		c.Log(k)
+
+		p, err = iabconsent.ParseV2(k)
+		c.Assert(err, check.IsNil)
+		var f = p.Fingerprint()
+		c.Check(f, check.Equals, v2ConsentFixtures[k].Fingerprint())
+		var other, ok = seen[f]
+		c.Check(ok, check.Equals, false, check.Commentf("same fingerprint as %s", other))
+		seen[f] = k
+	}
+}
@@ -0,0 +1,42 @@
+package iabconsent
+
+import (
+	"strings"
+)
+
+// ConsentFormat is an enum type used for identifying the format of a consent string, when
+// strings of different formats are sent in the same field.
+type ConsentFormat int
+
+const (
+	// FormatUnknown represents a string of an unrecognized format.
+	FormatUnknown ConsentFormat = iota
+	// FormatCCPA represents a version 1 US Privacy String, e.g. "1YNN".
+	FormatCCPA
+	// FormatGPP represents a GPP string, or a GPP header without sections.
+	FormatGPP
+	// FormatTCFv2Raw represents a TCF v2 TC string, which is not wrapped in a GPP string.
+	FormatTCFv2Raw
+)
+
+// DetectFormat returns the format of s, so that it can be routed to the matching parser, e.g.
+// ParseCCPA, ParseGpp, or ParseV2, or FormatUnknown if it is not recognized. Only the structure
+// of s is checked: a US Privacy String is 4 characters, starting with its version, a GPP
+// string starts with a valid GPP header, and a TC string starts with its 6 bit version. Since
+// the string is not fully decoded, it may still fail to parse. Surrounding whitespace is
+// ignored.
+func DetectFormat(s string) ConsentFormat {
+	s = strings.TrimSpace(s)
+	if _, err := ParseCCPA(s); err == nil {
+		return FormatCCPA
+	}
+	// A GPP header and a TC string can not be confused, as the 6 bit GPP header Type (3) and
+	// TCF version (2) are both the first character of the string.
+	if _, err := ParseGppHeader(strings.SplitN(s, "~", 2)[0]); err == nil {
+		return FormatGPP
+	}
+	if !strings.Contains(s, "~") && TCFVersionFromTCString(s) == V2 {
+		return FormatTCFv2Raw
+	}
+	return FormatUnknown
+}
@@ -0,0 +1,39 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type FormatSuite struct{}
+
+var _ = check.Suite(&FormatSuite{})
+
+func (s *FormatSuite) TestDetectFormat(c *check.C) {
+	var tcs = []struct {
+		s   string
+		exp iabconsent.ConsentFormat
+	}{
+		{s: "1YNN", exp: iabconsent.FormatCCPA},
+		{s: "1---", exp: iabconsent.FormatCCPA},
+		{s: " 1yny ", exp: iabconsent.FormatCCPA},
+		{s: "DBABLA~BVVqAAEABCA.QA", exp: iabconsent.FormatGPP},
+		{s: "DBACLMA~BVVqAAEABCA~BVoYYYI", exp: iabconsent.FormatGPP},
+		{s: "DBABLA", exp: iabconsent.FormatGPP},
+		{s: "COvzTO5OvzTO5BRAAAENAPCoALIAADgAAAAAAewAwABAAlAB6ABBFAAA", exp: iabconsent.FormatTCFv2Raw},
+		{s: "COvzTO5OvzTO5BRAAAENAPCoALIAADgAAAAAAewAwABAAlAB6ABBFAAA.YAAAAAAAAAAA", exp: iabconsent.FormatTCFv2Raw},
+		// TCF v1 strings are not detected.
+		{s: "BONMj34ONMj34ABACDENALqAAAAAplY", exp: iabconsent.FormatUnknown},
+		{s: "2YNN", exp: iabconsent.FormatUnknown},
+		{s: "1YNX", exp: iabconsent.FormatUnknown},
+		{s: "", exp: iabconsent.FormatUnknown},
+		{s: "not a consent string", exp: iabconsent.FormatUnknown},
+	}
+
+	for _, tc := range tcs {
+		c.Log(tc)
+
+		c.Check(iabconsent.DetectFormat(tc.s), check.Equals, tc.exp)
+	}
+}
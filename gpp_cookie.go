@@ -0,0 +1,46 @@
+package iabconsent
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// gppCookieKeys are the keys that hold a GPP string in key-value cookie values, such as
+// `isGpcEnabled=0&datestamp=...&gpp=DBABL~...`. IABGPP_HDR_GppString is the name of the GPP
+// string field in the GPP CMP API, which some CMPs reuse when persisting it.
+var gppCookieKeys = []string{"gpp", "IABGPP_HDR_GppString"}
+
+// ParseFromCookie parses the GPP string stored in a CMP cookie value. The value may be either
+// a GPP string, or `&` separated key-value metadata containing the GPP string under one of
+// the known keys (e.g. OptanonConsent-style cookies). In both cases, the GPP string may be
+// URL-encoded and wrapped in quotes.
+func ParseFromCookie(value string, options ...*Options) (*GppResult, error) {
+	var s, err = gppFromCookie(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse gpp cookie")
+	}
+	return ParseGpp(s, options...)
+}
+
+// gppFromCookie extracts the GPP string from a cookie value.
+func gppFromCookie(value string) (string, error) {
+	value = strings.Trim(strings.TrimSpace(value), `"`)
+	// Base64 Raw URL Encoding does not use `=`, so its presence means the value is key-value metadata.
+	if !strings.Contains(value, "=") {
+		return url.PathUnescape(value)
+	}
+	for _, pair := range strings.Split(value, "&") {
+		var kv = strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		for _, k := range gppCookieKeys {
+			if strings.EqualFold(strings.TrimSpace(kv[0]), k) {
+				return url.QueryUnescape(kv[1])
+			}
+		}
+	}
+	return "", errors.New("no gpp value in cookie")
+}
@@ -0,0 +1,79 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+func (s *MspaSuite) TestParseFromCookie(c *check.C) {
+	var expected = &iabconsent.GppResult{
+		Header: &iabconsent.GppHeader{Type: 3, Version: 1, Sections: []int{7}},
+		Sections: map[int]iabconsent.GppParsedConsent{
+			iabconsent.UsNationalSID: mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.YA"],
+		},
+	}
+	var tcs = []struct {
+		desc   string
+		cookie string
+	}{
+		{
+			desc:   "GPP string.",
+			cookie: "DBABLA~BVVqAAEABCA.YA",
+		},
+		{
+			desc:   "Quoted GPP string.",
+			cookie: `"DBABLA~BVVqAAEABCA.YA"`,
+		},
+		{
+			desc:   "URL-encoded GPP string.",
+			cookie: "DBABLA%7EBVVqAAEABCA.YA",
+		},
+		{
+			desc:   "OptanonConsent-style key-value cookie.",
+			cookie: "isGpcEnabled=1&datestamp=Mon+Oct+16+2026&version=202301.1.0&gpp=DBABLA%7EBVVqAAEABCA.YA&isIABGlobal=false",
+		},
+		{
+			desc:   "GPP CMP API key.",
+			cookie: "IABGPP_HDR_GppString=DBABLA~BVVqAAEABCA.YA",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var r, err = iabconsent.ParseFromCookie(tc.cookie)
+		c.Check(err, check.IsNil)
+		c.Check(r, check.DeepEquals, expected)
+	}
+}
+
+func (s *MspaSuite) TestParseFromCookieError(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		cookie   string
+		expected string
+	}{
+		{
+			desc:     "Key-value cookie without GPP.",
+			cookie:   "isGpcEnabled=0&datestamp=Mon+Oct+16+2026",
+			expected: "parse gpp cookie: no gpp value in cookie",
+		},
+		{
+			desc:     "Bad URL encoding.",
+			cookie:   "DBABLA%7~BVVqAAEABCA.YA",
+			expected: `parse gpp cookie: invalid URL escape "%7~"`,
+		},
+		{
+			desc:     "Empty GPP value.",
+			cookie:   "gpp=&datestamp=Mon+Oct+16+2026",
+			expected: "empty gpp string",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var r, err = iabconsent.ParseFromCookie(tc.cookie)
+		c.Check(r, check.IsNil)
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+}
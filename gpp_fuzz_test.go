@@ -0,0 +1,59 @@
+//go:build go1.18
+// +build go1.18
+
+package iabconsent_test
+
+import (
+	"testing"
+
+	"github.com/openx/iabconsent"
+)
+
+// FuzzParseGpp checks that parsing arbitrary input returns an error rather than panicking.
+// The seed corpus is made up of the GPP, MSPA, Canadian TCF and TCF v2 fixtures, and can be extended with:
+//
+//	go test -run '^$' -fuzz FuzzParseGpp
+func FuzzParseGpp(f *testing.F) {
+	for g := range gppParsedConsentFixtures {
+		f.Add(g)
+	}
+	for _, fixtures := range mspaConsentFixtures {
+		for m := range fixtures {
+			f.Add(m)
+		}
+	}
+	for ca := range caTcfConsentFixtures {
+		f.Add("DBABjw~" + ca + "~1YNN")
+	}
+	for v2 := range v2ConsentFixtures {
+		f.Add("DBABMA~" + v2)
+		f.Add(v2)
+	}
+	f.Add("DBABzw~~BVVqAAEABCA")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		iabconsent.IsValidGpp(s)
+		if g, err := iabconsent.ParseGppHeader(s); err == nil {
+			// Section IDs are offsets from the previous ID, so they can not be duplicated.
+			for i := 1; i < len(g.Sections); i++ {
+				if g.Sections[i] <= g.Sections[i-1] {
+					t.Fatalf("section ids are not strictly increasing: %v", g.Sections)
+				}
+			}
+		}
+		iabconsent.ParseGppConsent(s)
+		iabconsent.ParseGppURLEncoded(s)
+		iabconsent.ParseFromCookie(s)
+		iabconsent.ParseGpp(s)
+		iabconsent.ParseOpenRTBRegs(s, nil, s)
+		iabconsent.ParseCaTcf(s)
+		iabconsent.ParseCCPA(s)
+		iabconsent.ParseV2(s)
+		iabconsent.Parse(s)
+		for sid := range mspaConsentFixtures {
+			iabconsent.ParseMspaReuse(sid, s, &iabconsent.MspaParsedConsent{})
+			iabconsent.ParseMspaCompact(sid, s)
+		}
+	})
+}
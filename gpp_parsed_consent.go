@@ -3,7 +3,10 @@ package iabconsent
 import (
 	"encoding/base64"
 	"fmt"
+	"net/url"
+	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/pkg/errors"
 )
@@ -27,14 +30,131 @@ const (
 	UsTennesseeSID
 )
 
+// gppSectionInfo is the registration of a GPP section.
+type gppSectionInfo struct {
+	// The API prefix of the section, as defined by the GPP Section Information table.
+	name string
+	// Returns the GppSectionParser of the section for NewMspa, or nil if the section is known,
+	// but not supported by NewMspa.
+	newParser func(g GppSection) GppSectionParser
+}
+
+// gppSections is the registry of the GPP sections, keyed by Section ID, which NewMspa,
+// SectionName, and SectionID are driven by. Supporting a new section requires an entry here,
+// and the parser of its consent. A MSPA section also requires the fields of each of its
+// versions in mspaFieldTable, which its parser reads. Sections are only registered by this
+// declaration, or by registerGppSection in tests.
+var gppSections = map[int]gppSectionInfo{
+	TcfEuV2SID:        {name: "tcfeuv2"},
+	CaTcfSID:          {name: "tcfcav1", newParser: func(g GppSection) GppSectionParser { return &TcfCaV1{g} }},
+	UsPrivacySID:      {name: "uspv1"},
+	UsNationalSID:     {name: "usnat", newParser: func(g GppSection) GppSectionParser { return &MspaUsNational{g} }},
+	UsCaliforniaSID:   {name: "usca", newParser: func(g GppSection) GppSectionParser { return &MspaUsCA{g} }},
+	UsVirginiaSID:     {name: "usva", newParser: func(g GppSection) GppSectionParser { return &MspaUsVA{g} }},
+	UsColoradoSID:     {name: "usco", newParser: func(g GppSection) GppSectionParser { return &MspaUsCO{g} }},
+	UsUtahSID:         {name: "usut", newParser: func(g GppSection) GppSectionParser { return &MspaUsUT{g} }},
+	UsConnecticutSID:  {name: "usct", newParser: func(g GppSection) GppSectionParser { return &MspaUsCT{g} }},
+	UsFloridaSID:      {name: "usfl", newParser: func(g GppSection) GppSectionParser { return &MspaUsFL{g} }},
+	UsMontanaSID:      {name: "usmt", newParser: func(g GppSection) GppSectionParser { return &MspaUsMT{g} }},
+	UsOregonSID:       {name: "usor", newParser: func(g GppSection) GppSectionParser { return &MspaUsOR{g} }},
+	UsTexasSID:        {name: "ustx", newParser: func(g GppSection) GppSectionParser { return &MspaUsTX{g} }},
+	UsDelawareSID:     {name: "usde", newParser: func(g GppSection) GppSectionParser { return &MspaUsDE{g} }},
+	UsIowaSID:         {name: "usia", newParser: func(g GppSection) GppSectionParser { return &MspaUsIA{g} }},
+	UsNebraskaSID:     {name: "usne", newParser: func(g GppSection) GppSectionParser { return &MspaUsNE{g} }},
+	UsNewHampshireSID: {name: "usnh", newParser: func(g GppSection) GppSectionParser { return &MspaUsNH{g} }},
+	UsNewJerseySID:    {name: "usnj", newParser: func(g GppSection) GppSectionParser { return &MspaUsNJ{g} }},
+	UsTennesseeSID:    {name: "ustn", newParser: func(g GppSection) GppSectionParser { return &MspaUsTN{g} }},
+}
+
+// registerGppSection registers a GPP section, and returns a function that restores the
+// previous registration of its Section ID, so that tests can register hypothetical sections.
+// It is not safe to call concurrently with parsing.
+func registerGppSection(sid int, info gppSectionInfo) (restore func()) {
+	var previous, ok = gppSections[sid]
+	gppSections[sid] = info
+	return func() {
+		if ok {
+			gppSections[sid] = previous
+		} else {
+			delete(gppSections, sid)
+		}
+	}
+}
+
+// SectionName returns the API prefix of the GPP section with the given Section ID, e.g. "usnat"
+// for UsNationalSID, or an empty string if the Section ID is not known.
+func SectionName(sid int) string {
+	return gppSections[sid].name
+}
+
+// SectionID returns the Section ID of the GPP section with the given API prefix, e.g.
+// UsNationalSID for "usnat". The name is case sensitive, as the prefixes are.
+func SectionID(name string) (int, bool) {
+	for sid, info := range gppSections {
+		if info.name == name {
+			return sid, true
+		}
+	}
+	return 0, false
+}
+
 // GppHeader is the first section of a GPP Consent String.
-// See ParseGppHeader for in-depth format.
+// See ParseGppHeader for in-depth format. Version 1 of the GPP spec defines no other header
+// fields, so every field that is encoded in a header is decoded.
 type GppHeader struct {
-	Type     int
-	Version  int
+	// The type of the segment, which is fixed to 3 for the GPP header.
+	Type int
+	// The version of the GPP spec used to encode the string. Only version 1 is supported.
+	Version int
+	// The Section IDs in the GPP string. Each ID is encoded as a positive offset from the
+	// previous one, so the IDs are strictly increasing, and a header can not list the same
+	// Section ID twice.
 	Sections []int
 }
 
+// String returns every field of the header, e.g. "gpp header type 3 version 1 sections [7 9]",
+// for use when logging strings sent by CMPs.
+func (g *GppHeader) String() string {
+	return fmt.Sprintf("gpp header type %d version %d sections %v", g.Type, g.Version, g.Sections)
+}
+
+// SectionIDs returns a copy of the Section IDs in the order they were encoded in the header,
+// which is also the order of the sections in the GPP string.
+func (g *GppHeader) SectionIDs() []int {
+	return append([]int(nil), g.Sections...)
+}
+
+// GppResult is a fully parsed GPP string, with its header and each successfully parsed section
+// keyed by Section ID.
+type GppResult struct {
+	Header   *GppHeader
+	Sections map[int]GppParsedConsent
+}
+
+// OrderedSections returns the successfully parsed sections in the order they appeared in the
+// GPP string, which the Sections map does not keep. Sections that failed to parse are skipped.
+func (r *GppResult) OrderedSections() []GppParsedConsent {
+	if r.Header == nil {
+		return nil
+	}
+	var sections = make([]GppParsedConsent, 0, len(r.Sections))
+	for _, sid := range r.Header.Sections {
+		if section, ok := r.Sections[sid]; ok {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// NationalScope returns true if the GPP string asserts that the MSPA applies nationally: the
+// US National section was parsed, and it declares a MSPA Covered Transaction. A US National
+// section that is not a Covered Transaction, e.g. MspaCoveredTransaction is No, still signals
+// the user's choices, but the MSPA, and so its national scope, does not apply.
+func (r *GppResult) NationalScope() bool {
+	var usnat, ok = r.Sections[UsNationalSID].(*MspaParsedConsent)
+	return ok && usnat.MspaMode() != MspaModeNotCovered
+}
+
 // GppParsedConsent is an empty interface since GPP will need to handle more consent structs
 // than just the Multi-state Privacy Agreement structs.
 type GppParsedConsent interface {
@@ -47,22 +167,175 @@ type GppSection struct {
 	sectionValue string
 }
 
+// DefaultMaxSections is the default maximum number of sections allowed in a GPP string.
+const DefaultMaxSections = 32
+
+var (
+	// ErrEmptyInput is returned when a GPP string is empty, or only contains whitespace,
+	// which is commonly used to signal that no consent was provided.
+	ErrEmptyInput = errors.New("empty gpp string")
+	// ErrTooManySections is returned when a GPP string contains more sections than allowed
+	// by Options.MaxSections.
+	ErrTooManySections = errors.New("too many gpp sections")
+	// ErrUnexpectedSubsection is returned when a section that does not define a GPC
+	// subsection, e.g. tcfcav1, is followed by one, which is a bug of the encoder.
+	ErrUnexpectedSubsection = errors.New("unexpected gpc subsection")
+)
+
+// ErrBadGppType is returned when the Type field of a GPP header is not one of the accepted
+// values, and holds the observed Type.
+type ErrBadGppType int
+
+func (e ErrBadGppType) Error() string {
+	return "wrong gpp header type " + fmt.Sprint(int(e))
+}
+
+// ErrUnsupportedVersion is returned when the Version of a section is not supported, and holds
+// the Section ID and the decoded Version, e.g. to count the versions of sections that are sent
+// before they are supported. The error is returned as is by the ParseConsent method of the
+// section's GppSectionParser.
+type ErrUnsupportedVersion struct {
+	SectionID int
+	Version   int
+}
+
+func (e ErrUnsupportedVersion) Error() string {
+	return "unsupported version: " + fmt.Sprint(e.Version)
+}
+
+// ErrSectionSegmentMissing is returned when a GPP header declares more sections than the
+// string has segments for, and holds the Section ID of the first section without a segment.
+// Segments are matched to the header's Section IDs by position, so the sections declared
+// last are the ones that are missing.
+type ErrSectionSegmentMissing struct {
+	SectionID int
+}
+
+func (e ErrSectionSegmentMissing) Error() string {
+	return "missing segment of gpp section " + fmt.Sprint(e.SectionID)
+}
+
+// gppHeaderTypes is the set of accepted GPP header Type values. Version 1 of the GPP spec fixes
+// the Type to 3, and any values introduced by later revisions should be added here.
+var gppHeaderTypes = map[int]bool{
+	3: true,
+}
+
+// Options customize how GPP strings, and TC strings passed to ParseV2WithOptions, are parsed.
+// Multiple Options may be passed to the parsing functions, and they are combined with any set
+// fields of later Options taking precedence over earlier ones.
 type Options struct {
 	GppSectionParser func(sid int, sectionString string) GppSectionParser
+	// AllowEmptyInput treats an empty GPP string as a valid "no signal" string with no
+	// sections, instead of returning ErrEmptyInput.
+	AllowEmptyInput bool
+	// MaxSections is the maximum number of sections a GPP string may contain before
+	// parsing is aborted with ErrTooManySections. Defaults to DefaultMaxSections, which is also
+	// used for values of 0 or less.
+	MaxSections int
+	// SplitConcatenatedSection is a workaround for CMPs that append a single section directly
+	// to the GPP header, without a `~` separator. If a GPP string has no separators, the end
+	// of the header is found by decoding it. Strings with separators are parsed as usual.
+	SplitConcatenatedSection bool
+	// DotSegmentSeparator is a workaround for CMPs that separate the GPP header from the first
+	// section with a `.`, the subsection separator, instead of a `~`. If the segment before the
+	// first `~` is not a valid header, but the text before its first `.` is, the `.` is read as
+	// the separator. Other sections must still be separated by `~`.
+	DotSegmentSeparator bool
+	// StandardBase64 is a workaround for CMPs that encode the segments of GPP strings with the
+	// standard base64 alphabet, which has `+` and `/` instead of `-` and `_`, and may pad them
+	// with `=`. The standard characters are replaced, and the padding removed, before decoding.
+	// By default, only base64 Raw URL Encoding is accepted.
+	StandardBase64 bool
+	// InvalidEnumPolicy is how parsed MSPA sections with an invalid Sensitive Data Processing or
+	// Known Child Sensitive Data value are handled. Defaults to InvalidEnumKeep.
+	InvalidEnumPolicy InvalidEnumPolicy
+	// AssumedMaxVendorID is used by ParseV2WithOptions to parse TC strings whose vendor
+	// sections were truncated, e.g. by ad servers that limit the length of the string. Vendors
+	// past it are not read, and a string that ends within its vendor sections is parsed with
+	// the vendors read up to the truncation. Lookups of vendors that were not read return
+	// false, and the Publisher Restrictions and segments following a truncation are lost.
+	// Defaults to 0, which reads every vendor and fails on truncated strings.
+	AssumedMaxVendorID int
+}
+
+// InvalidEnumPolicy is an enum type of the ways to handle the invalid value 3, which every 2 bit
+// MSPA field reserves, in the Sensitive Data Processing and Known Child Sensitive Data fields
+// of a parsed MSPA section.
+type InvalidEnumPolicy int
+
+const (
+	// InvalidEnumKeep keeps invalid values as decoded, e.g. InvalidConsentValue.
+	InvalidEnumKeep InvalidEnumPolicy = iota
+	// InvalidEnumError fails to parse the section, which is skipped by ParseGppConsent, and
+	// reported as a Warning by ParseGppWithWarnings.
+	InvalidEnumError
+	// InvalidEnumCoerce sets invalid values to Not Applicable. ParseGppWithWarnings reports a
+	// Warning for each coerced field.
+	InvalidEnumCoerce
+)
+
+// WithMaxSections returns Options that limit GPP strings to n sections. n must be positive, and
+// DefaultMaxSections is kept for n of 0 or less, the same as for the zero value of the option.
+func WithMaxSections(n int) *Options {
+	if n <= 0 {
+		return &Options{}
+	}
+	return &Options{MaxSections: n}
+}
+
+// WithInvalidEnumPolicy returns Options that handle invalid Sensitive Data Processing and Known
+// Child Sensitive Data values of MSPA sections with policy p.
+func WithInvalidEnumPolicy(p InvalidEnumPolicy) *Options {
+	return &Options{InvalidEnumPolicy: p}
+}
+
+// WithAssumedMaxVendorID returns Options that parse TC strings with vendor sections that were
+// truncated, reading no vendors past n.
+func WithAssumedMaxVendorID(n int) *Options {
+	return &Options{AssumedMaxVendorID: n}
 }
 
 func defaultOptions() *Options {
 	return &Options{
 		GppSectionParser: NewMspa,
+		MaxSections:      DefaultMaxSections,
 	}
 }
 
 func optionsOrDefault(options []*Options) *Options {
-	if options == nil || len(options) == 0 {
-		return defaultOptions()
+	var o = defaultOptions()
+	for _, opt := range options {
+		if opt == nil {
+			continue
+		}
+		if opt.GppSectionParser != nil {
+			o.GppSectionParser = opt.GppSectionParser
+		}
+		if opt.AllowEmptyInput {
+			o.AllowEmptyInput = true
+		}
+		if opt.MaxSections > 0 {
+			o.MaxSections = opt.MaxSections
+		}
+		if opt.SplitConcatenatedSection {
+			o.SplitConcatenatedSection = true
+		}
+		if opt.DotSegmentSeparator {
+			o.DotSegmentSeparator = true
+		}
+		if opt.StandardBase64 {
+			o.StandardBase64 = true
+		}
+		if opt.InvalidEnumPolicy != InvalidEnumKeep {
+			o.InvalidEnumPolicy = opt.InvalidEnumPolicy
+		}
+		if opt.AssumedMaxVendorID != 0 {
+			o.AssumedMaxVendorID = opt.AssumedMaxVendorID
+		}
 	}
 
-	return options[0]
+	return o
 }
 
 type GppSectionParser interface {
@@ -75,9 +348,37 @@ func (g *GppSection) GetSectionId() int {
 	return g.sectionId
 }
 
+// EmptyGppSection is the GppParsedConsent of a section that is listed in the GPP header, but
+// has a zero-length payload. This is distinct from a section that is not listed at all,
+// which is absent from the parsed sections.
+type EmptyGppSection struct {
+	SectionID int
+}
+
+// IsEmptySection returns true if the parsed consent is an EmptyGppSection.
+func IsEmptySection(c GppParsedConsent) bool {
+	var _, ok = c.(*EmptyGppSection)
+	return ok
+}
+
+// emptyGppSection is the GppSectionParser of a section with a zero-length payload.
+type emptyGppSection struct {
+	GppSection
+}
+
+func (e *emptyGppSection) ParseConsent() (GppParsedConsent, error) {
+	return &EmptyGppSection{SectionID: e.sectionId}, nil
+}
+
 type GppSubSection struct {
 	// Global Privacy Control (GPC) is signaled and set.
 	Gpc bool
+	// A GPC subsection is present, so a false Gpc was explicitly signaled, rather than absent.
+	GpcPresent bool
+	// The subsections with a type other than SubSectGpc, in the order they appear, which are
+	// kept as their base64 Raw URL Encoded string rather than parsed, e.g. those of types that
+	// a later version of the spec adds.
+	UnknownSubsections []string
 }
 
 type GppSubSectionTypes int
@@ -90,97 +391,415 @@ const (
 // ParseGppHeader parses the first (and required) part of any GPP Consent String.
 // It is used to read the Type, Version, and which sections are contained in the following string(s).
 // Format is:
-// Type	    Int(6)	Fixed to 3 as “GPP Header field”
+// Type	    Int(6)	Fixed to 3 as “GPP Header field”, otherwise ErrBadGppType is returned
 // Version	Int(6)	Version of the GPP spec (version 1, as of Jan. 2023)
 // Sections	Range(Fibonacci)	List of Section IDs that are contained in the GPP string.
 func ParseGppHeader(s string) (*GppHeader, error) {
 	// IAB's base64 conversion means a 6 bit grouped value can be converted to 8 bit bytes.
 	// Any leftover bits <8 would be skipped in normal base64 decoding.
 	// Therefore, pad with 6 '0's w/ `A` to ensure that all bits are decoded into bytes.
-	var b, err = base64.RawURLEncoding.DecodeString(s + "A")
+	// Every 4 characters decode to whole bytes, so those strings are not padded, as the
+	// padded string would be an invalid length.
+	var g, _, err = readGppHeader(s)
+	return g, err
+}
+
+// EncodeGppHeader encodes a GppHeader into a base64 Raw URL Encoded string, the inverse of
+// ParseGppHeader. The Section IDs must be positive and strictly increasing, and consecutive IDs
+// are encoded as a range. The header is padded with 0s to a whole number of bytes.
+func EncodeGppHeader(h *GppHeader) (string, error) {
+	if !gppHeaderTypes[h.Type] {
+		return "", ErrBadGppType(h.Type)
+	}
+	if h.Version != 1 {
+		return "", errors.New("unsupported gpp version " + fmt.Sprint(h.Version))
+	}
+	var w = NewConsentWriter()
+	w.WriteInt(h.Type, 6)
+	w.WriteInt(h.Version, 6)
+	w.WriteFibonacciRange(h.Sections)
+	if w.Err != nil {
+		return "", errors.Wrap(w.Err, "encode gpp header")
+	}
+	return base64.RawURLEncoding.EncodeToString(w.Bytes()), nil
+}
+
+// BuildGppString builds a GPP string from raw section strings, keyed by Section ID, e.g. to
+// forward a subset of the sections returned by SplitGppSegments. The Type and Version of the
+// header are used, or Type 3 and Version 1 if it is nil, and its Sections are ignored, as a new
+// header is encoded listing the Section IDs of sections. The sections are joined in the order of
+// their Section IDs, which is the only order a GPP header can list them in. Sections are not
+// decoded, and may be empty.
+func BuildGppString(header *GppHeader, sections map[int]string) (string, error) {
+	if len(sections) == 0 {
+		return "", errors.New("no gpp sections")
+	}
+	var h = &GppHeader{Type: 3, Version: 1}
+	if header != nil {
+		h.Type, h.Version = header.Type, header.Version
+	}
+	for sid, section := range sections {
+		if strings.Contains(section, "~") {
+			return "", errors.New("gpp section " + fmt.Sprint(sid) + " contains a section separator")
+		}
+		h.Sections = append(h.Sections, sid)
+	}
+	sort.Ints(h.Sections)
+
+	var s, err = EncodeGppHeader(h)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse gpp header consent string")
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString(s)
+	for _, sid := range h.Sections {
+		b.WriteByte('~')
+		b.WriteString(sections[sid])
+	}
+	return b.String(), nil
+}
+
+// readGppHeader parses a GPP header like ParseGppHeader, and also returns the number of bits
+// that were read, which is used to find the end of a header that is not followed by a `~`.
+func readGppHeader(s string) (*GppHeader, int, error) {
+	if len(s)%4 != 0 {
+		s += "A"
+	}
+	var b, err = base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "parse gpp header consent string")
 	}
 
 	var r = NewConsentReader(b)
 
 	var g = &GppHeader{}
 	g.Type, _ = r.ReadInt(6)
-	if g.Type != 3 {
-		return nil, errors.New("wrong gpp header type " + fmt.Sprint(g.Type))
+	if !gppHeaderTypes[g.Type] {
+		return nil, 0, ErrBadGppType(g.Type)
 	}
 	g.Version, _ = r.ReadInt(6)
 	if g.Version != 1 {
-		return nil, errors.New("unsupported gpp version " + fmt.Sprint(g.Version))
+		return nil, 0, errors.New("unsupported gpp version " + fmt.Sprint(g.Version))
+	}
+	if g.Sections, err = r.ReadFibonacciRange(); err != nil && r.Err == nil {
+		// Errors which are not from reading bits, such as out of bounds ranges.
+		return nil, 0, err
+	}
+	return g, int(r.Size()) - r.NumUnread(), r.Err
+}
+
+// splitConcatenatedGppSection splits a GPP string with a single section, which was appended
+// to the header without a `~` separator, into the header and the section. The header does
+// not encode its length, so its end is found from the number of bits read by decoding it.
+// Encoders pad the header to whole bytes, or only to whole base64 characters, so both
+// boundaries are tried, and the first one at which the section parses is used.
+func splitConcatenatedGppSection(s string, option *Options) ([]string, error) {
+	var core = strings.SplitN(s, ".", 2)[0]
+	var g, n, err = readGppHeader(core)
+	if err != nil {
+		return nil, errors.Wrap(err, "read gpp header")
+	}
+	if len(g.Sections) != 1 {
+		return nil, errors.New("can not split " + fmt.Sprint(len(g.Sections)) + " gpp sections without separators")
+	}
+	for _, end := range []int{(n + 7) / 8 * 8, n} {
+		// Each base64 character encodes 6 bits.
+		var i = (end + 5) / 6
+		if i >= len(core) {
+			continue
+		}
+		var section = option.GppSectionParser(g.Sections[0], s[i:])
+		if section == nil {
+			continue
+		}
+		if _, err = section.ParseConsent(); err == nil {
+			return []string{s[:i], s[i:]}, nil
+		}
+	}
+	return nil, errors.New("can not find the end of the gpp header")
+}
+
+// isGppHeaderOnly returns true if s is exactly the encoding of a GPP header, without any
+// section appended to it.
+func isGppHeaderOnly(s string) bool {
+	var g, err = ParseGppHeader(s)
+	if err != nil {
+		return false
 	}
-	g.Sections, _ = r.ReadFibonacciRange()
-	return g, r.Err
+	var encoded string
+	encoded, err = EncodeGppHeader(g)
+	return err == nil && encoded == s
+}
+
+// splitDotSeparatedGppHeader splits the first of the `~` separated segments of a GPP string
+// at its first `.`, if the header was separated from the first section with a `.`. A header
+// never contains a `.`, so the segment is only split if the text before it is a valid header.
+func splitDotSeparatedGppHeader(segments []string) []string {
+	var i = strings.IndexByte(segments[0], '.')
+	if i < 0 {
+		return segments
+	}
+	if _, err := ParseGppHeader(segments[0][:i]); err != nil {
+		return segments
+	}
+	return append([]string{segments[0][:i], segments[0][i+1:]}, segments[1:]...)
+}
+
+// CleanGppString removes the artifacts that are commonly left around a GPP string extracted
+// from another payload, such as JSON: surrounding whitespace, a leading UTF-8 byte order mark,
+// and a matched pair of surrounding double or single quotes. Whitespace within the string, e.g.
+// line breaks or tabs left by copying it through a spreadsheet or log, is also removed, as it
+// is never part of base64 Raw URL Encoded segments or their separators. ParseGppConsent and the
+// other GPP string parsing functions clean their input, so it is only needed to compare or
+// store strings.
+func CleanGppString(s string) string {
+	s = trimGppBOM(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		// The byte order mark may also be within the quotes.
+		s = trimGppBOM(s[1 : len(s)-1])
+	}
+	if strings.IndexFunc(s, unicode.IsSpace) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// standardBase64Replacer converts standard base64 to base64 Raw URL Encoding.
+var standardBase64Replacer = strings.NewReplacer("+", "-", "/", "_", "=", "")
+
+// cleanGppString cleans s with CleanGppString, and converts it from standard base64 if
+// Options.StandardBase64 is set.
+func cleanGppString(s string, option *Options) string {
+	s = CleanGppString(s)
+	if option.StandardBase64 {
+		s = standardBase64Replacer.Replace(s)
+	}
+	return s
+}
+
+// trimGppBOM removes surrounding whitespace and a leading UTF-8 byte order mark.
+func trimGppBOM(s string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "\uFEFF"))
 }
 
 // MapGppSectionToParser takes a base64 Raw URL Encoded string which represents a GPP v1 string
 // of the format {gpp header}~{section 1}[.{sub-section}][~{section n}]
 // and returns each pair of section value and parsing function that should be used.
 // The pairs are returned to allow more control over how parsing functions are applied.
+// The string is cleaned with CleanGppString, and an empty string returns ErrEmptyInput
+// unless Options.AllowEmptyInput is set.
 func MapGppSectionToParser(s string, options ...*Options) ([]GppSectionParser, error) {
-	option := optionsOrDefault(options)
+	var _, gppSections, err = mapGppSectionToParser(s, optionsOrDefault(options))
+	return gppSections, err
+}
+
+// mapGppSectionToParser is MapGppSectionToParser, which also returns the decoded header, so
+// that it is not decoded again. The header is nil for an empty string when
+// Options.AllowEmptyInput is set.
+func mapGppSectionToParser(s string, option *Options) (*GppHeader, []GppSectionParser, error) {
 	var gppHeader *GppHeader
 	var err error
+	s = cleanGppString(s, option)
+	if s == "" {
+		if option.AllowEmptyInput {
+			return nil, []GppSectionParser{}, nil
+		}
+		return nil, nil, ErrEmptyInput
+	}
+	// Check before splitting, to avoid any work on abusive strings.
+	if strings.Count(s, "~") > option.MaxSections {
+		return nil, nil, ErrTooManySections
+	}
 	// ~ separated fields. with the format {gpp header}~{section 1}[.{sub-section}][~{section n}]
 	var segments = strings.Split(s, "~")
-	if len(segments) < 2 {
-		return nil, errors.New("not enough gpp segments")
+	if option.DotSegmentSeparator {
+		segments = splitDotSeparatedGppHeader(segments)
+	}
+	// A string that is only a header is checked like any other, so that a header that declares
+	// zero sections parses, and the segments of the sections it declares are reported missing.
+	if len(segments) < 2 && !isGppHeaderOnly(segments[0]) {
+		if !option.SplitConcatenatedSection {
+			return nil, nil, errors.New("not enough gpp segments")
+		}
+		if segments, err = splitConcatenatedGppSection(s, option); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	gppHeader, err = ParseGppHeader(segments[0])
 	if err != nil {
-		return nil, errors.Wrap(err, "read gpp header")
-	} else if len(segments[1:]) != len(gppHeader.Sections) {
+		return nil, nil, errors.Wrap(err, "read gpp header")
+	} else if len(segments[1:]) < len(gppHeader.Sections) {
+		return nil, nil, ErrSectionSegmentMissing{SectionID: gppHeader.Sections[len(segments)-1]}
+	} else if len(segments[1:]) > len(gppHeader.Sections) {
 		// Return early if sections in header do not match sections passed.
-		return nil, errors.New("mismatch number of sections")
+		return nil, nil, errors.New("mismatch number of sections")
 	}
 	// Go through each section and add parsing function and section value to returned value.
 	var gppSections = make([]GppSectionParser, 0)
 	for i := 1; i < len(segments); i++ {
 		var gppSection GppSectionParser
+		if segments[i] == "" {
+			// Sections listed in the header with a zero-length payload are present, but empty,
+			// regardless of whether the Section ID is supported.
+			gppSections = append(gppSections, &emptyGppSection{GppSection{sectionId: gppHeader.Sections[i-1]}})
+			continue
+		}
 		gppSection = option.GppSectionParser(gppHeader.Sections[i-1], segments[i])
 		if gppSection != nil {
 			gppSections = append(gppSections, gppSection)
 		}
 	}
-	return gppSections, nil
+	return gppHeader, gppSections, nil
+}
+
+// SplitGppSegments splits a GPP string into its header and section segments, which are
+// returned as is, without decoding them, e.g. so that a proxy can forward a subset of the
+// sections. The string is cleaned with CleanGppString, and ErrEmptyInput is returned if it is
+// empty. Subsections are kept with their section. Use ParseGppHeader on the header to find the
+// Section ID of each section, which are in the same order.
+func SplitGppSegments(s string) (header string, sections []string, err error) {
+	if s = CleanGppString(s); s == "" {
+		return "", nil, ErrEmptyInput
+	}
+	var segments = strings.Split(s, "~")
+	if len(segments) < 2 {
+		return "", nil, errors.New("not enough gpp segments")
+	}
+	return segments[0], segments[1:], nil
+}
+
+// IsValidGpp is a cheap check of whether s is a well-formed GPP string. It verifies that the
+// header decodes, that the number of sections matches the header, and that each section only
+// contains base64 Raw URL Encoded characters and `.` subsection separators. Sections may be
+// empty (see EmptyGppSection), and a header that declares zero sections is valid on its own.
+// Sections are not decoded, so a true result does not guarantee that every section parses
+// successfully. Strings with more sections than Options.MaxSections are not valid, which is
+// the only option used.
+func IsValidGpp(s string, options ...*Options) bool {
+	s = CleanGppString(s)
+	var n = strings.Count(s, "~")
+	if n == 0 {
+		var gppHeader, err = ParseGppHeader(s)
+		return s != "" && err == nil && len(gppHeader.Sections) == 0
+	}
+	if n > optionsOrDefault(options).MaxSections {
+		return false
+	}
+	var i = strings.IndexByte(s, '~')
+	var gppHeader, err = ParseGppHeader(s[:i])
+	if err != nil || len(gppHeader.Sections) != n {
+		return false
+	}
+	// Each section must only contain valid characters.
+	for _, c := range s[i+1:] {
+		switch {
+		case c == '~', c == '.', c == '-', c == '_',
+			'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // ParseGppConsent takes a base64 Raw URL Encoded string which represents a GPP v1 string and
 // returns a map of Section ID to ParsedConsents with consent parsed via a consecutive parsing.
 func ParseGppConsent(s string, options ...*Options) (map[int]GppParsedConsent, error) {
-	var gppSections []GppSectionParser
-	var err error
-	gppSections, err = MapGppSectionToParser(s, optionsOrDefault(options))
+	var _, gppConsents, err = parseGppConsent(s, optionsOrDefault(options))
+	return gppConsents, err
+}
+
+// parseGppConsent is ParseGppConsent, which also returns the decoded header.
+func parseGppConsent(s string, option *Options) (*GppHeader, map[int]GppParsedConsent, error) {
+	var gppHeader, gppSections, err = mapGppSectionToParser(s, option)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	var policy = option.InvalidEnumPolicy
 	var gppConsents = make(map[int]GppParsedConsent, len(gppSections))
 	// Consecutively, go through each section and try to parse.
 	for _, gpp := range gppSections {
 		var consent GppParsedConsent
 		var consentErr error
 		consent, consentErr = gpp.ParseConsent()
+		if consentErr == nil {
+			consentErr = applyInvalidEnumPolicy(policy, consent)
+		}
 		if consentErr != nil {
 			// If an error, quietly do not add the consent value to map.
 		} else {
 			gppConsents[gpp.GetSectionId()] = consent
 		}
 	}
-	return gppConsents, nil
+	return gppHeader, gppConsents, nil
+}
+
+// ParseGppLenient is ParseGppConsent with Options.SplitConcatenatedSection set, as a workaround
+// for CMPs that append a single section directly to the GPP header without a `~` separator,
+// e.g. "DBABJgBVoYYQg" instead of "DBABJg~BVoYYQg". Well-formed strings parse the same as with
+// ParseGppConsent.
+func ParseGppLenient(s string, options ...*Options) (map[int]GppParsedConsent, error) {
+	return ParseGppConsent(s, append(options[:len(options):len(options)], &Options{SplitConcatenatedSection: true})...)
+}
+
+// ParseGpp parses a GPP string like ParseGppConsent, but also returns the decoded header, which
+// keeps the order of the sections. The Header is nil if the string is empty, and
+// Options.AllowEmptyInput is set.
+func ParseGpp(s string, options ...*Options) (*GppResult, error) {
+	var header, sections, err = parseGppConsent(s, optionsOrDefault(options))
+	if err != nil {
+		return nil, err
+	}
+	return &GppResult{Header: header, Sections: sections}, nil
+}
+
+// ParseGppURLEncoded is ParseGppConsent for GPP strings that may be percent-encoded, such as
+// values read from URL query parameters where the `~` delimiter is sent as `%7E`. Only the
+// percent-encoded bytes are decoded, so a `+` of a standard base64 string is kept as is.
+func ParseGppURLEncoded(s string, options ...*Options) (map[int]GppParsedConsent, error) {
+	var unescaped, err = url.PathUnescape(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "unescape gpp string")
+	}
+	return ParseGppConsent(unescaped, options...)
+}
+
+// applyInvalidEnumPolicy applies policy to the Sensitive Data Processing and Known Child
+// Sensitive Data values of c, if it is a MspaParsedConsent. An error listing the invalid fields
+// is returned for InvalidEnumError.
+func applyInvalidEnumPolicy(policy InvalidEnumPolicy, c GppParsedConsent) error {
+	var m, ok = c.(*MspaParsedConsent)
+	if !ok || policy == InvalidEnumKeep {
+		return nil
+	}
+	var invalid = m.invalidSensitiveDataFields(policy == InvalidEnumCoerce)
+	if policy == InvalidEnumError && len(invalid) > 0 {
+		return errors.New("invalid sensitive data value in " + strings.Join(invalid, ", "))
+	}
+	return nil
 }
 
 // ParseGppSubSections parses the subsections that may be appended to GPP sections after a `.`
 // Currently, GPC is the only subsection, so we only have a single Subsection parsing function.
 // In the future, Section IDs may need their own SubSection parser.
+// Each subsection starts with its 2 bit SubsectionType, and the GPC bool is only read from
+// subsections of type SubSectGpc. Subsections of any other type are stored in
+// UnknownSubsections.
 func ParseGppSubSections(subSections []string) (*GppSubSection, error) {
 	var gppSub = new(GppSubSection)
 	// There could be >1 subsection, but we will only return a single GppSubSection result.
 	for _, s := range subSections {
+		// Some encoders end the subsections with a bare type 0 (core) subsection, encoded as a
+		// single "A", which is not valid base64 on its own. It has no data, so it is skipped.
+		if s != "" && strings.Trim(s, "A") == "" {
+			continue
+		}
 		// Actual base64 encoded data, so no need to add extra `0`s.
 		var b, err = base64.RawURLEncoding.DecodeString(s)
 		if err != nil {
@@ -206,6 +825,9 @@ func ParseGppSubSections(subSections []string) (*GppSubSection, error) {
 			if gppSub.Gpc != true {
 				gppSub.Gpc = gppValue
 			}
+			gppSub.GpcPresent = true
+		default:
+			gppSub.UnknownSubsections = append(gppSub.UnknownSubsections, s)
 		}
 	}
 	return gppSub, nil
@@ -222,3 +844,12 @@ func ParseGpcSubsection(r *ConsentReader) (bool, error) {
 	}
 	return gppValue, err
 }
+
+// encodeGpcSubsection returns the base64 Raw URL Encoded GPC subsection, which is the
+// subsection type followed by the GPC bool.
+func encodeGpcSubsection(gpc bool) string {
+	var w = NewConsentWriter()
+	w.WriteInt(int(SubSectGpc), 2)
+	w.WriteBool(gpc)
+	return base64.RawURLEncoding.EncodeToString(w.Bytes())
+}
@@ -6,8 +6,10 @@ import (
 
 // Test fixtures can be created here: https://iabgpp.com/
 var gppParsedConsentFixtures = map[string]map[int]*iabconsent.MspaParsedConsent{
-	// Valid GPP w/ V1 US National MSPA, No Subsection (is the same as false GPC subsection).
-	"DBABLA~BVVqAAEABCA": {iabconsent.UsNationalSID: mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]},
+	// Valid GPP header that declares zero sections, with no section segments.
+	"DBAA": {},
+	// Valid GPP w/ V1 US National MSPA, No Subsection (is the same as false GPC subsection, besides GpcPresent).
+	"DBABLA~BVVqAAEABCA": {iabconsent.UsNationalSID: gpcAbsent(mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"])},
 	// Valid GPP w/ V1 US National MSPA, Subsection of GPC False.
 	"DBABLA~BVVqAAEABCA.QA": {iabconsent.UsNationalSID: mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]},
 	// Valid GPP w/ V1 US National MSPA, Subsection of GPC True.
@@ -21,30 +23,39 @@ var gppParsedConsentFixtures = map[string]map[int]*iabconsent.MspaParsedConsent{
 	// Valid GPP w/ US Colorado MSPA, Subsection of GPC False.
 	"DBABJg~BVoYYQg": {iabconsent.UsColoradoSID: mspaConsentFixtures[iabconsent.UsColoradoSID]["BVoYYQg"]},
 	// Valid GPP w/ US Utah MSPA, Subsection of GPC False.
-	"DBABFg~BVaGGGCA": {iabconsent.UsUtahSID: mspaConsentFixtures[iabconsent.UsUtahSID]["BVaGGGCA.QA"]},
+	"DBABFg~BVaGGGCA": {iabconsent.UsUtahSID: gpcAbsent(mspaConsentFixtures[iabconsent.UsUtahSID]["BVaGGGCA.QA"])},
 	// Valid GPP w/ US Connecticut MSPA, Subsection of GPC False.
 	"DBABVg~BVoYYYQg": {iabconsent.UsConnecticutSID: mspaConsentFixtures[iabconsent.UsConnecticutSID]["BVoYYYQg"]},
 	// Valid GPP w/ US National and Virginia MSPA, Subsection of GPC False.
 	"DBACLMA~BVVqAAEABCA~BVoYYYI": {
-		iabconsent.UsNationalSID: mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+		iabconsent.UsNationalSID: gpcAbsent(mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]),
 		iabconsent.UsVirginiaSID: mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"],
 	},
 	// Valid GPP w/ V1 US National, California MSPA, Virgina MSPA, Colorado MSPA, and Utah Subsection of GPC False.
 	"DBABrGA~BVVqAAEABCA~BVoYYZoI~BVoYYYI~BVoYYQg~BVaGGGCA~BVoYYYQg": {
-		iabconsent.UsNationalSID:    mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+		iabconsent.UsNationalSID:    gpcAbsent(mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]),
 		iabconsent.UsCaliforniaSID:  mspaConsentFixtures[iabconsent.UsCaliforniaSID]["BVoYYZoI"],
 		iabconsent.UsVirginiaSID:    mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"],
 		iabconsent.UsColoradoSID:    mspaConsentFixtures[iabconsent.UsColoradoSID]["BVoYYQg"],
-		iabconsent.UsUtahSID:        mspaConsentFixtures[iabconsent.UsUtahSID]["BVaGGGCA.QA"],
+		iabconsent.UsUtahSID:        gpcAbsent(mspaConsentFixtures[iabconsent.UsUtahSID]["BVaGGGCA.QA"]),
+		iabconsent.UsConnecticutSID: mspaConsentFixtures[iabconsent.UsConnecticutSID]["BVoYYYQg"],
+	},
+	// Same as above, but the header encodes the Section IDs as single IDs instead of a range.
+	"DBAGLbbY~BVVqAAEABCA~BVoYYZoI~BVoYYYI~BVoYYQg~BVaGGGCA~BVoYYYQg": {
+		iabconsent.UsNationalSID:    gpcAbsent(mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]),
+		iabconsent.UsCaliforniaSID:  mspaConsentFixtures[iabconsent.UsCaliforniaSID]["BVoYYZoI"],
+		iabconsent.UsVirginiaSID:    mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"],
+		iabconsent.UsColoradoSID:    mspaConsentFixtures[iabconsent.UsColoradoSID]["BVoYYQg"],
+		iabconsent.UsUtahSID:        gpcAbsent(mspaConsentFixtures[iabconsent.UsUtahSID]["BVaGGGCA.QA"]),
 		iabconsent.UsConnecticutSID: mspaConsentFixtures[iabconsent.UsConnecticutSID]["BVoYYYQg"],
 	},
 	// Valid GPP w/ V1 US National, California MSPA, Virginia MSPA, Colorado MSPA, Utah MSPA, Conneticut MSPA, Florida MSPA and Montana MSPA Subsection of GPC False.
 	"DBABrWA~BVVqAAEABCA~BVoYYZoI~BVoYYYI~BVoYYQg~BVaGGGCA~BVoYYYQg~Bqqqqqqo~Bqqqqqqo": {
-		iabconsent.UsNationalSID:    mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+		iabconsent.UsNationalSID:    gpcAbsent(mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]),
 		iabconsent.UsCaliforniaSID:  mspaConsentFixtures[iabconsent.UsCaliforniaSID]["BVoYYZoI"],
 		iabconsent.UsVirginiaSID:    mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"],
 		iabconsent.UsColoradoSID:    mspaConsentFixtures[iabconsent.UsColoradoSID]["BVoYYQg"],
-		iabconsent.UsUtahSID:        mspaConsentFixtures[iabconsent.UsUtahSID]["BVaGGGCA.QA"],
+		iabconsent.UsUtahSID:        gpcAbsent(mspaConsentFixtures[iabconsent.UsUtahSID]["BVaGGGCA.QA"]),
 		iabconsent.UsConnecticutSID: mspaConsentFixtures[iabconsent.UsConnecticutSID]["BVoYYYQg"],
 		iabconsent.UsFloridaSID:     mspaConsentFixtures[iabconsent.UsFloridaSID]["Bqqqqqqo"],
 		iabconsent.UsMontanaSID:     mspaConsentFixtures[iabconsent.UsMontanaSID]["Bqqqqqqo"],
@@ -75,3 +86,11 @@ var gppParsedConsentFixtures = map[string]map[int]*iabconsent.MspaParsedConsent{
 	// Valid GPP w/ US Tennessee MSPA, Subsection of GPC False.
 	"DBABQYA~BqqqqqqA": {iabconsent.UsTennesseeSID: mspaConsentFixtures[iabconsent.UsTennesseeSID]["BqqqqqqA"]},
 }
+
+// gpcAbsent returns a copy of a MSPA fixture with a GPC subsection of false, as it is parsed
+// when the GPC subsection is absent instead.
+func gpcAbsent(p *iabconsent.MspaParsedConsent) *iabconsent.MspaParsedConsent {
+	var c = *p
+	c.GpcPresent = false
+	return &c
+}
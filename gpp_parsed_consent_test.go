@@ -3,6 +3,7 @@ package iabconsent_test
 import (
 	"encoding/base64"
 	"strings"
+	"sync"
 
 	"github.com/go-check/check"
 	"github.com/pkg/errors"
@@ -61,6 +62,48 @@ func (s *GppParseSuite) TestParseGppHeader(c *check.C) {
 				Version:  1,
 				Sections: []int{6, 7}},
 		},
+		{
+			// Sections may be encoded as single IDs, or as groups of consecutive IDs (ranges),
+			// and both must decode to the same list of Section IDs.
+			description: "US MSPA Sections 7-12, encoded as single IDs",
+			header:      "DBAGLbbY",
+			expected: &iabconsent.GppHeader{
+				Type:     3,
+				Version:  1,
+				Sections: []int{7, 8, 9, 10, 11, 12}},
+		},
+		{
+			description: "US MSPA Sections 7-12, encoded as a range",
+			header:      "DBABrG",
+			expected: &iabconsent.GppHeader{
+				Type:     3,
+				Version:  1,
+				Sections: []int{7, 8, 9, 10, 11, 12}},
+		},
+		{
+			description: "Single IDs and ranges, encoded as single IDs",
+			header:      "DBAHNbats",
+			expected: &iabconsent.GppHeader{
+				Type:     3,
+				Version:  1,
+				Sections: []int{2, 6, 7, 8, 20, 21, 22}},
+		},
+		{
+			description: "Single IDs and ranges, encoded as a single ID and ranges",
+			header:      "DBADPb1s",
+			expected: &iabconsent.GppHeader{
+				Type:     3,
+				Version:  1,
+				Sections: []int{2, 6, 7, 8, 20, 21, 22}},
+		},
+		{
+			description: "Zero sections",
+			// Six bit groupings: 000011 000001 000000 000000
+			header: "DBAA",
+			expected: &iabconsent.GppHeader{
+				Type:    3,
+				Version: 1},
+		},
 	}
 
 	for _, tc := range tcs {
@@ -69,6 +112,20 @@ func (s *GppParseSuite) TestParseGppHeader(c *check.C) {
 		c.Check(err, check.IsNil)
 		c.Check(g, check.DeepEquals, tc.expected)
 	}
+
+	// The header is decoded from its bit fields, rather than matched by prefix. Headers with
+	// 64 or more entries do not start with "DBA", as the entry count overflows its first 6 bits.
+	var sections = make([]int, 64)
+	for i := range sections {
+		sections[i] = i + 1
+	}
+	var g, err = iabconsent.ParseGppHeader("DBBAbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	c.Check(err, check.IsNil)
+	c.Check(g, check.DeepEquals, &iabconsent.GppHeader{Type: 3, Version: 1, Sections: sections})
+
+	g, err = iabconsent.ParseGppHeader("DBACLMA")
+	c.Assert(err, check.IsNil)
+	c.Check(g.String(), check.Equals, "gpp header type 3 version 1 sections [7 9]")
 }
 
 func (s *GppParseSuite) TestParseGppHeaderError(c *check.C) {
@@ -100,6 +157,11 @@ func (s *GppParseSuite) TestParseGppHeaderError(c *check.C) {
 			// Six bit groupings: 000011 000010 000000 000010 001101 011000
 			header:   "DCACNY",
 			expected: errors.New("unsupported gpp version 2"),
+		},
+		{
+			description: "Section ID ranges are bounded, rather than expanding into unbounded IDs.",
+			header:      "DBRCyCQAcAAAISAAAAQRQAozYaABUgc",
+			expected:    errors.New("fibonacci range exceeds max id of 65535"),
 		}}
 
 	for _, tc := range tcs {
@@ -111,6 +173,37 @@ func (s *GppParseSuite) TestParseGppHeaderError(c *check.C) {
 	}
 }
 
+func (s *GppParseSuite) TestParseGppHeaderBadType(c *check.C) {
+	var tcs = []struct {
+		description string
+		header      string
+		expected    iabconsent.ErrBadGppType
+	}{
+		{
+			description: "Type 1.",
+			header:      "BBACNY",
+			expected:    1,
+		},
+		{
+			description: "Type 4.",
+			// Six bit groupings: 000100 000001 000000 000010 001101 011000
+			header:   "EBACNY",
+			expected: 4,
+		},
+	}
+
+	for _, tc := range tcs {
+		c.Log(tc.description)
+		var g, err = iabconsent.ParseGppHeader(tc.header)
+		c.Check(g, check.IsNil)
+		c.Check(err, check.Equals, tc.expected)
+
+		// The observed value is kept when wrapped by MapGppSectionToParser.
+		_, err = iabconsent.MapGppSectionToParser(tc.header + "~1YNN~BVVqAAEABCA")
+		c.Check(errors.Cause(err), check.Equals, tc.expected)
+	}
+}
+
 func (s *MspaSuite) TestMapGppSectionToParser(c *check.C) {
 	for gppString, expectedValues := range gppParsedConsentFixtures {
 		c.Log(gppString)
@@ -139,11 +232,21 @@ func (s *MspaSuite) TestMapGppSectionToParserErrors(c *check.C) {
 			gpp:      "DBABL",
 			expected: errors.New("not enough gpp segments"),
 		},
+		{
+			desc:     "Header only, header expects 1.",
+			gpp:      "DBABLA",
+			expected: errors.New("missing segment of gpp section 7"),
+		},
 		{
 			desc:     "Mismatched # of sections, header expects 1.",
 			gpp:      "DBABL~section1~section2",
 			expected: errors.New("mismatch number of sections"),
 		},
+		{
+			desc:     "Missing section, header expects 2.",
+			gpp:      "DBACLMA~BVVqAAEABCA",
+			expected: errors.New("missing segment of gpp section 9"),
+		},
 		{
 			desc:     "Bad header.",
 			gpp:      "badheader~BVVqAAEABCA.QA",
@@ -158,6 +261,13 @@ func (s *MspaSuite) TestMapGppSectionToParserErrors(c *check.C) {
 		c.Check(p, check.IsNil)
 		c.Check(err, check.ErrorMatches, t.expected.Error())
 	}
+
+	// A header declaring US National and Virginia, with only the US National segment.
+	var _, err = iabconsent.ParseGpp("DBACLMA~BVVqAAEABCA")
+	c.Check(err, check.Equals, iabconsent.ErrSectionSegmentMissing{SectionID: iabconsent.UsVirginiaSID})
+	// A header declaring US National and Virginia, without any section segments.
+	_, err = iabconsent.ParseGpp("DBACLMA")
+	c.Check(err, check.Equals, iabconsent.ErrSectionSegmentMissing{SectionID: iabconsent.UsNationalSID})
 }
 
 func (s *MspaSuite) TestParseGppConsent(c *check.C) {
@@ -174,6 +284,13 @@ func (s *MspaSuite) TestParseGppConsent(c *check.C) {
 			c.Check(parsed, check.DeepEquals, expected)
 		}
 	}
+
+	// A header declaring zero sections is a whole GPP string, with no sections.
+	var r, err = iabconsent.ParseGpp("DBAA")
+	c.Assert(err, check.IsNil)
+	c.Check(r.Sections, check.NotNil)
+	c.Check(r.Sections, check.HasLen, 0)
+	c.Check(r.Header, check.DeepEquals, &iabconsent.GppHeader{Type: 3, Version: 1})
 }
 
 func (s *MspaSuite) TestParseGppConsentError(c *check.C) {
@@ -213,7 +330,8 @@ func (s *GppParseSuite) TestParseGppSubSections(c *check.C) {
 			// 01000000
 			subsections: "QA",
 			expectedSubsection: &iabconsent.GppSubSection{
-				Gpc: false,
+				Gpc:        false,
+				GpcPresent: true,
 			},
 		},
 		{
@@ -221,7 +339,8 @@ func (s *GppParseSuite) TestParseGppSubSections(c *check.C) {
 			// 01100000
 			subsections: "YA",
 			expectedSubsection: &iabconsent.GppSubSection{
-				Gpc: true,
+				Gpc:        true,
+				GpcPresent: true,
 			},
 		},
 		{
@@ -237,7 +356,8 @@ func (s *GppParseSuite) TestParseGppSubSections(c *check.C) {
 			// 01100000.01000000
 			subsections: "YA.QA",
 			expectedSubsection: &iabconsent.GppSubSection{
-				Gpc: true,
+				Gpc:        true,
+				GpcPresent: true,
 			},
 		},
 		{
@@ -245,7 +365,53 @@ func (s *GppParseSuite) TestParseGppSubSections(c *check.C) {
 			// 01000000.01100000
 			subsections: "QA.YA",
 			expectedSubsection: &iabconsent.GppSubSection{
-				Gpc: true,
+				Gpc:        true,
+				GpcPresent: true,
+			},
+		},
+		{
+			description: "Type 0 terminator.",
+			// 000000
+			subsections: "A",
+			expectedSubsection: &iabconsent.GppSubSection{
+				Gpc: false,
+			},
+		},
+		{
+			description: "GPC True, then a type 0 terminator.",
+			// 01100000.000000
+			subsections: "YA.A",
+			expectedSubsection: &iabconsent.GppSubSection{
+				Gpc:        true,
+				GpcPresent: true,
+			},
+		},
+		{
+			description: "GPC False, then a type 0 terminator.",
+			// 01000000.000000
+			subsections: "QA.A",
+			expectedSubsection: &iabconsent.GppSubSection{
+				Gpc:        false,
+				GpcPresent: true,
+			},
+		},
+		{
+			description: "Unknown type 2, with the bit that follows the type set.",
+			// 10100000
+			subsections: "oA",
+			expectedSubsection: &iabconsent.GppSubSection{
+				Gpc:                false,
+				UnknownSubsections: []string{"oA"},
+			},
+		},
+		{
+			description: "GPC True, then unknown type 3.",
+			// 01100000.11100000
+			subsections: "YA.4A",
+			expectedSubsection: &iabconsent.GppSubSection{
+				Gpc:                true,
+				GpcPresent:         true,
+				UnknownSubsections: []string{"4A"},
 			},
 		},
 		{
@@ -269,6 +435,25 @@ func (s *GppParseSuite) TestParseGppSubSections(c *check.C) {
 		}
 		c.Check(g, check.DeepEquals, tc.expectedSubsection)
 	}
+
+	// The terminator is also skipped when parsing a full GPP string.
+	var sections, err = iabconsent.ParseGppConsent("DBABLA~BVVqAAEABCA.YA.A")
+	c.Assert(err, check.IsNil)
+	c.Assert(sections[iabconsent.UsNationalSID], check.NotNil)
+	var m = sections[iabconsent.UsNationalSID].(*iabconsent.MspaParsedConsent)
+	c.Check(m.Gpc, check.Equals, true)
+	c.Check(m.GpcPresent, check.Equals, true)
+	var reused iabconsent.MspaParsedConsent
+	c.Check(iabconsent.ParseMspaReuse(iabconsent.UsNationalSID, "BVVqAAEABCA.YA.A", &reused), check.IsNil)
+	c.Check(reused.Gpc, check.Equals, true)
+
+	// A subsection of another type is not read as GPC.
+	sections, err = iabconsent.ParseGppConsent("DBABLA~BVVqAAEABCA.oA")
+	c.Assert(err, check.IsNil)
+	c.Assert(sections[iabconsent.UsNationalSID], check.NotNil)
+	m = sections[iabconsent.UsNationalSID].(*iabconsent.MspaParsedConsent)
+	c.Check(m.Gpc, check.Equals, false)
+	c.Check(m.GpcPresent, check.Equals, false)
 }
 
 func (s *GppParseSuite) TestParseGpcSubSections(c *check.C) {
@@ -307,3 +492,613 @@ func (s *GppParseSuite) TestParseGpcSubSections(c *check.C) {
 		c.Check(g, check.DeepEquals, tc.expected)
 	}
 }
+
+func (s *MspaSuite) TestParseGppConsentEmptyInput(c *check.C) {
+	var tcs = []string{"", "   ", "\t\n"}
+	for _, tc := range tcs {
+		c.Logf("%q", tc)
+
+		var p, err = iabconsent.ParseGppConsent(tc)
+		c.Check(p, check.IsNil)
+		c.Check(err, check.Equals, iabconsent.ErrEmptyInput)
+
+		p, err = iabconsent.ParseGppConsent(tc, &iabconsent.Options{AllowEmptyInput: true})
+		c.Check(err, check.IsNil)
+		c.Check(p, check.HasLen, 0)
+	}
+}
+
+func (s *MspaSuite) TestParseGppConsentSurroundingWhitespace(c *check.C) {
+	var p, err = iabconsent.ParseGppConsent("  DBABLA~BVVqAAEABCA.YA\n")
+
+	c.Check(err, check.IsNil)
+	c.Check(p, check.DeepEquals, map[int]iabconsent.GppParsedConsent{
+		iabconsent.UsNationalSID: mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.YA"],
+	})
+}
+
+func (s *MspaSuite) TestParseGppOrderedSections(c *check.C) {
+	var gpp = "DBAGLbbY~BVVqAAEABCA~BVoYYZoI~BVoYYYI~BVoYYQg~BVaGGGCA~BVoYYYQg"
+	var r, err = iabconsent.ParseGpp(gpp)
+	c.Assert(err, check.IsNil)
+	var sids = r.Header.SectionIDs()
+	c.Check(sids, check.DeepEquals, []int{7, 8, 9, 10, 11, 12})
+
+	var ordered = r.OrderedSections()
+	c.Assert(ordered, check.HasLen, len(sids))
+	for i, sid := range sids {
+		c.Check(ordered[i], check.DeepEquals, gppParsedConsentFixtures[gpp][sid])
+	}
+
+	// The returned Section IDs are a copy.
+	sids[0] = 2
+	c.Check(r.Header.Sections[0], check.Equals, 7)
+
+	// Sections that are not parsed are skipped.
+	r, err = iabconsent.ParseGpp("DBABzw~1YNN~BVVqAAEABCA.QA")
+	c.Assert(err, check.IsNil)
+	c.Check(r.Header.SectionIDs(), check.DeepEquals, []int{6, 7})
+	c.Check(r.OrderedSections(), check.DeepEquals, []iabconsent.GppParsedConsent{
+		mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+	})
+
+	// Empty strings have no header.
+	r, err = iabconsent.ParseGpp("", &iabconsent.Options{AllowEmptyInput: true})
+	c.Assert(err, check.IsNil)
+	c.Check(r.Header, check.IsNil)
+	c.Check(r.OrderedSections(), check.IsNil)
+}
+
+func (s *MspaSuite) TestParseGppLenient(c *check.C) {
+	for g, expected := range gppParsedConsentFixtures {
+		c.Log(g)
+
+		// Well-formed strings parse the same as with ParseGppConsent.
+		var p, err = iabconsent.ParseGppLenient(g)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.HasLen, len(expected))
+		for sid, e := range expected {
+			c.Check(p[sid], check.DeepEquals, e)
+		}
+
+		if strings.Count(g, "~") != 1 || len(expected) == 0 {
+			continue
+		}
+		// A single section appended to the header without a separator.
+		var concatenated = strings.Replace(g, "~", "", 1)
+		_, err = iabconsent.ParseGppConsent(concatenated)
+		c.Check(err, check.ErrorMatches, "not enough gpp segments")
+		p, err = iabconsent.ParseGppLenient(concatenated)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.HasLen, len(expected))
+		for sid, e := range expected {
+			c.Check(p[sid], check.DeepEquals, e)
+		}
+	}
+
+	var tcs = []struct {
+		desc     string
+		gpp      string
+		expected string
+	}{
+		{
+			desc:     "Multiple sections.",
+			gpp:      "DBACNYABVVqAAEABCA",
+			expected: "can not split 2 gpp sections without separators",
+		},
+		{
+			desc:     "Invalid section.",
+			gpp:      "DBABLABVVqAAE",
+			expected: "can not find the end of the gpp header",
+		},
+		{
+			desc:     "Only a header.",
+			gpp:      "DBABLA",
+			expected: "missing segment of gpp section 7",
+		},
+		{
+			desc:     "Invalid header.",
+			gpp:      "BBABLABVVqAAEABCA",
+			expected: "read gpp header: wrong gpp header type 1",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var _, err = iabconsent.ParseGppLenient(tc.gpp)
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestParseGppDotSegmentSeparator(c *check.C) {
+	var dot = &iabconsent.Options{DotSegmentSeparator: true}
+	var tcs = []struct {
+		desc     string
+		gpp      string
+		expected string
+	}{
+		{
+			desc:     "Single section.",
+			gpp:      "DBABLA.BVVqAAEABCA",
+			expected: "DBABLA~BVVqAAEABCA",
+		},
+		{
+			desc:     "Single section with a subsection.",
+			gpp:      "DBABLA.BVVqAAEABCA.YA",
+			expected: "DBABLA~BVVqAAEABCA.YA",
+		},
+		{
+			desc:     "Multiple sections.",
+			gpp:      "DBACLMA.BVVqAAEABCA.YA~BVoYYYI",
+			expected: "DBACLMA~BVVqAAEABCA.YA~BVoYYYI",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var _, err = iabconsent.ParseGppConsent(tc.gpp)
+		c.Check(err, check.NotNil)
+
+		var r, expected *iabconsent.GppResult
+		r, err = iabconsent.ParseGpp(tc.gpp, dot)
+		c.Assert(err, check.IsNil)
+		expected, err = iabconsent.ParseGpp(tc.expected)
+		c.Assert(err, check.IsNil)
+		c.Check(r, check.DeepEquals, expected)
+	}
+
+	// Well-formed strings parse the same.
+	for g, expected := range gppParsedConsentFixtures {
+		c.Log(g)
+
+		var p, err = iabconsent.ParseGppConsent(g, dot)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.HasLen, len(expected))
+		for sid, e := range expected {
+			c.Check(p[sid], check.DeepEquals, e)
+		}
+	}
+
+	// Only the separator after the header is read from a `.`.
+	var _, err = iabconsent.ParseGppConsent("DBACLMA.BVVqAAEABCA.BVoYYYI", dot)
+	c.Check(err, check.Equals, iabconsent.ErrSectionSegmentMissing{SectionID: iabconsent.UsVirginiaSID})
+	_, err = iabconsent.ParseGppConsent("BBABLA.BVVqAAEABCA", dot)
+	c.Check(err, check.ErrorMatches, "not enough gpp segments")
+}
+
+func (s *MspaSuite) TestParseGppStandardBase64(c *check.C) {
+	var std = &iabconsent.Options{StandardBase64: true}
+	var tcs = []struct {
+		desc     string
+		gpp      string
+		expected string
+	}{
+		{
+			desc:     "Standard alphabet.",
+			gpp:      "DBACLMA~BVVqAAEAB+A.YA~BV/YYYI",
+			expected: "DBACLMA~BVVqAAEAB-A.YA~BV_YYYI",
+		},
+		{
+			desc:     "Standard alphabet with padding.",
+			gpp:      "DBABLA==~BVVqAAEAB/A=",
+			expected: "DBABLA~BVVqAAEAB_A",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		// Strict mode requires the URL-safe alphabet, so no section is parsed.
+		var p, _ = iabconsent.ParseGppConsent(tc.gpp)
+		c.Check(p, check.HasLen, 0)
+
+		var r, err = iabconsent.ParseGpp(tc.gpp, std)
+		c.Assert(err, check.IsNil)
+		var expected *iabconsent.GppResult
+		expected, err = iabconsent.ParseGpp(tc.expected)
+		c.Assert(err, check.IsNil)
+		c.Check(r, check.DeepEquals, expected)
+	}
+
+	// URL-safe strings parse the same.
+	for g, expected := range gppParsedConsentFixtures {
+		c.Log(g)
+
+		var p, err = iabconsent.ParseGppConsent(g, std)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.HasLen, len(expected))
+		for sid, e := range expected {
+			c.Check(p[sid], check.DeepEquals, e)
+		}
+	}
+}
+
+func (s *MspaSuite) TestCleanGppString(c *check.C) {
+	var tcs = []struct {
+		input    string
+		expected string
+	}{
+		{input: `"BVVqAAEABCA"`, expected: "BVVqAAEABCA"},
+		{input: "\uFEFFBVVqAAEABCA", expected: "BVVqAAEABCA"},
+		{input: " \"\uFEFFDBABLA~BVVqAAEABCA\" ", expected: "DBABLA~BVVqAAEABCA"},
+		{input: "\uFEFF\"DBABLA~BVVqAAEABCA\"\n", expected: "DBABLA~BVVqAAEABCA"},
+		{input: `'DBABLA~BVVqAAEABCA'`, expected: "DBABLA~BVVqAAEABCA"},
+		// Unmatched quotes are not stripped.
+		{input: `"DBABLA~BVVqAAEABCA`, expected: `"DBABLA~BVVqAAEABCA`},
+		{input: `"DBABLA~BVVqAAEABCA'`, expected: `"DBABLA~BVVqAAEABCA'`},
+		{input: `""`, expected: ""},
+		{input: `"`, expected: `"`},
+		// Whitespace within the string is removed, but the separators are kept.
+		{input: "DBABLA~\nBVVq\tAAEABCA", expected: "DBABLA~BVVqAAEABCA"},
+		{input: "\"DBACLMA\r\n~BVVqAAEABCA.Q A\t~BVoYYYI\"", expected: "DBACLMA~BVVqAAEABCA.QA~BVoYYYI"},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.input)
+		c.Check(iabconsent.CleanGppString(tc.input), check.Equals, tc.expected)
+	}
+
+	var expected, err = iabconsent.ParseGppConsent("DBABLA~BVVqAAEABCA")
+	c.Assert(err, check.IsNil)
+	for _, gpp := range []string{`"DBABLA~BVVqAAEABCA"`, "\uFEFFDBABLA~BVVqAAEABCA", "DBA\tBLA~\r\nBVVqAA\nEABCA"} {
+		c.Log(gpp)
+
+		var p map[int]iabconsent.GppParsedConsent
+		p, err = iabconsent.ParseGppConsent(gpp)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.DeepEquals, expected)
+		c.Check(iabconsent.IsValidGpp(gpp), check.Equals, true)
+	}
+}
+
+func (s *MspaSuite) TestParseGppConsentMaxSections(c *check.C) {
+	var gpp = "DBABrGA~BVVqAAEABCA~BVoYYZoI~BVoYYYI~BVoYYQg~BVaGGGCA~BVoYYYQg"
+
+	var p, err = iabconsent.ParseGppConsent(gpp, iabconsent.WithMaxSections(5))
+	c.Check(p, check.IsNil)
+	c.Check(err, check.Equals, iabconsent.ErrTooManySections)
+
+	p, err = iabconsent.ParseGppConsent(gpp, iabconsent.WithMaxSections(6))
+	c.Check(err, check.IsNil)
+	c.Check(p, check.HasLen, 6)
+
+	// Default limit.
+	var abusive = "DBABL" + strings.Repeat("~BVVqAAEABCA", iabconsent.DefaultMaxSections+1)
+	p, err = iabconsent.ParseGppConsent(abusive)
+	c.Check(p, check.IsNil)
+	c.Check(err, check.Equals, iabconsent.ErrTooManySections)
+
+	// Limits of 0 or less keep the default limit.
+	for _, n := range []int{0, -1} {
+		c.Log(n)
+		c.Check(iabconsent.WithMaxSections(n).MaxSections, check.Equals, 0)
+		p, err = iabconsent.ParseGppConsent(gpp, iabconsent.WithMaxSections(n))
+		c.Check(err, check.IsNil)
+		c.Check(p, check.HasLen, 6)
+		p, err = iabconsent.ParseGppConsent(abusive, &iabconsent.Options{MaxSections: n})
+		c.Check(err, check.Equals, iabconsent.ErrTooManySections)
+	}
+
+	// Combined with other Options.
+	p, err = iabconsent.ParseGppConsent(" ", iabconsent.WithMaxSections(1), &iabconsent.Options{AllowEmptyInput: true})
+	c.Check(err, check.IsNil)
+	c.Check(p, check.HasLen, 0)
+}
+
+func (s *MspaSuite) TestParseGppConsentInvalidEnumPolicy(c *check.C) {
+	// A US National section with the invalid value 3 in SensitiveDataProcessingConsents[2] and
+	// KnownChildSensitiveDataConsents[1], and a valid Virginia section.
+	var gpp = "DBACLMA~BAAADAAAMQA~BVoYYYI"
+	var virginia = mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"]
+
+	// By default, the values are kept as decoded.
+	var p, err = iabconsent.ParseGppConsent(gpp)
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.HasLen, 2)
+	var usnat = p[iabconsent.UsNationalSID].(*iabconsent.MspaParsedConsent)
+	c.Check(usnat.SensitiveDataProcessingConsents[2], check.Equals, iabconsent.InvalidConsentValue)
+	c.Check(usnat.KnownChildSensitiveDataConsents[1], check.Equals, iabconsent.InvalidConsentValue)
+	c.Check(usnat.MspaCoveredTransaction, check.Equals, iabconsent.MspaYes)
+
+	// The section with invalid values is skipped.
+	p, err = iabconsent.ParseGppConsent(gpp, iabconsent.WithInvalidEnumPolicy(iabconsent.InvalidEnumError))
+	c.Assert(err, check.IsNil)
+	c.Check(p, check.DeepEquals, map[int]iabconsent.GppParsedConsent{iabconsent.UsVirginiaSID: virginia})
+	var warnings []iabconsent.Warning
+	_, warnings, err = iabconsent.ParseGppWithWarnings(gpp, iabconsent.WithInvalidEnumPolicy(iabconsent.InvalidEnumError))
+	c.Assert(err, check.IsNil)
+	c.Check(warnings, check.DeepEquals, []iabconsent.Warning{{
+		SectionID: iabconsent.UsNationalSID,
+		Message: "section not parsed: invalid sensitive data value in KnownChildSensitiveDataConsents[1], " +
+			"SensitiveDataProcessingConsents[2]",
+	}})
+
+	// The invalid values are Not Applicable, and the other fields are unchanged.
+	for _, parser := range []func(int, string) iabconsent.GppSectionParser{iabconsent.NewMspa, iabconsent.NewMspaCompact, iabconsent.NewMspaLazy} {
+		p, err = iabconsent.ParseGppConsent(gpp, &iabconsent.Options{GppSectionParser: parser},
+			iabconsent.WithInvalidEnumPolicy(iabconsent.InvalidEnumCoerce))
+		c.Assert(err, check.IsNil)
+		c.Assert(p, check.HasLen, 2)
+		usnat = p[iabconsent.UsNationalSID].(*iabconsent.MspaParsedConsent)
+		c.Check(usnat.SensitiveDataSlice(), check.DeepEquals, make([]iabconsent.MspaConsent, 12))
+		c.Check(usnat.KnownChildSensitiveDataConsents, check.DeepEquals, map[int]iabconsent.MspaConsent{
+			0: iabconsent.ConsentNotApplicable,
+			1: iabconsent.ConsentNotApplicable,
+		})
+		c.Check(usnat.MspaCoveredTransaction, check.Equals, iabconsent.MspaYes)
+	}
+	var r *iabconsent.GppResult
+	r, warnings, err = iabconsent.ParseGppWithWarnings(gpp, iabconsent.WithInvalidEnumPolicy(iabconsent.InvalidEnumCoerce))
+	c.Assert(err, check.IsNil)
+	c.Check(r.Sections, check.HasLen, 2)
+	c.Check(warnings, check.DeepEquals, []iabconsent.Warning{
+		{SectionID: iabconsent.UsNationalSID, Field: "SensitiveDataProcessingConsents[2]", Message: "invalid value 3"},
+		{SectionID: iabconsent.UsNationalSID, Field: "KnownChildSensitiveDataConsents[1]", Message: "invalid value 3"},
+	})
+}
+
+func (s *MspaSuite) TestParseGppConsentEmptySection(c *check.C) {
+	var p, err = iabconsent.ParseGppConsent("DBABL~")
+	c.Assert(err, check.IsNil)
+	c.Check(p, check.DeepEquals, map[int]iabconsent.GppParsedConsent{
+		7: &iabconsent.EmptyGppSection{SectionID: 7},
+	})
+	c.Check(iabconsent.IsEmptySection(p[7]), check.Equals, true)
+
+	// Unsupported Section IDs are also present when empty, while sections not listed in
+	// the header are absent.
+	p, err = iabconsent.ParseGppConsent("DBABzw~~BVVqAAEABCA")
+	c.Assert(err, check.IsNil)
+	c.Check(p, check.HasLen, 2)
+	c.Check(p[6], check.DeepEquals, &iabconsent.EmptyGppSection{SectionID: 6})
+	c.Check(iabconsent.IsEmptySection(p[7]), check.Equals, false)
+	var _, ok = p[8]
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *MspaSuite) TestIsValidGpp(c *check.C) {
+	for g := range gppParsedConsentFixtures {
+		c.Log(g)
+		c.Check(iabconsent.IsValidGpp(g), check.Equals, true)
+	}
+	// Sections listed in the header may be present, but empty.
+	c.Check(iabconsent.IsValidGpp("DBABzw~~BVVqAAEABCA"), check.Equals, true)
+	c.Check(iabconsent.IsValidGpp("DBABzw~1YNN~"), check.Equals, true)
+	c.Check(iabconsent.IsValidGpp("DBAA"), check.Equals, true)
+
+	var tcs = []struct {
+		desc string
+		gpp  string
+	}{
+		{desc: "Empty.", gpp: ""},
+		{desc: "No sections.", gpp: "DBABL"},
+		{desc: "Bad header.", gpp: "badheader~BVVqAAEABCA.QA"},
+		{desc: "Mismatched # of sections, header expects 1.", gpp: "DBABL~BVVqAAEABCA~BVVqAAEABCA"},
+		{desc: "Mismatched # of sections, header expects 2.", gpp: "DBABzw~1YNN"},
+		{desc: "Invalid base64 character.", gpp: "DBABL~BVVq+AEABCA"},
+		{desc: "Too many sections.", gpp: "DBABL" + strings.Repeat("~BVVqAAEABCA", iabconsent.DefaultMaxSections+1)},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+		c.Check(iabconsent.IsValidGpp(tc.gpp), check.Equals, false)
+	}
+
+	// The maximum number of sections is set by the options, as for parsing.
+	c.Check(iabconsent.IsValidGpp("DBABzw~~BVVqAAEABCA", iabconsent.WithMaxSections(1)), check.Equals, false)
+	c.Check(iabconsent.IsValidGpp("DBABzw~~BVVqAAEABCA", iabconsent.WithMaxSections(2)), check.Equals, true)
+}
+
+func (s *MspaSuite) TestParseGppURLEncoded(c *check.C) {
+	var tcs = []string{
+		"DBABrGA%7EBVVqAAEABCA%7EBVoYYZoI%7EBVoYYYI%7EBVoYYQg%7EBVaGGGCA%7EBVoYYYQg",
+		"DBABrGA%7eBVVqAAEABCA%7eBVoYYZoI%7eBVoYYYI%7eBVoYYQg%7eBVaGGGCA%7eBVoYYYQg",
+		// Strings which are not encoded are parsed as-is.
+		"DBABrGA~BVVqAAEABCA~BVoYYZoI~BVoYYYI~BVoYYQg~BVaGGGCA~BVoYYYQg",
+	}
+	var expected, err = iabconsent.ParseGppConsent("DBABrGA~BVVqAAEABCA~BVoYYZoI~BVoYYYI~BVoYYQg~BVaGGGCA~BVoYYYQg")
+	c.Assert(err, check.IsNil)
+	c.Assert(expected, check.HasLen, 6)
+
+	for _, tc := range tcs {
+		c.Log(tc)
+
+		var p, err = iabconsent.ParseGppURLEncoded(tc)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.DeepEquals, expected)
+	}
+
+	// A `+` is part of a standard base64 string, rather than an encoded space.
+	var std = &iabconsent.Options{StandardBase64: true}
+	p, err := iabconsent.ParseGppURLEncoded("DBACLMA%7EBVVqAAEAB+A.YA%7EBV/YYYI", std)
+	c.Check(err, check.IsNil)
+	expected, err = iabconsent.ParseGppConsent("DBACLMA~BVVqAAEAB-A.YA~BV_YYYI")
+	c.Assert(err, check.IsNil)
+	c.Check(p, check.DeepEquals, expected)
+
+	p, err = iabconsent.ParseGppURLEncoded("DBABL%7~BVVqAAEABCA")
+	c.Check(p, check.IsNil)
+	c.Check(err, check.ErrorMatches, `unescape gpp string: invalid URL escape "%7~"`)
+}
+
+func (s *GppParseSuite) TestSectionName(c *check.C) {
+	// Every section the package can parse has a name, which maps back to its Section ID.
+	var parsed = 0
+	for sid := 0; sid <= 64; sid++ {
+		var name = iabconsent.SectionName(sid)
+		if iabconsent.NewMspa(sid, "") != nil {
+			parsed++
+			c.Check(name, check.Not(check.Equals), "", check.Commentf("section id %d", sid))
+		}
+		if name == "" {
+			continue
+		}
+		var id, ok = iabconsent.SectionID(name)
+		c.Check(ok, check.Equals, true)
+		c.Check(id, check.Equals, sid)
+	}
+	c.Check(parsed, check.Equals, 17)
+
+	c.Check(iabconsent.SectionName(iabconsent.UsDelawareSID), check.Equals, "usde")
+	c.Check(iabconsent.SectionName(iabconsent.UsPrivacySID), check.Equals, "uspv1")
+	c.Check(iabconsent.SectionName(1), check.Equals, "")
+	var _, ok = iabconsent.SectionID("USNAT")
+	c.Check(ok, check.Equals, false)
+}
+
+func (s *GppParseSuite) TestSplitGppSegments(c *check.C) {
+	var header, sections, err = iabconsent.SplitGppSegments(" \"DBACLMA~BVVqAAEABCA.QA~BVoYYYI\" ")
+	c.Assert(err, check.IsNil)
+	c.Check(header, check.Equals, "DBACLMA")
+	c.Check(sections, check.DeepEquals, []string{"BVVqAAEABCA.QA", "BVoYYYI"})
+
+	// A subset of the sections is reassembled with a new header, listing only Virginia.
+	var h *iabconsent.GppHeader
+	h, err = iabconsent.ParseGppHeader(header)
+	c.Assert(err, check.IsNil)
+	c.Check(h.Sections, check.DeepEquals, []int{iabconsent.UsNationalSID, iabconsent.UsVirginiaSID})
+	var p map[int]iabconsent.GppParsedConsent
+	p, err = iabconsent.ParseGppConsent("DBABRg~" + sections[1])
+	c.Assert(err, check.IsNil)
+	c.Check(p, check.DeepEquals, map[int]iabconsent.GppParsedConsent{
+		iabconsent.UsVirginiaSID: mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"],
+	})
+
+	// Empty sections are kept.
+	header, sections, err = iabconsent.SplitGppSegments("DBACLMA~~BVoYYYI")
+	c.Assert(err, check.IsNil)
+	c.Check(sections, check.DeepEquals, []string{"", "BVoYYYI"})
+
+	header, sections, err = iabconsent.SplitGppSegments("DBABLA")
+	c.Check(err, check.ErrorMatches, "not enough gpp segments")
+	c.Check(header, check.Equals, "")
+	c.Check(sections, check.IsNil)
+	_, _, err = iabconsent.SplitGppSegments(" ")
+	c.Check(err, check.Equals, iabconsent.ErrEmptyInput)
+}
+
+func (s *GppParseSuite) TestEncodeGppHeader(c *check.C) {
+	for _, h := range []string{"DBABLA", "DBACLMA", "DBABRg", "DBABrGA", "DBACNYA"} {
+		c.Log(h)
+
+		var parsed, err = iabconsent.ParseGppHeader(h)
+		c.Assert(err, check.IsNil)
+		var encoded string
+		encoded, err = iabconsent.EncodeGppHeader(parsed)
+		c.Assert(err, check.IsNil)
+		c.Check(encoded, check.Equals, h)
+	}
+
+	var _, err = iabconsent.EncodeGppHeader(&iabconsent.GppHeader{Type: 2, Version: 1})
+	c.Check(err, check.Equals, iabconsent.ErrBadGppType(2))
+	_, err = iabconsent.EncodeGppHeader(&iabconsent.GppHeader{Type: 3, Version: 2})
+	c.Check(err, check.ErrorMatches, "unsupported gpp version 2")
+	_, err = iabconsent.EncodeGppHeader(&iabconsent.GppHeader{Type: 3, Version: 1, Sections: []int{8, 7}})
+	c.Check(err, check.ErrorMatches, "encode gpp header: write fibonacci range: .*")
+}
+
+func (s *GppParseSuite) TestBuildGppString(c *check.C) {
+	var header, sections, err = iabconsent.SplitGppSegments("DBACLMA~BVVqAAEABCA~BVoYYYI")
+	c.Assert(err, check.IsNil)
+	var h *iabconsent.GppHeader
+	h, err = iabconsent.ParseGppHeader(header)
+	c.Assert(err, check.IsNil)
+
+	// Rebuilding every section reproduces the string.
+	var g string
+	g, err = iabconsent.BuildGppString(h, map[int]string{
+		iabconsent.UsVirginiaSID: sections[1],
+		iabconsent.UsNationalSID: sections[0],
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(g, check.Equals, "DBACLMA~BVVqAAEABCA~BVoYYYI")
+
+	// Dropping a section removes it from the header, which defaults to Type 3 and Version 1.
+	g, err = iabconsent.BuildGppString(nil, map[int]string{iabconsent.UsVirginiaSID: sections[1]})
+	c.Assert(err, check.IsNil)
+	c.Check(g, check.Equals, "DBABRg~BVoYYYI")
+	var p map[int]iabconsent.GppParsedConsent
+	p, err = iabconsent.ParseGppConsent(g)
+	c.Assert(err, check.IsNil)
+	c.Check(p, check.DeepEquals, map[int]iabconsent.GppParsedConsent{
+		iabconsent.UsVirginiaSID: mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"],
+	})
+
+	_, err = iabconsent.BuildGppString(h, nil)
+	c.Check(err, check.ErrorMatches, "no gpp sections")
+	_, err = iabconsent.BuildGppString(h, map[int]string{7: "BVVqAAEABCA~BVoYYYI"})
+	c.Check(err, check.ErrorMatches, "gpp section 7 contains a section separator")
+	_, err = iabconsent.BuildGppString(h, map[int]string{0: "BVVqAAEABCA"})
+	c.Check(err, check.ErrorMatches, "encode gpp header: write fibonacci range: .*")
+}
+
+func (s *GppParseSuite) TestParseConcurrently(c *check.C) {
+	// Parsing keeps no shared state, so the fixtures parse the same from many goroutines. Run
+	// with -race to also check for data races.
+	var expectedGpp = make(map[string]*iabconsent.GppResult, len(gppParsedConsentFixtures))
+	for g := range gppParsedConsentFixtures {
+		var r, err = iabconsent.ParseGpp(g)
+		c.Assert(err, check.IsNil)
+		expectedGpp[g] = r
+	}
+	// Lazily and compactly parsed consents are also shared by the goroutines, whose methods
+	// read them without modifying them.
+	type mspaFixture struct {
+		sid           int
+		section       string
+		expected      *iabconsent.MspaParsedConsent
+		lazy, compact *iabconsent.MspaParsedConsent
+	}
+	var mspaFixtures []mspaFixture
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, expected := range fixtures {
+			var f = mspaFixture{sid: sid, section: k, expected: expected}
+			var err error
+			f.lazy, err = iabconsent.ParseMspaLazy(sid, k)
+			c.Assert(err, check.IsNil)
+			f.compact, err = iabconsent.ParseMspaCompact(sid, k)
+			c.Assert(err, check.IsNil)
+			mspaFixtures = append(mspaFixtures, f)
+		}
+	}
+
+	const goroutines = 16
+	var gppResults = make([]map[string]*iabconsent.GppResult, goroutines)
+	var v2Results = make([]map[string]*iabconsent.V2ParsedConsent, goroutines)
+	var mspaResults = make([][]*iabconsent.MspaParsedConsent, goroutines)
+	var sharedResults = make([][][]iabconsent.MspaConsent, goroutines)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			gppResults[i] = make(map[string]*iabconsent.GppResult, len(gppParsedConsentFixtures))
+			for g := range gppParsedConsentFixtures {
+				gppResults[i][g], _ = iabconsent.ParseGpp(g)
+			}
+			v2Results[i] = make(map[string]*iabconsent.V2ParsedConsent, len(v2ConsentFixtures))
+			for k := range v2ConsentFixtures {
+				v2Results[i][k], _ = iabconsent.ParseV2(k)
+			}
+			for _, f := range mspaFixtures {
+				var lazy, _ = iabconsent.ParseMspaLazy(f.sid, f.section)
+				var compact, _ = iabconsent.ParseMspaCompact(f.sid, f.section)
+				mspaResults[i] = append(mspaResults[i], lazy.DecodeSensitiveData(), compact.DecodeSensitiveData())
+				f.lazy.Fields()
+				f.compact.NonDefaultFields()
+				sharedResults[i] = append(sharedResults[i], f.lazy.SensitiveDataSlice(), f.compact.SensitiveDataSlice())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < goroutines; i++ {
+		c.Check(gppResults[i], check.DeepEquals, expectedGpp)
+		for k, expected := range v2ConsentFixtures {
+			c.Check(v2Results[i][k], check.DeepEquals, expected, check.Commentf(k))
+		}
+		for j, f := range mspaFixtures {
+			c.Check(mspaResults[i][2*j], check.DeepEquals, f.expected, check.Commentf(f.section))
+			c.Check(mspaResults[i][2*j+1], check.DeepEquals, f.expected, check.Commentf(f.section))
+			c.Check(sharedResults[i][2*j], check.DeepEquals, f.expected.SensitiveDataSlice(), check.Commentf(f.section))
+			c.Check(sharedResults[i][2*j+1], check.DeepEquals, f.expected.SensitiveDataSlice(), check.Commentf(f.section))
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package iabconsent
+
+import (
+	"github.com/go-check/check"
+)
+
+type GppRegistrySuite struct{}
+
+var _ = check.Suite(&GppRegistrySuite{})
+
+// provincialSection is the parser of a hypothetical provincial section, whose consent is the
+// section value.
+type provincialSection struct {
+	GppSection
+}
+
+func (p *provincialSection) ParseConsent() (GppParsedConsent, error) {
+	return p.sectionValue, nil
+}
+
+func (s *GppRegistrySuite) TestRegisterSection(c *check.C) {
+	const sid = 99
+	c.Assert(gppSections[sid].name, check.Equals, "")
+	defer registerGppSection(sid, gppSectionInfo{name: "caqcv1", newParser: func(g GppSection) GppSectionParser {
+		return &provincialSection{g}
+	}})()
+
+	c.Check(SectionName(sid), check.Equals, "caqcv1")
+	var id, ok = SectionID("caqcv1")
+	c.Check(ok, check.Equals, true)
+	c.Check(id, check.Equals, sid)
+
+	var parser = NewMspa(sid, "BAAA")
+	c.Assert(parser, check.NotNil)
+	c.Check(parser.GetSectionId(), check.Equals, sid)
+
+	var header, err = EncodeGppHeader(&GppHeader{Type: 3, Version: 1, Sections: []int{UsVirginiaSID, sid}})
+	c.Assert(err, check.IsNil)
+	var consents map[int]GppParsedConsent
+	consents, err = ParseGppConsent(header + "~BVoYYYI~BAAA")
+	c.Assert(err, check.IsNil)
+	c.Check(consents, check.HasLen, 2)
+	c.Check(consents[sid], check.Equals, "BAAA")
+}
+
+func (s *GppRegistrySuite) TestRegisterSectionRestore(c *check.C) {
+	var restore = registerGppSection(UsVirginiaSID, gppSectionInfo{name: "usva2"})
+	c.Check(SectionName(UsVirginiaSID), check.Equals, "usva2")
+	c.Check(NewMspa(UsVirginiaSID, "BVoYYYI"), check.IsNil)
+	restore()
+	c.Check(SectionName(UsVirginiaSID), check.Equals, "usva")
+	c.Check(NewMspa(UsVirginiaSID, "BVoYYYI"), check.NotNil)
+
+	registerGppSection(99, gppSectionInfo{name: "caqcv1"})()
+	var _, ok = gppSections[99]
+	c.Check(ok, check.Equals, false)
+}
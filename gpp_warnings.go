@@ -0,0 +1,107 @@
+package iabconsent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// Warning is a recoverable issue found while parsing a GPP string, such as a section that could
+// not be parsed, or a field with a value that is not defined by the section's specification.
+// Warnings do not prevent the rest of the string from being used, but are useful to log the
+// quality of the strings sent by each CMP.
+type Warning struct {
+	// The Section ID of the section the issue was found in.
+	SectionID int
+	// The name of the MspaParsedConsent field the issue was found in, as returned by
+	// FieldsForVersion, or empty if the issue is not with a single field.
+	Field string
+	// A description of the issue.
+	Message string
+}
+
+func (w Warning) String() string {
+	var s = "section " + fmt.Sprint(w.SectionID)
+	if w.Field != "" {
+		s += " field " + w.Field
+	}
+	return s + ": " + w.Message
+}
+
+// ParseGppWithWarnings parses a GPP string like ParseGpp, on a best-effort basis, and also
+// returns a Warning for each recoverable issue found. Sections that fail to parse are skipped,
+// as with ParseGpp, and reported as warnings with the reason they failed. Parsed MSPA sections
+// are checked for fields with the invalid value 3, and for non-zero padding bits following the
+// core segment's fields. The error is only returned for issues with the string as a whole,
+// e.g. an invalid header, in which case no sections are parsed.
+func ParseGppWithWarnings(s string, options ...*Options) (*GppResult, []Warning, error) {
+	var option = optionsOrDefault(options)
+	var header, parsers, err = mapGppSectionToParser(s, option)
+	if err != nil {
+		return nil, nil, err
+	}
+	var result = &GppResult{Header: header, Sections: make(map[int]GppParsedConsent, len(parsers))}
+
+	var warnings []Warning
+	for _, parser := range parsers {
+		var sid = parser.GetSectionId()
+		var consent, parseErr = parser.ParseConsent()
+		var sectionWarnings []Warning
+		if parseErr == nil {
+			if m, ok := consent.(*MspaParsedConsent); ok {
+				var section string
+				if v, ok := parser.(interface{ sectionString() string }); ok {
+					section = v.sectionString()
+				}
+				// The warnings are found before invalid values are coerced.
+				sectionWarnings = mspaWarnings(sid, section, m)
+			}
+			parseErr = applyInvalidEnumPolicy(option.InvalidEnumPolicy, consent)
+		}
+		if parseErr != nil {
+			warnings = append(warnings, Warning{SectionID: sid, Message: "section not parsed: " + parseErr.Error()})
+			continue
+		}
+		result.Sections[sid] = consent
+		warnings = append(warnings, sectionWarnings...)
+	}
+	return result, warnings, nil
+}
+
+// sectionString returns the unparsed section value.
+func (g *GppSection) sectionString() string {
+	return g.sectionValue
+}
+
+// mspaWarnings returns the recoverable issues of a parsed MSPA section: fields with the
+// invalid value 3, which every 2 bit MSPA field reserves, and non-zero padding bits in the
+// core segment of section, which is skipped if empty.
+func mspaWarnings(sid int, section string, m *MspaParsedConsent) []Warning {
+	var warnings []Warning
+	var values = m.NonDefaultFields()
+	for _, f := range FieldsForVersion(sid, m.Version) {
+		if f != "Version" && values[f] == "3" {
+			warnings = append(warnings, Warning{SectionID: sid, Field: f, Message: "invalid value 3"})
+		}
+	}
+
+	var used = m.ConsumedBits(sid)
+	if section == "" || used == 0 {
+		return warnings
+	}
+	var b, err = base64.RawURLEncoding.DecodeString(strings.SplitN(section, ".", 2)[0])
+	if err != nil {
+		return warnings
+	}
+	var r = NewConsentReader(b)
+	if err = r.SkipBits(uint(used)); err != nil {
+		return warnings
+	}
+	if r.NumUnread() == 0 {
+		return warnings
+	}
+	if padding, err := r.ReadBits(uint(r.NumUnread())); err == nil && padding != 0 {
+		warnings = append(warnings, Warning{SectionID: sid, Message: "non-zero padding bits"})
+	}
+	return warnings
+}
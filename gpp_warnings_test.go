@@ -0,0 +1,89 @@
+package iabconsent_test
+
+import (
+	"strings"
+
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type GppWarningsSuite struct{}
+
+var _ = check.Suite(&GppWarningsSuite{})
+
+func (s *GppWarningsSuite) TestParseGppWithWarnings(c *check.C) {
+	var tcs = []struct {
+		desc             string
+		gpp              string
+		expectedSections []int
+		expected         []iabconsent.Warning
+	}{
+		{
+			desc:             "No warnings.",
+			gpp:              "DBABLA~BVVqAAEABCA.QA",
+			expectedSections: []int{iabconsent.UsNationalSID},
+		},
+		{
+			desc:             "Invalid value.",
+			gpp:              "DBABLA~BwAAAAAAAAA",
+			expectedSections: []int{iabconsent.UsNationalSID},
+			expected: []iabconsent.Warning{
+				{SectionID: iabconsent.UsNationalSID, Field: "SharingNotice", Message: "invalid value 3"},
+			},
+		},
+		{
+			desc:             "Non-zero padding.",
+			gpp:              "DBABLA~BAAAAAAAAAE",
+			expectedSections: []int{iabconsent.UsNationalSID},
+			expected: []iabconsent.Warning{
+				{SectionID: iabconsent.UsNationalSID, Message: "non-zero padding bits"},
+			},
+		},
+		{
+			desc:             "Section not parsed.",
+			gpp:              "DBACLMA~BVVqAA~BVoYYYI",
+			expectedSections: []int{iabconsent.UsVirginiaSID},
+			expected: []iabconsent.Warning{
+				{SectionID: iabconsent.UsNationalSID, Message: "section not parsed: invalid consent string length for v1"},
+			},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var r, warnings, err = iabconsent.ParseGppWithWarnings(tc.gpp)
+		c.Assert(err, check.IsNil)
+		c.Check(warnings, check.DeepEquals, tc.expected)
+		c.Check(r.Sections, check.HasLen, len(tc.expectedSections))
+		for _, sid := range tc.expectedSections {
+			c.Check(r.Sections[sid], check.NotNil)
+		}
+	}
+
+	// The fixtures parse the same as with ParseGpp, and only the US National v2 fixture, which
+	// pads its 70 bits with "10", has a warning.
+	for g := range gppParsedConsentFixtures {
+		c.Log(g)
+
+		var r, warnings, err = iabconsent.ParseGppWithWarnings(g)
+		c.Assert(err, check.IsNil)
+		var expectedWarnings []iabconsent.Warning
+		if strings.Contains(g, "CVVVVVVVVVVW") {
+			expectedWarnings = []iabconsent.Warning{{SectionID: iabconsent.UsNationalSID, Message: "non-zero padding bits"}}
+		}
+		c.Check(warnings, check.DeepEquals, expectedWarnings)
+		var expected *iabconsent.GppResult
+		expected, err = iabconsent.ParseGpp(g)
+		c.Assert(err, check.IsNil)
+		c.Check(r, check.DeepEquals, expected)
+	}
+
+	// Errors with the string as a whole are fatal.
+	var _, warnings, err = iabconsent.ParseGppWithWarnings("DBABLA")
+	c.Check(err, check.Equals, iabconsent.ErrSectionSegmentMissing{SectionID: iabconsent.UsNationalSID})
+	c.Check(warnings, check.IsNil)
+
+	c.Check(iabconsent.Warning{SectionID: 7, Field: "SaleOptOut", Message: "invalid value 3"}.String(),
+		check.Equals, "section 7 field SaleOptOut: invalid value 3")
+}
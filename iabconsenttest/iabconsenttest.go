@@ -0,0 +1,91 @@
+// Package iabconsenttest provides assertions for testing code that parses and encodes consent
+// strings with iabconsent, e.g. to run conformance checks on fixtures generated by the IAB's
+// reference encoder.
+package iabconsenttest
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/openx/iabconsent"
+)
+
+// AssertRoundTrip parses the MSPA section s of the given GPP Section ID, re-encodes it with
+// iabconsent.EncodeMspaSection, and fails t if the encoded string differs from s. Fields that
+// differ between s and the parsed encoded string are reported by name, as listed by
+// iabconsent.FieldsForVersion. If every field is equal, s has a non-canonical encoding, e.g.
+// non-zero padding bits, which is also reported as a failure.
+func AssertRoundTrip(t testing.TB, sid int, s string) {
+	t.Helper()
+
+	var parser = iabconsent.NewMspa(sid, s)
+	if parser == nil {
+		t.Fatalf("unsupported section id %d", sid)
+		return
+	}
+	var expected, err = parseMspa(parser)
+	if err != nil {
+		t.Fatalf("parse %q: %v", s, err)
+		return
+	}
+	var encoded string
+	if encoded, err = iabconsent.EncodeMspaSection(sid, expected); err != nil {
+		t.Fatalf("encode %q: %v", s, err)
+		return
+	}
+	if encoded == s {
+		return
+	}
+
+	var actual *iabconsent.MspaParsedConsent
+	if actual, err = parseMspa(iabconsent.NewMspa(sid, encoded)); err != nil {
+		t.Fatalf("parse encoded %q of %q: %v", encoded, s, err)
+		return
+	}
+	var diffs = diffFields(sid, expected, actual)
+	if len(diffs) == 0 {
+		t.Errorf("round trip of %q encoded %q: fields are equal, so %q is not canonically encoded", s, encoded, s)
+		return
+	}
+	for _, d := range diffs {
+		t.Errorf("round trip of %q encoded %q: %s", s, encoded, d)
+	}
+}
+
+// parseMspa parses a section with parser, and returns it as a MspaParsedConsent.
+func parseMspa(parser iabconsent.GppSectionParser) (*iabconsent.MspaParsedConsent, error) {
+	var p, err = parser.ParseConsent()
+	if err != nil {
+		return nil, err
+	}
+	return p.(*iabconsent.MspaParsedConsent), nil
+}
+
+// diffFields returns a description of every field that differs between expected and actual,
+// in the order they are encoded.
+func diffFields(sid int, expected, actual *iabconsent.MspaParsedConsent) []string {
+	var e, a = expected.NonDefaultFields(), actual.NonDefaultFields()
+	var diffs []string
+	for _, f := range iabconsent.FieldsForVersion(sid, expected.Version) {
+		if e[f] != a[f] {
+			diffs = append(diffs, f+": expected "+valueOf(e, f)+", got "+valueOf(a, f))
+		}
+	}
+	if expected.GpcPresent != actual.GpcPresent {
+		diffs = append(diffs, "GpcPresent: expected "+strconv.FormatBool(expected.GpcPresent)+
+			", got "+strconv.FormatBool(actual.GpcPresent))
+	}
+	return diffs
+}
+
+// valueOf returns the value of field f in fields, as returned by NonDefaultFields, which omits
+// fields that are Not Applicable or false.
+func valueOf(fields map[string]string, f string) string {
+	if v, ok := fields[f]; ok {
+		return v
+	}
+	if f == "Gpc" {
+		return "false"
+	}
+	return "0"
+}
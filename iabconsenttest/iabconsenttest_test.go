@@ -0,0 +1,89 @@
+package iabconsenttest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+	"github.com/openx/iabconsent/iabconsenttest"
+)
+
+func Test(t *testing.T) { check.TestingT(t) }
+
+type RoundTripSuite struct{}
+
+var _ = check.Suite(&RoundTripSuite{})
+
+// recorder is a testing.TB that records failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.fatal = fmt.Sprintf(format, args...)
+}
+
+func (s *RoundTripSuite) TestAssertRoundTrip(c *check.C) {
+	var tcs = []struct {
+		desc           string
+		sid            int
+		section        string
+		expectedErrors []string
+		expectedFatal  string
+	}{
+		{
+			desc:    "Canonical US National v1.",
+			sid:     iabconsent.UsNationalSID,
+			section: "BVVqAAEABCA.QA",
+		},
+		{
+			desc:    "Canonical Virginia.",
+			sid:     iabconsent.UsVirginiaSID,
+			section: "BVoYYYI",
+		},
+		{
+			desc:    "Non-zero padding bits.",
+			sid:     iabconsent.UsNationalSID,
+			section: "CVVVVVVVVVVW.YA",
+			expectedErrors: []string{`round trip of "CVVVVVVVVVVW.YA" encoded "CVVVVVVVVVVU.YA": ` +
+				`fields are equal, so "CVVVVVVVVVVW.YA" is not canonically encoded`},
+		},
+		{
+			desc:    "Type 0 terminator subsection.",
+			sid:     iabconsent.UsNationalSID,
+			section: "BVVqAAEABCA.A",
+			expectedErrors: []string{`round trip of "BVVqAAEABCA.A" encoded "BVVqAAEABCA": ` +
+				`fields are equal, so "BVVqAAEABCA.A" is not canonically encoded`},
+		},
+		{
+			desc:          "Unsupported Section ID.",
+			sid:           2,
+			section:       "BVVqAAEABCA",
+			expectedFatal: "unsupported section id 2",
+		},
+		{
+			desc:          "Parse error.",
+			sid:           iabconsent.UsNationalSID,
+			section:       "DVVqAAEABA",
+			expectedFatal: `parse "DVVqAAEABA": unsupported version: 3`,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var r = &recorder{}
+		iabconsenttest.AssertRoundTrip(r, tc.sid, tc.section)
+		c.Check(r.errors, check.DeepEquals, tc.expectedErrors)
+		c.Check(r.fatal, check.Equals, tc.expectedFatal)
+	}
+}
@@ -0,0 +1,230 @@
+package iabconsent
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// MspaConsentBuilder builds MSPA section strings from individual field values, which
+// is useful when constructing consent strings in tests and tooling. Setters can be
+// chained, and any field that is not set keeps the spec's Not Applicable (0) value:
+//
+//	var s, err = iabconsent.NewMspaConsentBuilder().
+//		SetSaleOptOutNotice(iabconsent.NoticeProvided).
+//		SetSaleOptOut(iabconsent.OptedOut).
+//		SetSensitiveDataConsent(0, iabconsent.NoConsent).
+//		SetGpc(true).
+//		Build(iabconsent.UsNationalSID)
+type MspaConsentBuilder struct {
+	consent MspaParsedConsent
+}
+
+// NewMspaConsentBuilder returns a MspaConsentBuilder for Version 1 of a section, with all
+// other fields Not Applicable.
+func NewMspaConsentBuilder() *MspaConsentBuilder {
+	return &MspaConsentBuilder{
+		consent: MspaParsedConsent{
+			Version:                         1,
+			SensitiveDataProcessingConsents: make(map[int]MspaConsent),
+			SensitiveDataProcessingOptOuts:  make(map[int]MspaOptout),
+			KnownChildSensitiveDataConsents: make(map[int]MspaConsent),
+		},
+	}
+}
+
+// SetVersion sets the version of the section specification to encode.
+func (b *MspaConsentBuilder) SetVersion(v int) *MspaConsentBuilder {
+	b.consent.Version = v
+	return b
+}
+
+// SetSharingNotice sets the SharingNotice field.
+func (b *MspaConsentBuilder) SetSharingNotice(n MspaNotice) *MspaConsentBuilder {
+	b.consent.SharingNotice = n
+	return b
+}
+
+// SetSaleOptOutNotice sets the SaleOptOutNotice field.
+func (b *MspaConsentBuilder) SetSaleOptOutNotice(n MspaNotice) *MspaConsentBuilder {
+	b.consent.SaleOptOutNotice = n
+	return b
+}
+
+// SetSharingOptOutNotice sets the SharingOptOutNotice field.
+func (b *MspaConsentBuilder) SetSharingOptOutNotice(n MspaNotice) *MspaConsentBuilder {
+	b.consent.SharingOptOutNotice = n
+	return b
+}
+
+// SetTargetedAdvertisingOptOutNotice sets the TargetedAdvertisingOptOutNotice field.
+func (b *MspaConsentBuilder) SetTargetedAdvertisingOptOutNotice(n MspaNotice) *MspaConsentBuilder {
+	b.consent.TargetedAdvertisingOptOutNotice = n
+	return b
+}
+
+// SetSensitiveDataProcessingOptOutNotice sets the SensitiveDataProcessingOptOutNotice field.
+func (b *MspaConsentBuilder) SetSensitiveDataProcessingOptOutNotice(n MspaNotice) *MspaConsentBuilder {
+	b.consent.SensitiveDataProcessingOptOutNotice = n
+	return b
+}
+
+// SetSensitiveDataLimitUseNotice sets the SensitiveDataLimitUseNotice field.
+func (b *MspaConsentBuilder) SetSensitiveDataLimitUseNotice(n MspaNotice) *MspaConsentBuilder {
+	b.consent.SensitiveDataLimitUseNotice = n
+	return b
+}
+
+// SetSaleOptOut sets the SaleOptOut field.
+func (b *MspaConsentBuilder) SetSaleOptOut(o MspaOptout) *MspaConsentBuilder {
+	b.consent.SaleOptOut = o
+	return b
+}
+
+// SetSharingOptOut sets the SharingOptOut field.
+func (b *MspaConsentBuilder) SetSharingOptOut(o MspaOptout) *MspaConsentBuilder {
+	b.consent.SharingOptOut = o
+	return b
+}
+
+// SetTargetedAdvertisingOptOut sets the TargetedAdvertisingOptOut field.
+func (b *MspaConsentBuilder) SetTargetedAdvertisingOptOut(o MspaOptout) *MspaConsentBuilder {
+	b.consent.TargetedAdvertisingOptOut = o
+	return b
+}
+
+// SetSensitiveDataConsent sets the Sensitive Data Processing consent at the zero-based index,
+// for sections that signal Sensitive Data Processing as consents.
+func (b *MspaConsentBuilder) SetSensitiveDataConsent(index int, c MspaConsent) *MspaConsentBuilder {
+	b.consent.SensitiveDataProcessingConsents[index] = c
+	return b
+}
+
+// SetSensitiveDataOptOut sets the Sensitive Data Processing opt-out at the zero-based index,
+// for sections that signal Sensitive Data Processing as opt-outs (e.g. California, Utah, Iowa).
+func (b *MspaConsentBuilder) SetSensitiveDataOptOut(index int, o MspaOptout) *MspaConsentBuilder {
+	b.consent.SensitiveDataProcessingOptOuts[index] = o
+	return b
+}
+
+// SetKnownChildSensitiveDataConsent sets the Known Child Sensitive Data consent at the
+// zero-based index.
+func (b *MspaConsentBuilder) SetKnownChildSensitiveDataConsent(index int, c MspaConsent) *MspaConsentBuilder {
+	b.consent.KnownChildSensitiveDataConsents[index] = c
+	return b
+}
+
+// SetPersonalDataConsents sets the PersonalDataConsents field.
+func (b *MspaConsentBuilder) SetPersonalDataConsents(c MspaConsent) *MspaConsentBuilder {
+	b.consent.PersonalDataConsents = c
+	return b
+}
+
+// SetMspaCoveredTransaction sets the MspaCoveredTransaction field.
+func (b *MspaConsentBuilder) SetMspaCoveredTransaction(v MspaNaYesNo) *MspaConsentBuilder {
+	b.consent.MspaCoveredTransaction = v
+	return b
+}
+
+// SetMspaOptOutOptionMode sets the MspaOptOutOptionMode field.
+func (b *MspaConsentBuilder) SetMspaOptOutOptionMode(v MspaNaYesNo) *MspaConsentBuilder {
+	b.consent.MspaOptOutOptionMode = v
+	return b
+}
+
+// SetMspaServiceProviderMode sets the MspaServiceProviderMode field.
+func (b *MspaConsentBuilder) SetMspaServiceProviderMode(v MspaNaYesNo) *MspaConsentBuilder {
+	b.consent.MspaServiceProviderMode = v
+	return b
+}
+
+// SetGpc sets the Gpc subsection value. The GPC subsection is encoded even when gpc is false,
+// as it is then explicitly signaled (see GpcPresent).
+func (b *MspaConsentBuilder) SetGpc(gpc bool) *MspaConsentBuilder {
+	b.consent.Gpc = gpc
+	b.consent.GpcPresent = true
+	return b
+}
+
+// Build validates the set fields against the layout of the given GPP Section ID and version,
+// and encodes them with EncodeMspaSection. Building does not modify the builder, so it can be
+// built again for a different section, or after setting more fields.
+func (b *MspaConsentBuilder) Build(sid int) (string, error) {
+	if err := b.validate(sid); err != nil {
+		return "", errors.Wrap(err, "build mspa consent string")
+	}
+	return EncodeMspaSection(sid, &b.consent)
+}
+
+// validate checks that every set value is a valid MSPA value, and that every indexed value
+// exists in the section's layout.
+func (b *MspaConsentBuilder) validate(sid int) error {
+	var versions, ok = mspaSectionLayouts[sid]
+	if !ok {
+		return errors.New("unsupported section id: " + fmt.Sprint(sid))
+	}
+	var layout mspaSectionLayout
+	if layout, ok = versions[b.consent.Version]; !ok {
+		return ErrUnsupportedVersion{SectionID: sid, Version: b.consent.Version}
+	}
+
+	var p = &b.consent
+	for _, n := range []MspaNotice{p.SharingNotice, p.SaleOptOutNotice, p.SharingOptOutNotice,
+		p.TargetedAdvertisingOptOutNotice, p.SensitiveDataProcessingOptOutNotice, p.SensitiveDataLimitUseNotice} {
+		if n < NoticeNotApplicable || n >= InvalidNoticeValue {
+			return errors.New("invalid notice value: " + fmt.Sprint(n))
+		}
+	}
+	for _, o := range []MspaOptout{p.SaleOptOut, p.SharingOptOut, p.TargetedAdvertisingOptOut} {
+		if o < OptOutNotApplicable || o >= InvalidOptOutValue {
+			return errors.New("invalid opt-out value: " + fmt.Sprint(o))
+		}
+	}
+	if p.PersonalDataConsents < ConsentNotApplicable || p.PersonalDataConsents >= InvalidConsentValue {
+		return errors.New("invalid consent value: " + fmt.Sprint(p.PersonalDataConsents))
+	}
+	for _, v := range []MspaNaYesNo{p.MspaCoveredTransaction, p.MspaOptOutOptionMode, p.MspaServiceProviderMode} {
+		if v < MspaNotApplicable || v >= InvalidMspaValue {
+			return errors.New("invalid mspa value: " + fmt.Sprint(v))
+		}
+	}
+
+	if usesSensitiveDataOptOuts(sid) && len(p.SensitiveDataProcessingConsents) > 0 {
+		return errors.New("section id " + fmt.Sprint(sid) + " does not support sensitive data consents")
+	}
+	if !usesSensitiveDataOptOuts(sid) && len(p.SensitiveDataProcessingOptOuts) > 0 {
+		return errors.New("section id " + fmt.Sprint(sid) + " does not support sensitive data opt-outs")
+	}
+	for i, c := range p.SensitiveDataProcessingConsents {
+		if err := validateMspaIndexedConsent("sensitive data", i, layout.sensitiveData, c); err != nil {
+			return err
+		}
+	}
+	for i, o := range p.SensitiveDataProcessingOptOuts {
+		if i < 0 || i >= int(layout.sensitiveData) {
+			return errors.New("sensitive data index " + fmt.Sprint(i) + " out of range for " +
+				fmt.Sprint(layout.sensitiveData) + " fields")
+		}
+		if o < OptOutNotApplicable || o >= InvalidOptOutValue {
+			return errors.New("invalid opt-out value: " + fmt.Sprint(o))
+		}
+	}
+	for i, c := range p.KnownChildSensitiveDataConsents {
+		if err := validateMspaIndexedConsent("known child", i, layout.knownChild, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateMspaIndexedConsent checks that the zero-based index i is within n fields, and that c
+// is a valid MSPA consent value.
+func validateMspaIndexedConsent(field string, i int, n uint, c MspaConsent) error {
+	if i < 0 || i >= int(n) {
+		return errors.New(field + " index " + fmt.Sprint(i) + " out of range for " + fmt.Sprint(n) + " fields")
+	}
+	if c < ConsentNotApplicable || c >= InvalidConsentValue {
+		return errors.New("invalid consent value: " + fmt.Sprint(c))
+	}
+	return nil
+}
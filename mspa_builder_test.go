@@ -0,0 +1,124 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+func (s *MspaSuite) TestMspaConsentBuilder(c *check.C) {
+	var encoded, err = iabconsent.NewMspaConsentBuilder().
+		SetSaleOptOutNotice(iabconsent.NoticeProvided).
+		SetSaleOptOut(iabconsent.OptedOut).
+		SetSensitiveDataConsent(1, iabconsent.NoConsent).
+		SetKnownChildSensitiveDataConsent(0, iabconsent.Consent).
+		SetMspaCoveredTransaction(iabconsent.MspaYes).
+		SetGpc(true).
+		Build(iabconsent.UsNationalSID)
+	c.Check(err, check.IsNil)
+
+	p, err := iabconsent.NewMspa(iabconsent.UsNationalSID, encoded).ParseConsent()
+	c.Check(err, check.IsNil)
+	c.Check(p, check.DeepEquals, &iabconsent.MspaParsedConsent{
+		Version:          1,
+		SaleOptOutNotice: iabconsent.NoticeProvided,
+		SaleOptOut:       iabconsent.OptedOut,
+		SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{
+			0: 0, 1: iabconsent.NoConsent, 2: 0, 3: 0, 4: 0, 5: 0, 6: 0, 7: 0, 8: 0, 9: 0, 10: 0, 11: 0,
+		},
+		KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{0: iabconsent.Consent, 1: 0},
+		MspaCoveredTransaction:          iabconsent.MspaYes,
+		Gpc:                             true,
+		GpcPresent:                      true,
+	})
+}
+
+func (s *MspaSuite) TestMspaConsentBuilderDefaults(c *check.C) {
+	var b = iabconsent.NewMspaConsentBuilder()
+	for sid := range mspaConsentFixtures {
+		c.Log(sid)
+
+		var encoded, err = b.Build(sid)
+		c.Check(err, check.IsNil)
+
+		p, err := iabconsent.NewMspa(sid, encoded).ParseConsent()
+		c.Check(err, check.IsNil)
+		var m = p.(*iabconsent.MspaParsedConsent)
+		c.Check(m.Version, check.Equals, 1)
+		c.Check(m.SaleOptOut, check.Equals, iabconsent.OptOutNotApplicable)
+		c.Check(m.PersonalDataConsents, check.Equals, iabconsent.ConsentNotApplicable)
+		c.Check(m.Gpc, check.Equals, false)
+	}
+}
+
+func (s *MspaSuite) TestMspaConsentBuilderError(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		builder  *iabconsent.MspaConsentBuilder
+		sid      int
+		expected string
+	}{
+		{
+			desc:     "Unsupported Section ID.",
+			builder:  iabconsent.NewMspaConsentBuilder(),
+			sid:      2,
+			expected: "build mspa consent string: unsupported section id: 2",
+		},
+		{
+			desc:     "Unsupported Version.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetVersion(2),
+			sid:      iabconsent.UsVirginiaSID,
+			expected: "build mspa consent string: unsupported version: 2",
+		},
+		{
+			desc:     "Invalid Notice.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetSharingNotice(iabconsent.InvalidNoticeValue),
+			sid:      iabconsent.UsNationalSID,
+			expected: "build mspa consent string: invalid notice value: 3",
+		},
+		{
+			desc:     "Invalid Opt-Out.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetSaleOptOut(-1),
+			sid:      iabconsent.UsNationalSID,
+			expected: "build mspa consent string: invalid opt-out value: -1",
+		},
+		{
+			desc:     "Invalid MSPA Mode.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetMspaServiceProviderMode(iabconsent.InvalidMspaValue),
+			sid:      iabconsent.UsNationalSID,
+			expected: "build mspa consent string: invalid mspa value: 3",
+		},
+		{
+			desc:     "Sensitive Data Index Out Of Range.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetSensitiveDataConsent(7, iabconsent.Consent),
+			sid:      iabconsent.UsColoradoSID,
+			expected: "build mspa consent string: sensitive data index 7 out of range for 7 fields",
+		},
+		{
+			desc:     "Known Child Index Out Of Range.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetKnownChildSensitiveDataConsent(1, iabconsent.Consent),
+			sid:      iabconsent.UsVirginiaSID,
+			expected: "build mspa consent string: known child index 1 out of range for 1 fields",
+		},
+		{
+			desc:     "Sensitive Data Consent For Opt-Out Section.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetSensitiveDataConsent(0, iabconsent.Consent),
+			sid:      iabconsent.UsCaliforniaSID,
+			expected: "build mspa consent string: section id 8 does not support sensitive data consents",
+		},
+		{
+			desc:     "Sensitive Data Opt-Out For Consent Section.",
+			builder:  iabconsent.NewMspaConsentBuilder().SetSensitiveDataOptOut(0, iabconsent.OptedOut),
+			sid:      iabconsent.UsNationalSID,
+			expected: "build mspa consent string: section id 7 does not support sensitive data opt-outs",
+		},
+	}
+	for _, t := range tcs {
+		c.Log(t.desc)
+
+		var encoded, err = t.builder.Build(t.sid)
+
+		c.Check(encoded, check.Equals, "")
+		c.Check(err, check.ErrorMatches, t.expected)
+	}
+}
@@ -0,0 +1,79 @@
+package iabconsent
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// MarshalJSON encodes the MspaParsedConsent as encoding/json does by default, but with the
+// keys of SensitiveDataProcessingConsents, SensitiveDataProcessingOptOuts, and
+// KnownChildSensitiveDataConsents in ascending index order, instead of the order of their
+// string form, which puts "10" before "2". Equal consents are encoded to identical bytes, e.g.
+// for use as storage keys, and the output decodes with json.Unmarshal. The Sensitive Data
+// Processing fields of a consent parsed with ParseMspaLazy are decoded without modifying m.
+func (m MspaParsedConsent) MarshalJSON() ([]byte, error) {
+	m = *m.DecodeSensitiveData()
+	// plain has no methods, to not call MarshalJSON recursively. Its indexed fields are
+	// shadowed by the fields of the same name, which are encoded in order.
+	type plain MspaParsedConsent
+	return json.Marshal(struct {
+		plain
+		SensitiveDataProcessingConsents indexedJSON
+		SensitiveDataProcessingOptOuts  indexedJSON
+		KnownChildSensitiveDataConsents indexedJSON
+	}{
+		plain(m),
+		consentIndexedJSON(m.SensitiveDataProcessingConsents),
+		optOutIndexedJSON(m.SensitiveDataProcessingOptOuts),
+		consentIndexedJSON(m.KnownChildSensitiveDataConsents),
+	})
+}
+
+// indexedJSON is a map of zero-based field indexes to field values, which is encoded as a JSON
+// object with its keys in ascending order. A nil map is encoded as null.
+type indexedJSON map[int]int
+
+func (m indexedJSON) MarshalJSON() ([]byte, error) {
+	if m == nil {
+		return []byte("null"), nil
+	}
+	var keys = make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	var b = []byte{'{'}
+	for i, k := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, '"')
+		b = strconv.AppendInt(b, int64(k), 10)
+		b = append(b, '"', ':')
+		b = strconv.AppendInt(b, int64(m[k]), 10)
+	}
+	return append(b, '}'), nil
+}
+
+func consentIndexedJSON(m map[int]MspaConsent) indexedJSON {
+	if m == nil {
+		return nil
+	}
+	var j = make(indexedJSON, len(m))
+	for k, v := range m {
+		j[k] = int(v)
+	}
+	return j
+}
+
+func optOutIndexedJSON(m map[int]MspaOptout) indexedJSON {
+	if m == nil {
+		return nil
+	}
+	var j = make(indexedJSON, len(m))
+	for k, v := range m {
+		j[k] = int(v)
+	}
+	return j
+}
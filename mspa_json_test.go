@@ -0,0 +1,55 @@
+package iabconsent_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+func (s *MspaSuite) TestMspaParsedConsentMarshalJSON(c *check.C) {
+	// US National v2 has 16 Sensitive Data Processing fields, which encoding/json would order
+	// with "10" before "2".
+	var m = iabconsent.NewOptedOutMspaConsent(iabconsent.UsNationalSID)
+	var expected, err = json.Marshal(m)
+	c.Assert(err, check.IsNil)
+	c.Check(strings.Contains(string(expected), `"SensitiveDataProcessingConsents":{"0":1,"1":1,"2":1,"3":1,"4":1,`+
+		`"5":1,"6":1,"7":1,"8":1,"9":1,"10":1,"11":1,"12":1,"13":1,"14":1,"15":1}`), check.Equals, true)
+	c.Check(strings.Contains(string(expected), `"SensitiveDataProcessingOptOuts":null`), check.Equals, true)
+	c.Check(strings.Contains(string(expected), `"KnownChildSensitiveDataConsents":{"0":1,"1":1,"2":1}`), check.Equals, true)
+	for i := 0; i < 100; i++ {
+		var b []byte
+		b, err = json.Marshal(m)
+		c.Assert(err, check.IsNil)
+		c.Assert(string(b), check.Equals, string(expected))
+	}
+
+	// Values and pointers are encoded the same, and the output decodes to the same consent.
+	var b []byte
+	b, err = json.Marshal(*m)
+	c.Assert(err, check.IsNil)
+	c.Check(string(b), check.Equals, string(expected))
+	var decoded *iabconsent.MspaParsedConsent
+	c.Assert(json.Unmarshal(expected, &decoded), check.IsNil)
+	c.Check(decoded, check.DeepEquals, m)
+
+	// Lazily parsed consents are encoded with their Sensitive Data Processing fields.
+	for sid, fixtures := range mspaConsentFixtures {
+		for section := range fixtures {
+			c.Log(section)
+
+			var eager, lazy iabconsent.GppParsedConsent
+			eager, err = iabconsent.NewMspa(sid, section).ParseConsent()
+			c.Assert(err, check.IsNil)
+			lazy, err = iabconsent.ParseMspaLazy(sid, section)
+			c.Assert(err, check.IsNil)
+			expected, err = json.Marshal(eager)
+			c.Assert(err, check.IsNil)
+			b, err = json.Marshal(lazy)
+			c.Assert(err, check.IsNil)
+			c.Check(string(b), check.Equals, string(expected))
+		}
+	}
+}
@@ -1,5 +1,12 @@
 package iabconsent
 
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
 // MspaParsedConsent represents data extract from a Multi-State Privacy Agreement (mspa) consent string.
 // Format can be found here: https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/blob/main/Sections/US-National/IAB%20Privacy%E2%80%99s%20National%20Privacy%20Technical%20Specification.md#core-segment
 type MspaParsedConsent struct {
@@ -93,17 +100,701 @@ type MspaParsedConsent struct {
 	// Subsections added below:
 	// Global Privacy Control (GPC) is signaled and set.
 	Gpc bool
+	// The GPC subsection is present. Together with Gpc, this distinguishes GPC signaled as
+	// true, GPC explicitly signaled as false, and the GPC signal being absent.
+	GpcPresent bool
+
+	// packedSensitiveData locates the undecoded Sensitive Data Processing fields of a consent
+	// parsed with ParseMspaLazy or ParseMspaCompact. It is never modified after parsing.
+	packedSensitiveData *packedSensitiveData
+}
+
+// packedSensitiveData is the location of the Sensitive Data Processing fields in a core
+// segment, which stores them compactly, 2 bits per field.
+type packedSensitiveData struct {
+	// The decoded core segment.
+	core []byte
+	// The bit offset of the first field in core.
+	offset uint
+	// The number of fields.
+	count uint
+	// Whether the fields are opt-outs, rather than consents.
+	optOuts bool
+}
+
+// value returns the value of the zero-based field i. The fields are 2 bits long, and start at
+// an even bit offset after the 6 bit Version, so a field never spans two bytes.
+func (l *packedSensitiveData) value(i int) int {
+	var bit = l.offset + 2*uint(i)
+	return int(l.core[bit/8]>>(6-bit%8)) & 3
+}
+
+// DecodeSensitiveData returns a copy of a consent parsed with ParseMspaLazy or ParseMspaCompact,
+// with its Sensitive Data Processing fields decoded into SensitiveDataProcessingConsents or
+// SensitiveDataProcessingOptOuts, which are nil in the parsed consent. The methods of
+// MspaParsedConsent read the undecoded fields directly, so it only has to be called to read
+// those maps. The consent is not modified, and is returned as is if its fields are decoded.
+func (m *MspaParsedConsent) DecodeSensitiveData() *MspaParsedConsent {
+	if m.packedSensitiveData == nil {
+		return m
+	}
+	var d = *m
+	d.packedSensitiveData = nil
+	if m.packedSensitiveData.optOuts {
+		d.SensitiveDataProcessingOptOuts = m.sensitiveDataOptOuts()
+	} else {
+		d.SensitiveDataProcessingConsents = m.sensitiveDataConsents()
+	}
+	return &d
+}
+
+// sensitiveDataConsents returns SensitiveDataProcessingConsents, or a new map of the undecoded
+// consents of a consent parsed with ParseMspaLazy or ParseMspaCompact.
+func (m *MspaParsedConsent) sensitiveDataConsents() map[int]MspaConsent {
+	var l = m.packedSensitiveData
+	if l == nil || l.optOuts {
+		return m.SensitiveDataProcessingConsents
+	}
+	var consents = make(map[int]MspaConsent, l.count)
+	for i := 0; i < int(l.count); i++ {
+		consents[i] = MspaConsent(l.value(i))
+	}
+	return consents
+}
+
+// sensitiveDataOptOuts returns SensitiveDataProcessingOptOuts, or a new map of the undecoded
+// opt-outs of a consent parsed with ParseMspaLazy.
+func (m *MspaParsedConsent) sensitiveDataOptOuts() map[int]MspaOptout {
+	var l = m.packedSensitiveData
+	if l == nil || !l.optOuts {
+		return m.SensitiveDataProcessingOptOuts
+	}
+	var optOuts = make(map[int]MspaOptout, l.count)
+	for i := 0; i < int(l.count); i++ {
+		optOuts[i] = MspaOptout(l.value(i))
+	}
+	return optOuts
+}
+
+// Reset clears all fields of the MspaParsedConsent so it can be reused, e.g. with
+// ParseMspaReuse. Maps are emptied rather than discarded, to reuse their allocations.
+func (m *MspaParsedConsent) Reset() {
+	var sd, sdo, kc = m.SensitiveDataProcessingConsents, m.SensitiveDataProcessingOptOuts, m.KnownChildSensitiveDataConsents
+	for k := range sd {
+		delete(sd, k)
+	}
+	for k := range sdo {
+		delete(sdo, k)
+	}
+	for k := range kc {
+		delete(kc, k)
+	}
+	*m = MspaParsedConsent{
+		SensitiveDataProcessingConsents: sd,
+		SensitiveDataProcessingOptOuts:  sdo,
+		KnownChildSensitiveDataConsents: kc,
+	}
+}
+
+// SensitiveDataSlice returns the Sensitive Data Processing consents as a new slice indexed by
+// the zero-based category, regardless of whether they were decoded into the
+// SensitiveDataProcessingConsents map, or kept undecoded by ParseMspaCompact or ParseMspaLazy.
+// Sections that signal Sensitive Data Processing as opt-outs (e.g. California) return nil, and
+// categories missing from the map are Not Applicable.
+func (m *MspaParsedConsent) SensitiveDataSlice() []MspaConsent {
+	if l := m.packedSensitiveData; l != nil && !l.optOuts {
+		var sd = make([]MspaConsent, l.count)
+		for i := range sd {
+			sd[i] = MspaConsent(l.value(i))
+		}
+		return sd
+	}
+	if m.SensitiveDataProcessingConsents == nil {
+		return nil
+	}
+	var n = 0
+	for i := range m.SensitiveDataProcessingConsents {
+		if i >= n {
+			n = i + 1
+		}
+	}
+	var sd = make([]MspaConsent, n)
+	for i, c := range m.SensitiveDataProcessingConsents {
+		sd[i] = c
+	}
+	return sd
+}
+
+// invalidSensitiveDataFields returns the sorted names, as listed by FieldsForVersion, of the
+// Sensitive Data Processing and Known Child Sensitive Data fields with an invalid value. If
+// coerce is true, the invalid values are set to Not Applicable, which decodes the fields of a
+// consent parsed with ParseMspaLazy, so it is only called while the consent is being parsed.
+func (m *MspaParsedConsent) invalidSensitiveDataFields(coerce bool) []string {
+	if coerce {
+		*m = *m.DecodeSensitiveData()
+	}
+	var invalid []string
+	var checkConsents = func(name string, consents map[int]MspaConsent) {
+		for i, c := range consents {
+			if c >= InvalidConsentValue {
+				invalid = append(invalid, name+"["+fmt.Sprint(i)+"]")
+				if coerce {
+					consents[i] = ConsentNotApplicable
+				}
+			}
+		}
+	}
+	checkConsents("SensitiveDataProcessingConsents", m.sensitiveDataConsents())
+	for i, o := range m.sensitiveDataOptOuts() {
+		if o >= InvalidOptOutValue {
+			invalid = append(invalid, "SensitiveDataProcessingOptOuts["+fmt.Sprint(i)+"]")
+			if coerce {
+				m.SensitiveDataProcessingOptOuts[i] = OptOutNotApplicable
+			}
+		}
+	}
+	checkConsents("KnownChildSensitiveDataConsents", m.KnownChildSensitiveDataConsents)
+	sort.Strings(invalid)
+	return invalid
+}
+
+// SensitiveDataCount returns the number of Sensitive Data Processing fields in the parsed
+// consent, from either SensitiveDataProcessingConsents or
+// SensitiveDataProcessingOptOuts, depending on which the section uses. A successfully parsed
+// section always has the number of fields returned by MspaSensitiveDataCount for its Section
+// ID and Version.
+func (m *MspaParsedConsent) SensitiveDataCount() int {
+	if m.packedSensitiveData != nil {
+		return int(m.packedSensitiveData.count)
+	}
+	return len(m.SensitiveDataProcessingConsents) + len(m.SensitiveDataProcessingOptOuts)
+}
+
+// OptedOutSensitiveCategories returns the sorted indexes of SensitiveDataProcessingOptOuts
+// that are set to OptedOut. Only sections that express sensitive data processing as opt-outs
+// (e.g. California, Utah, Iowa) populate this field, so other sections will return an empty slice.
+func (m *MspaParsedConsent) OptedOutSensitiveCategories() []int {
+	var categories = make([]int, 0)
+	for i, o := range m.sensitiveDataOptOuts() {
+		if o == OptedOut {
+			categories = append(categories, i)
+		}
+	}
+	sort.Ints(categories)
+	return categories
+}
+
+// ConsentedSensitiveCategories returns the sorted indexes of SensitiveDataProcessingConsents
+// that are set to Consent. Only sections that express sensitive data processing as consents
+// populate this field, so opt-out based sections will return an empty slice.
+func (m *MspaParsedConsent) ConsentedSensitiveCategories() []int {
+	var categories = make([]int, 0)
+	for i, c := range m.sensitiveDataConsents() {
+		if c == Consent {
+			categories = append(categories, i)
+		}
+	}
+	sort.Ints(categories)
+	return categories
+}
+
+// AnySensitiveDataAllowed returns true if the processing of at least one category of Sensitive
+// Data is allowed, applying the comparison of the section's model: in sections that express it
+// as consents, a category is allowed if it is set to Consent, and in sections that express it
+// as opt-outs (e.g. California, Utah, Iowa), if it is set to NotOptedOut. Invalid and Not
+// Applicable values do not allow it in either model. Known child consents are not checked, and
+// a section without Sensitive Data Processing fields returns false.
+func (m *MspaParsedConsent) AnySensitiveDataAllowed() bool {
+	for _, c := range m.SensitiveDataSlice() {
+		if c == Consent {
+			return true
+		}
+	}
+	for _, o := range m.sensitiveDataOptOuts() {
+		if o == NotOptedOut {
+			return true
+		}
+	}
+	return false
+}
+
+// CaliforniaCanSell returns true if the California consent allows the sale of the consumer's
+// Personal Data, i.e. the consumer has not opted out of sale, either with SaleOptOut or GPC.
+// Sale is distinct from sharing, see CaliforniaCanShare.
+func (m *MspaParsedConsent) CaliforniaCanSell() bool {
+	return m.SaleOptOut != OptedOut && !m.Gpc
+}
+
+// CaliforniaCanShare returns true if the California consent allows the sharing of the consumer's
+// Personal Data, i.e. the consumer has not opted out of sharing, either with SharingOptOut or GPC.
+// Under the CCPA, sharing is the disclosure of Personal Data for cross-context behavioral
+// advertising, whether or not for monetary consideration, and is distinct from sale, which is
+// signaled by SaleOptOut. A consumer may opt out of one but not the other.
+func (m *MspaParsedConsent) CaliforniaCanShare() bool {
+	return m.SharingOptOut != OptedOut && !m.Gpc
+}
+
+// TargetedAdvertisingAllowed returns true if the consumer has not opted out of targeted
+// advertising, either with TargetedAdvertisingOptOut or GPC. Invalid and Not Applicable
+// opt-outs do not restrict it, and notices are not checked.
+//
+// GPC signaled as true is an opt-out in the states that honor it as a universal opt-out
+// mechanism. The GPP spec only defines the GPC subsection for US National and the sections of
+// those states, so GPC is honored whenever it is signaled, regardless of the section.
+// California has no targeted advertising fields, so only GPC restricts it; see
+// CaliforniaCanShare for its opt-out of cross-context behavioral advertising.
+func (m *MspaParsedConsent) TargetedAdvertisingAllowed() bool {
+	return m.TargetedAdvertisingOptOut != OptedOut && !m.Gpc
+}
+
+// OptOutSignals returns the consumer's opt-outs keyed by canonical names, so that generic rules
+// can be evaluated over any MSPA section: "sale" (SaleOptOut), "sharing" (SharingOptOut), and
+// "targeted_advertising" (TargetedAdvertisingOptOut). Every name is always included, and
+// opt-outs that a section does not encode, e.g. sharing in most state sections, are Not
+// Applicable. GPC is not folded into the values, see Gpc.
+func (m *MspaParsedConsent) OptOutSignals() map[string]MspaOptout {
+	return map[string]MspaOptout{
+		"sale":                 m.SaleOptOut,
+		"sharing":              m.SharingOptOut,
+		"targeted_advertising": m.TargetedAdvertisingOptOut,
+	}
+}
+
+// RedactedConsent is a minimal form of a MspaParsedConsent that only keeps coarse decisions,
+// which can be logged without retaining the consumer's notices, or their Sensitive Data and
+// Known Child choices.
+type RedactedConsent struct {
+	// The version of the section specification used to encode the string.
+	Version int
+	// The consumer has not opted out of sale, either with SaleOptOut or GPC.
+	CanSell bool
+	// The consumer has not opted out of sharing, either with SharingOptOut or GPC.
+	CanShare bool
+	// The consumer has not opted out of targeted advertising, either with
+	// TargetedAdvertisingOptOut or GPC.
+	CanTargetAdvertising bool
+	// Global Privacy Control (GPC) is signaled and set.
+	Gpc bool
+}
+
+// Redacted returns the RedactedConsent of the parsed consent, for privacy-compliant logging of
+// consent decisions. Opt-outs that are Not Applicable do not restrict their activity.
+func (m *MspaParsedConsent) Redacted() RedactedConsent {
+	return RedactedConsent{
+		Version:              m.Version,
+		CanSell:              m.SaleOptOut != OptedOut && !m.Gpc,
+		CanShare:             m.SharingOptOut != OptedOut && !m.Gpc,
+		CanTargetAdvertising: m.TargetedAdvertisingOptOut != OptedOut && !m.Gpc,
+		Gpc:                  m.Gpc,
+	}
+}
+
+// DecisionKey returns a short, human-readable token of the decisions of the parsed consent,
+// e.g. for keying a cache of ad decisions: whether sale, sharing, targeted advertising, and
+// the processing of any Sensitive Data are allowed, as by Redacted and
+// AnySensitiveDataAllowed, and whether GPC is signaled, e.g.
+// "sale=ok,share=ok,ta=no,sd=no,gpc=1". Consents with the same decisions have the same key,
+// even when their strings differ, e.g. in Not Applicable and Not Opted Out opt-outs. The
+// Section ID is not known to the consent, so decisions of several sections are keyed with
+// the section's name as a prefix, e.g. SectionName(sid) + ":" + m.DecisionKey().
+func (m *MspaParsedConsent) DecisionKey() string {
+	var r = m.Redacted()
+	var gpc = "0"
+	if r.Gpc {
+		gpc = "1"
+	}
+	return "sale=" + decisionKeyValue(r.CanSell) +
+		",share=" + decisionKeyValue(r.CanShare) +
+		",ta=" + decisionKeyValue(r.CanTargetAdvertising) +
+		",sd=" + decisionKeyValue(m.AnySensitiveDataAllowed()) +
+		",gpc=" + gpc
+}
+
+// decisionKeyValue returns the DecisionKey value of whether an activity is allowed.
+func decisionKeyValue(allowed bool) string {
+	if allowed {
+		return "ok"
+	}
+	return "no"
+}
+
+// GpcSource is an enum type of where the Gpc value of a parsed consent was signaled.
+type GpcSource int
+
+const (
+	// GpcSourceNone signals that GPC was not signaled, so Gpc is false.
+	GpcSourceNone GpcSource = iota
+	// GpcSourceSubsection signals that Gpc was read from the GPC subsection.
+	GpcSourceSubsection
+)
+
+// GpcSource returns where the Gpc value of the parsed consent was signaled. GPC is only
+// signaled by the GPC subsection, as no MSPA section defines a GPC field in its core segment,
+// so GpcSourceSubsection is also returned for a consent with Gpc set but GpcPresent unset,
+// which is encoded with a GPC subsection.
+func (m *MspaParsedConsent) GpcSource() GpcSource {
+	if m.GpcPresent || m.Gpc {
+		return GpcSourceSubsection
+	}
+	return GpcSourceNone
+}
+
+// GpcResolution is the result of reconciling the GPC subsection of a consent string with
+// the Global Privacy Control signal sent via the Sec-GPC HTTP header.
+type GpcResolution struct {
+	// The GPC value signaled by the consent string's GPC subsection.
+	ConsentGpc bool
+	// The GPC value signaled by the Sec-GPC HTTP header.
+	HeaderGpc bool
+	// Whether the consent string and header signal the same value.
+	Agree bool
+	// The GPC value that should be honored.
+	Gpc bool
 }
 
+// ReconcileGpc compares the GPC subsection of parsed with the GPC value of the Sec-GPC
+// HTTP header. GPC is a user's opt-out request, and IAB guidance is to honor it whenever it is
+// received, so a true value from either source is honored even if the sources disagree.
+// A nil parsed consent is treated as a consent string without a GPC signal.
+func ReconcileGpc(parsed *MspaParsedConsent, headerGpc bool) GpcResolution {
+	var consentGpc = parsed != nil && parsed.Gpc
+	return GpcResolution{
+		ConsentGpc: consentGpc,
+		HeaderGpc:  headerGpc,
+		Agree:      consentGpc == headerGpc,
+		Gpc:        consentGpc || headerGpc,
+	}
+}
+
+// MspaNotice represents the values of MSPA notice fields.
 type MspaNotice int
 
 const (
+	// NoticeNotApplicable signals that the Business does not perform the activity the notice
+	// is about, so no notice is required. It is distinct from NoticeNotProvided.
 	NoticeNotApplicable MspaNotice = iota
+	// NoticeProvided signals that notice was provided.
 	NoticeProvided
+	// NoticeNotProvided signals that the Business performs the activity, but notice was not provided.
 	NoticeNotProvided
 	InvalidNoticeValue
 )
 
+// IsProvided returns true if notice was provided. Both NoticeNotApplicable and
+// NoticeNotProvided return false, so use AllNoticesProvided to check whether every
+// required notice was provided.
+func (n MspaNotice) IsProvided() bool {
+	return n == NoticeProvided
+}
+
+// AllNoticesProvided returns true if every notice of the parsed consent was either provided,
+// or not applicable. Per IAB guidance, a not applicable notice means that the Business does not
+// perform the activity, so no notice is required. A nil consent returns false.
+func AllNoticesProvided(m *MspaParsedConsent) bool {
+	if m == nil {
+		return false
+	}
+	for _, n := range []MspaNotice{m.SharingNotice, m.SaleOptOutNotice, m.SharingOptOutNotice,
+		m.TargetedAdvertisingOptOutNotice, m.SensitiveDataProcessingOptOutNotice, m.SensitiveDataLimitUseNotice} {
+		if n != NoticeNotApplicable && !n.IsProvided() {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the dependencies between the fields of the parsed consent, which catches
+// CMPs that encode inconsistent values. If an opt-out notice was not provided, the user can not
+// have been given the opportunity to opt out, so the matching opt-out must be Not Applicable.
+// Likewise, if the Sensitive Data Processing opt-out notice was not provided, every Sensitive
+// Data Processing consent or opt-out must be Not Applicable. A transaction that is not a
+// Covered Transaction, see IsMspaCovered, can not be in either MSPA mode, so
+// MspaOptOutOptionMode and MspaServiceProviderMode must not be Yes. The first inconsistency
+// found is returned.
+func (m *MspaParsedConsent) Validate() error {
+	if !m.IsMspaCovered() {
+		if m.MspaOptOutOptionMode == MspaYes {
+			return errors.New("mspa opt-out option mode must not be yes when the transaction is not covered")
+		}
+		if m.MspaServiceProviderMode == MspaYes {
+			return errors.New("mspa service provider mode must not be yes when the transaction is not covered")
+		}
+	}
+	var dependencies = []struct {
+		name   string
+		notice MspaNotice
+		optOut MspaOptout
+	}{
+		{name: "sale", notice: m.SaleOptOutNotice, optOut: m.SaleOptOut},
+		{name: "sharing", notice: m.SharingOptOutNotice, optOut: m.SharingOptOut},
+		{name: "targeted advertising", notice: m.TargetedAdvertisingOptOutNotice, optOut: m.TargetedAdvertisingOptOut},
+	}
+	for _, d := range dependencies {
+		if d.notice == NoticeNotProvided && d.optOut != OptOutNotApplicable {
+			return errors.New(d.name + " opt-out must be not applicable when its notice was not provided, got " +
+				fmt.Sprint(d.optOut))
+		}
+	}
+	if m.SensitiveDataProcessingOptOutNotice != NoticeNotProvided {
+		return nil
+	}
+	for i, c := range m.SensitiveDataSlice() {
+		if c != ConsentNotApplicable {
+			return errors.New("sensitive data processing consent " + fmt.Sprint(i) +
+				" must be not applicable when its opt-out notice was not provided, got " + fmt.Sprint(c))
+		}
+	}
+	var optOuts = m.sensitiveDataOptOuts()
+	var indexes = make([]int, 0, len(optOuts))
+	for i := range optOuts {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		if o := optOuts[i]; o != OptOutNotApplicable {
+			return errors.New("sensitive data processing opt-out " + fmt.Sprint(i) +
+				" must be not applicable when its notice was not provided, got " + fmt.Sprint(o))
+		}
+	}
+	return nil
+}
+
+// NonDefaultFields returns the fields of the parsed consent that are not Not Applicable, or
+// zero, keyed by field name, with their values formatted as numbers, e.g. "SaleOptOut": "1".
+// Sensitive Data Processing and Known Child Sensitive Data fields are keyed by their zero-based
+// index, the same as FieldsForVersion, e.g. "SensitiveDataProcessingConsents[2]". Gpc is only
+// included when it is true, and GpcPresent is never included. This is intended for compact
+// logging, so that fields the CMP did not set do not need to be logged.
+func (m *MspaParsedConsent) NonDefaultFields() map[string]string {
+	var fields = make(map[string]string)
+	var add = func(name string, v int) {
+		if v != 0 {
+			fields[name] = fmt.Sprint(v)
+		}
+	}
+	for _, f := range mspaConsentFields {
+		switch f.Name {
+		case "SensitiveDataProcessingConsents":
+			for i, c := range m.SensitiveDataSlice() {
+				add(f.Name+"["+fmt.Sprint(i)+"]", int(c))
+			}
+		case "SensitiveDataProcessingOptOuts":
+			for i, o := range m.sensitiveDataOptOuts() {
+				add(f.Name+"["+fmt.Sprint(i)+"]", int(o))
+			}
+		case "KnownChildSensitiveDataConsents":
+			for i, c := range m.KnownChildSensitiveDataConsents {
+				add(f.Name+"["+fmt.Sprint(i)+"]", int(c))
+			}
+		case "Gpc":
+			if m.Gpc {
+				fields[f.Name] = "true"
+			}
+		default:
+			add(f.Name, m.fieldValue(f.Name))
+		}
+	}
+	return fields
+}
+
+// KV is the name and formatted value of a field, as returned by MspaParsedConsent.Fields.
+type KV struct {
+	Key   string
+	Value string
+}
+
+// Names of the values of the MSPA enum types, indexed by value.
+var (
+	mspaNoticeNames  = []string{"NotApplicable", "Provided", "NotProvided"}
+	mspaOptOutNames  = []string{"NotApplicable", "OptedOut", "NotOptedOut"}
+	mspaConsentNames = []string{"NotApplicable", "NoConsent", "Consent"}
+	mspaNaYesNoNames = []string{"NotApplicable", "Yes", "No"}
+)
+
+// mspaFieldValueName returns the name of value v of a field of type t, or "Invalid" for values
+// without a name. Int values are formatted as numbers, and Bool values as true or false.
+func mspaFieldValueName(t FieldType, v int) string {
+	var names []string
+	switch t {
+	case FieldTypeInt:
+		return fmt.Sprint(v)
+	case FieldTypeBool:
+		return fmt.Sprint(v != 0)
+	case FieldTypeNotice:
+		names = mspaNoticeNames
+	case FieldTypeOptOut:
+		names = mspaOptOutNames
+	case FieldTypeConsent:
+		names = mspaConsentNames
+	case FieldTypeNaYesNo:
+		names = mspaNaYesNoNames
+	}
+	if v < 0 || v >= len(names) {
+		return "Invalid"
+	}
+	return names[v]
+}
+
+// Fields returns every field of the parsed consent, in the order the spec encodes them, with
+// their values named, e.g. {"SaleOptOut", "OptedOut"}, e.g. to render the consent as a table.
+// Sensitive Data Processing and Known Child Sensitive Data fields are listed by their
+// zero-based index in ascending order, the same as FieldsForVersion, e.g.
+// {"SensitiveDataProcessingConsents[7]", "NoConsent"}. The consent does not know the section
+// it was parsed from, so fields that the section does not encode are listed as NotApplicable;
+// see FieldsForVersion for the fields of a section. Version is formatted as a number, Gpc as
+// true or false, and values that are not defined by the spec as Invalid. GpcPresent is not
+// included.
+func (m *MspaParsedConsent) Fields() []KV {
+	var fields []KV
+	var add = func(key string, t FieldType, v int) {
+		fields = append(fields, KV{Key: key, Value: mspaFieldValueName(t, v)})
+	}
+	for _, f := range mspaConsentFields {
+		switch f.Name {
+		case "SensitiveDataProcessingConsents":
+			for i, c := range m.SensitiveDataSlice() {
+				add(f.Name+"["+fmt.Sprint(i)+"]", f.Type, int(c))
+			}
+		case "SensitiveDataProcessingOptOuts":
+			var optOuts = m.sensitiveDataOptOuts()
+			var indexes = make([]int, 0, len(optOuts))
+			for i := range optOuts {
+				indexes = append(indexes, i)
+			}
+			sort.Ints(indexes)
+			for _, i := range indexes {
+				add(f.Name+"["+fmt.Sprint(i)+"]", f.Type, int(optOuts[i]))
+			}
+		case "KnownChildSensitiveDataConsents":
+			var indexes = make([]int, 0, len(m.KnownChildSensitiveDataConsents))
+			for i := range m.KnownChildSensitiveDataConsents {
+				indexes = append(indexes, i)
+			}
+			sort.Ints(indexes)
+			for _, i := range indexes {
+				add(f.Name+"["+fmt.Sprint(i)+"]", f.Type, int(m.KnownChildSensitiveDataConsents[i]))
+			}
+		default:
+			add(f.Name, f.Type, m.fieldValue(f.Name))
+		}
+	}
+	return fields
+}
+
+// ChildConsent returns the Known Child Sensitive Data consent of the given zero-based bracket,
+// e.g. UsNatChildUnder13, or Not Applicable if the bracket was not decoded. The age bracket of
+// each index is defined by the section the consent was parsed from.
+func (m *MspaParsedConsent) ChildConsent(bracket int) MspaConsent {
+	return m.KnownChildSensitiveDataConsents[bracket]
+}
+
+// HasKnownChildUnder13 returns true if the consent, parsed from the section of the given GPP
+// Section ID, signals that the Business has actual knowledge that it processes the data of a
+// child younger than 13, regardless of whether consent was given. Use ChildConsent to check
+// whether the child's data may be processed. Only the brackets of US National are known, so
+// false is returned for every other section; use InvolvesKnownChild for them.
+func (m *MspaParsedConsent) HasKnownChildUnder13(sid int) bool {
+	var bracket = knownChildUnder13Bracket(sid)
+	return bracket >= 0 && m.ChildConsent(bracket) != ConsentNotApplicable
+}
+
+// InvolvesKnownChild returns true if any Known Child Sensitive Data bracket of the consent is
+// not Not Applicable, which signals that the Business has actual knowledge that it processes
+// the data of a minor, regardless of whether consent was given. Invalid values are treated as
+// a known minor. The number and age of the brackets differ between sections, from a single
+// bracket for most states to 5 brackets, and every one of them is checked. Use
+// HasKnownChildUnder13 to check only for a child younger than 13.
+func (m *MspaParsedConsent) InvolvesKnownChild() bool {
+	for _, c := range m.KnownChildSensitiveDataConsents {
+		if c != ConsentNotApplicable {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEntirelyNotApplicable returns true if every notice, opt-out, and consent field of the
+// parsed consent is Not Applicable, which signals that the CMP asserted the section does not
+// apply to the user at all. The MSPA fields and Gpc describe the transaction rather than the
+// user's choices, so they are not checked.
+func (m *MspaParsedConsent) IsEntirelyNotApplicable() bool {
+	if m.SharingNotice != NoticeNotApplicable ||
+		m.SaleOptOutNotice != NoticeNotApplicable ||
+		m.SharingOptOutNotice != NoticeNotApplicable ||
+		m.TargetedAdvertisingOptOutNotice != NoticeNotApplicable ||
+		m.SensitiveDataProcessingOptOutNotice != NoticeNotApplicable ||
+		m.SensitiveDataLimitUseNotice != NoticeNotApplicable ||
+		m.SaleOptOut != OptOutNotApplicable ||
+		m.SharingOptOut != OptOutNotApplicable ||
+		m.TargetedAdvertisingOptOut != OptOutNotApplicable ||
+		m.PersonalDataConsents != ConsentNotApplicable {
+		return false
+	}
+	for _, c := range m.SensitiveDataSlice() {
+		if c != ConsentNotApplicable {
+			return false
+		}
+	}
+	for _, o := range m.sensitiveDataOptOuts() {
+		if o != OptOutNotApplicable {
+			return false
+		}
+	}
+	for _, c := range m.KnownChildSensitiveDataConsents {
+		if c != ConsentNotApplicable {
+			return false
+		}
+	}
+	return true
+}
+
+// MspaMode is the mode of the Multi-State Privacy Agreement that a transaction is covered under,
+// derived from the MspaCoveredTransaction, MspaOptOutOptionMode, and MspaServiceProviderMode
+// fields.
+type MspaMode int
+
+const (
+	// MspaModeNotCovered is a transaction that is not a Covered Transaction, so the MSPA does
+	// not apply. MspaCoveredTransaction is No, or Not Applicable, which is not a valid value,
+	// and the mode fields are ignored.
+	MspaModeNotCovered MspaMode = iota
+	// MspaModeOptOutOption is a Covered Transaction in Opt-Out Option Mode, in which the user's
+	// opt-outs must be honored.
+	MspaModeOptOutOption
+	// MspaModeServiceProvider is a Covered Transaction in Service Provider Mode, in which
+	// downstream participants process data as service providers.
+	MspaModeServiceProvider
+	// MspaModeUnspecified is a Covered Transaction in which the mode fields do not select
+	// exactly one mode, e.g. both are No or both are Yes.
+	MspaModeUnspecified
+)
+
+// IsMspaCovered returns true if the transaction is a Covered Transaction, so the MSPA applies
+// to it, which is only signaled by MspaCoveredTransaction set to Yes. No, and the Not
+// Applicable and invalid values, which the spec does not define for the field, all mean the
+// MSPA does not apply, in which case MspaOptOutOptionMode and MspaServiceProviderMode are
+// not applicable, and MspaMode returns MspaModeNotCovered.
+func (m *MspaParsedConsent) IsMspaCovered() bool {
+	return m.MspaCoveredTransaction == MspaYes
+}
+
+// MspaMode returns the MSPA mode of the transaction. MspaCoveredTransaction determines whether
+// the MSPA applies at all, and only a Covered Transaction (Yes) has a mode, which is then chosen
+// by whichever of MspaOptOutOptionMode and MspaServiceProviderMode is Yes.
+func (m *MspaParsedConsent) MspaMode() MspaMode {
+	if !m.IsMspaCovered() {
+		return MspaModeNotCovered
+	}
+	switch {
+	case m.MspaOptOutOptionMode == MspaYes && m.MspaServiceProviderMode != MspaYes:
+		return MspaModeOptOutOption
+	case m.MspaServiceProviderMode == MspaYes && m.MspaOptOutOptionMode != MspaYes:
+		return MspaModeServiceProvider
+	default:
+		return MspaModeUnspecified
+	}
+}
+
 type MspaOptout int
 
 const (
@@ -188,3 +879,51 @@ func (r *ConsentReader) ReadMspaNaYesNo() (MspaNaYesNo, error) {
 	var nyn, err = r.ReadInt(2)
 	return MspaNaYesNo(nyn), err
 }
+
+// WriteMspaNotice writes a standard MSPA Notice value into the next 2 bits.
+func (w *ConsentWriter) WriteMspaNotice(n MspaNotice) error {
+	return w.WriteInt(int(n), 2)
+}
+
+// WriteMspaOptOut writes a standard MSPA OptOut value into the next 2 bits.
+func (w *ConsentWriter) WriteMspaOptOut(o MspaOptout) error {
+	return w.WriteInt(int(o), 2)
+}
+
+// WriteMspaConsent writes a standard MSPA Consent value into the next 2 bits.
+func (w *ConsentWriter) WriteMspaConsent(c MspaConsent) error {
+	return w.WriteInt(int(c), 2)
+}
+
+// WriteMspaBitfieldConsent writes l MSPA Consent values from the zero-based map m.
+// Missing indexes are written as ConsentNotApplicable.
+func (w *ConsentWriter) WriteMspaBitfieldConsent(m map[int]MspaConsent, l uint) error {
+	if len(m) > int(l) {
+		return w.fail(errors.New("write mspa bitfield consent: " + fmt.Sprint(len(m)) + " values exceeds length " + fmt.Sprint(l)))
+	}
+	for i := 0; i < int(l); i++ {
+		if err := w.WriteMspaConsent(m[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMspaBitfieldOptOut writes l MSPA OptOut values from the zero-based map m.
+// Missing indexes are written as OptOutNotApplicable.
+func (w *ConsentWriter) WriteMspaBitfieldOptOut(m map[int]MspaOptout, l uint) error {
+	if len(m) > int(l) {
+		return w.fail(errors.New("write mspa bitfield opt out: " + fmt.Sprint(len(m)) + " values exceeds length " + fmt.Sprint(l)))
+	}
+	for i := 0; i < int(l); i++ {
+		if err := w.WriteMspaOptOut(m[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMspaNaYesNo writes a standard MSPA Not Applicable, Yes, No value into the next 2 bits.
+func (w *ConsentWriter) WriteMspaNaYesNo(v MspaNaYesNo) error {
+	return w.WriteInt(int(v), 2)
+}
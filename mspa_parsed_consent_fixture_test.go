@@ -45,6 +45,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaCoveredTransaction:  iabconsent.MspaNotApplicable,
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
+			GpcPresent:              true,
 		},
 		// usnat v1 with true GPC subsection.
 		"BVVqAAEABCA.YA": {
@@ -81,6 +82,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 		// usnat v1 without subsection.
 		"BqqAqqqqqqA": {
@@ -158,11 +160,118 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaYes,
 			MspaServiceProviderMode: iabconsent.MspaYes,
 			Gpc:                     true,
+			GpcPresent:              true,
+		},
+		// usnat v1 with a SensitiveDataLimitUseNotice that differs from the other notices.
+		"BVWqVVVVVaA": {
+			Version:                             1,
+			SharingNotice:                       iabconsent.NoticeProvided,
+			SaleOptOutNotice:                    iabconsent.NoticeProvided,
+			SharingOptOutNotice:                 iabconsent.NoticeProvided,
+			TargetedAdvertisingOptOutNotice:     iabconsent.NoticeProvided,
+			SensitiveDataProcessingOptOutNotice: iabconsent.NoticeProvided,
+			SensitiveDataLimitUseNotice:         iabconsent.NoticeNotProvided,
+			SaleOptOut:                          iabconsent.NotOptedOut,
+			SharingOptOut:                       iabconsent.NotOptedOut,
+			TargetedAdvertisingOptOut:           iabconsent.NotOptedOut,
+			SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{
+				0:  iabconsent.NoConsent,
+				1:  iabconsent.NoConsent,
+				2:  iabconsent.NoConsent,
+				3:  iabconsent.NoConsent,
+				4:  iabconsent.NoConsent,
+				5:  iabconsent.NoConsent,
+				6:  iabconsent.NoConsent,
+				7:  iabconsent.NoConsent,
+				8:  iabconsent.NoConsent,
+				9:  iabconsent.NoConsent,
+				10: iabconsent.NoConsent,
+				11: iabconsent.NoConsent,
+			},
+			KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.NoConsent,
+				1: iabconsent.NoConsent,
+			},
+			PersonalDataConsents:    iabconsent.NoConsent,
+			MspaCoveredTransaction:  iabconsent.MspaYes,
+			MspaOptOutOptionMode:    iabconsent.MspaNo,
+			MspaServiceProviderMode: iabconsent.MspaNo,
+			Gpc:                     false,
+		},
+		// usnat v2 with a SensitiveDataLimitUseNotice that differs from the other notices.
+		"CqpVqqqqqqpU": {
+			Version:                             2,
+			SharingNotice:                       iabconsent.NoticeNotProvided,
+			SaleOptOutNotice:                    iabconsent.NoticeNotProvided,
+			SharingOptOutNotice:                 iabconsent.NoticeNotProvided,
+			TargetedAdvertisingOptOutNotice:     iabconsent.NoticeNotProvided,
+			SensitiveDataProcessingOptOutNotice: iabconsent.NoticeNotProvided,
+			SensitiveDataLimitUseNotice:         iabconsent.NoticeProvided,
+			SaleOptOut:                          iabconsent.OptedOut,
+			SharingOptOut:                       iabconsent.OptedOut,
+			TargetedAdvertisingOptOut:           iabconsent.OptedOut,
+			SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{
+				0:  iabconsent.Consent,
+				1:  iabconsent.Consent,
+				2:  iabconsent.Consent,
+				3:  iabconsent.Consent,
+				4:  iabconsent.Consent,
+				5:  iabconsent.Consent,
+				6:  iabconsent.Consent,
+				7:  iabconsent.Consent,
+				8:  iabconsent.Consent,
+				9:  iabconsent.Consent,
+				10: iabconsent.Consent,
+				11: iabconsent.Consent,
+				12: iabconsent.Consent,
+				13: iabconsent.Consent,
+				14: iabconsent.Consent,
+				15: iabconsent.Consent,
+			},
+			KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.Consent,
+				1: iabconsent.Consent,
+				2: iabconsent.Consent,
+			},
+			PersonalDataConsents:    iabconsent.Consent,
+			MspaCoveredTransaction:  iabconsent.MspaYes,
+			MspaOptOutOptionMode:    iabconsent.MspaYes,
+			MspaServiceProviderMode: iabconsent.MspaYes,
+			Gpc:                     false,
 		},
 	},
 
 	// California
 	iabconsent.UsCaliforniaSID: {
+		// usca where the user has opted out of sharing, but not of sale.
+		"BVmqqpRo": {
+			Version:                     1,
+			SaleOptOutNotice:            iabconsent.NoticeProvided,
+			SharingOptOutNotice:         iabconsent.NoticeProvided,
+			SensitiveDataLimitUseNotice: iabconsent.NoticeProvided,
+			SaleOptOut:                  iabconsent.NotOptedOut,
+			SharingOptOut:               iabconsent.OptedOut,
+			SensitiveDataProcessingOptOuts: map[int]iabconsent.MspaOptout{
+				0: iabconsent.NotOptedOut,
+				1: iabconsent.NotOptedOut,
+				2: iabconsent.NotOptedOut,
+				3: iabconsent.NotOptedOut,
+				4: iabconsent.NotOptedOut,
+				5: iabconsent.NotOptedOut,
+				6: iabconsent.NotOptedOut,
+				7: iabconsent.NotOptedOut,
+				8: iabconsent.NotOptedOut,
+			},
+			KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.NoConsent,
+				1: iabconsent.NoConsent,
+			},
+			PersonalDataConsents:    iabconsent.ConsentNotApplicable,
+			MspaCoveredTransaction:  iabconsent.MspaYes,
+			MspaOptOutOptionMode:    iabconsent.MspaNo,
+			MspaServiceProviderMode: iabconsent.MspaNo,
+			Gpc:                     false,
+		},
 		// usca with subsection of GPC True.
 		"BVoYYZoI.YA": {
 			Version:                     1,
@@ -191,6 +300,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 		// usca without subsection.
 		"BVoYYZoI": {
@@ -224,6 +334,34 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 	},
 	// Virginia
 	iabconsent.UsVirginiaSID: {
+		// usva v1 with a different value in each neighbouring field, so that any misaligned
+		// field offset changes the decoded values, and a subsection of GPC False.
+		"BlaSSZk.QA": {
+			Version:                         1,
+			SharingNotice:                   iabconsent.NoticeNotProvided,
+			SaleOptOutNotice:                iabconsent.NoticeProvided,
+			TargetedAdvertisingOptOutNotice: iabconsent.NoticeProvided,
+			SaleOptOut:                      iabconsent.OptedOut,
+			TargetedAdvertisingOptOut:       iabconsent.NotOptedOut,
+			SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.Consent,
+				1: iabconsent.NoConsent,
+				2: iabconsent.ConsentNotApplicable,
+				3: iabconsent.Consent,
+				4: iabconsent.NoConsent,
+				5: iabconsent.ConsentNotApplicable,
+				6: iabconsent.Consent,
+				7: iabconsent.NoConsent,
+			},
+			KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.Consent,
+			},
+			MspaCoveredTransaction:  iabconsent.MspaYes,
+			MspaOptOutOptionMode:    iabconsent.MspaNo,
+			MspaServiceProviderMode: iabconsent.MspaYes,
+			Gpc:                     false,
+			GpcPresent:              true,
+		},
 		// usva with subsection of GPC True.
 		"BVoYYYI.YA": {
 			Version:                         1,
@@ -249,6 +387,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 		// usva without subsection.
 		"BVoYYYI": {
@@ -328,10 +467,38 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Utah
 	iabconsent.UsUtahSID: {
+		// usut with a different value in each MSPA field, following the known child field.
+		"BVWGGGZA": {
+			Version:                             1,
+			SharingNotice:                       iabconsent.NoticeProvided,
+			SaleOptOutNotice:                    iabconsent.NoticeProvided,
+			TargetedAdvertisingOptOutNotice:     iabconsent.NoticeProvided,
+			SensitiveDataProcessingOptOutNotice: iabconsent.NoticeProvided,
+			SaleOptOut:                          iabconsent.OptedOut,
+			TargetedAdvertisingOptOut:           iabconsent.NotOptedOut,
+			SensitiveDataProcessingOptOuts: map[int]iabconsent.MspaOptout{
+				0: iabconsent.OptOutNotApplicable,
+				1: iabconsent.OptedOut,
+				2: iabconsent.NotOptedOut,
+				3: iabconsent.OptOutNotApplicable,
+				4: iabconsent.OptedOut,
+				5: iabconsent.NotOptedOut,
+				6: iabconsent.OptOutNotApplicable,
+				7: iabconsent.OptedOut,
+			},
+			KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.Consent,
+			},
+			MspaCoveredTransaction:  iabconsent.MspaYes,
+			MspaOptOutOptionMode:    iabconsent.MspaNo,
+			MspaServiceProviderMode: iabconsent.MspaYes,
+			Gpc:                     false,
+		},
 		// usut with subsection of GPC False.
 		"BVaGGGCA.QA": {
 			Version:                             1,
@@ -358,6 +525,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     false,
+			GpcPresent:              true,
 		},
 		// usut with subsection of GPC True.
 		"BVaGGGCA.YA": {
@@ -385,6 +553,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Connecticut
@@ -444,6 +613,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNotApplicable,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Florida
@@ -505,6 +675,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Montana
@@ -566,6 +737,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Oregon
@@ -633,6 +805,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Texas
@@ -690,6 +863,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Delaware
@@ -757,6 +931,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Iowa
@@ -814,6 +989,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaYes,
 			MspaServiceProviderMode: iabconsent.MspaYes,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Nebraska
@@ -871,6 +1047,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// New Hampshire
@@ -932,6 +1109,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// New Jersey
@@ -1001,6 +1179,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 	// Tennessee
@@ -1058,6 +1237,7 @@ var mspaConsentFixtures = map[int]map[string]*iabconsent.MspaParsedConsent{
 			MspaOptOutOptionMode:    iabconsent.MspaNo,
 			MspaServiceProviderMode: iabconsent.MspaNo,
 			Gpc:                     true,
+			GpcPresent:              true,
 		},
 	},
 }
@@ -1,6 +1,9 @@
 package iabconsent_test
 
 import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
 	"strings"
 
 	"github.com/go-check/check"
@@ -247,3 +250,1501 @@ func (s *MspaSuite) TestParseMSPAError(c *check.C) {
 		}
 	}
 }
+
+func (s *MspaSuite) TestParseUsColoradoVersions(c *check.C) {
+	// Only version 1 of the usco section is published, so version 2 is not supported until its
+	// layout is.
+	for _, consentString := range []string{"BVoYYQg", "BVoYYQg.YA"} {
+		c.Log(consentString)
+
+		var p, err = iabconsent.NewMspa(iabconsent.UsColoradoSID, consentString).ParseConsent()
+		c.Assert(err, check.IsNil)
+		var m = p.(*iabconsent.MspaParsedConsent)
+		c.Check(m.Version, check.Equals, 1)
+		c.Check(m.KnownChildSensitiveDataConsents, check.HasLen, 1)
+		c.Check(m.SensitiveDataProcessingConsents, check.HasLen, 7)
+	}
+
+	var p, err = iabconsent.NewMspa(iabconsent.UsColoradoSID, "CVoYYQg").ParseConsent()
+	c.Check(p, check.IsNil)
+	c.Check(err, check.Equals, iabconsent.ErrUnsupportedVersion{SectionID: iabconsent.UsColoradoSID, Version: 2})
+}
+
+func (s *MspaSuite) TestSensitiveCategories(c *check.C) {
+	var tcs = []struct {
+		desc              string
+		sid               int
+		consentString     string
+		expectedOptedOut  []int
+		expectedConsented []int
+	}{
+		{
+			desc:              "California uses opt-outs.",
+			sid:               iabconsent.UsCaliforniaSID,
+			consentString:     "BVoYYZoI",
+			expectedOptedOut:  []int{1, 4, 7},
+			expectedConsented: []int{},
+		},
+		{
+			desc:              "Virginia uses consents.",
+			sid:               iabconsent.UsVirginiaSID,
+			consentString:     "BVoYYYI",
+			expectedOptedOut:  []int{},
+			expectedConsented: []int{2, 5},
+		},
+		{
+			desc:              "US National with no consented categories.",
+			sid:               iabconsent.UsNationalSID,
+			consentString:     "BVVqAAEABCA.QA",
+			expectedOptedOut:  []int{},
+			expectedConsented: []int{},
+		},
+	}
+	for _, t := range tcs {
+		c.Log(t.desc)
+
+		var p = mspaConsentFixtures[t.sid][t.consentString]
+
+		c.Check(p.OptedOutSensitiveCategories(), check.DeepEquals, t.expectedOptedOut)
+		c.Check(p.ConsentedSensitiveCategories(), check.DeepEquals, t.expectedConsented)
+	}
+}
+
+func (s *MspaSuite) TestEncodeMspaSectionRoundTrip(c *check.C) {
+	// Fixtures with non-zero padding bits are encoded with canonical 0 padding.
+	var canonical = map[string]string{
+		"CVVVVVVVVVVW.YA": "CVVVVVVVVVVU.YA",
+	}
+	for sid, sections := range mspaConsentFixtures {
+		for section := range sections {
+			c.Log(section)
+
+			var p, err = iabconsent.NewMspa(sid, section).ParseConsent()
+			c.Check(err, check.IsNil)
+
+			var encoded string
+			encoded, err = iabconsent.EncodeMspaSection(sid, p.(*iabconsent.MspaParsedConsent))
+			c.Check(err, check.IsNil)
+			var expected, ok = canonical[section]
+			if !ok {
+				expected = section
+			}
+			c.Check(encoded, check.Equals, expected)
+
+			var buf bytes.Buffer
+			c.Check(iabconsent.EncodeMspaTo(&buf, sid, p.(*iabconsent.MspaParsedConsent)), check.IsNil)
+			c.Check(buf.String(), check.Equals, expected)
+		}
+	}
+}
+
+func (s *MspaSuite) TestEncodeMspaTo(c *check.C) {
+	// Consecutive strings are written without building each one.
+	var buf bytes.Buffer
+	for _, gpc := range []bool{false, true} {
+		var p = &iabconsent.MspaParsedConsent{Version: 1, SaleOptOut: iabconsent.OptedOut, Gpc: gpc}
+		c.Assert(iabconsent.EncodeMspaTo(&buf, iabconsent.UsVirginiaSID, p), check.IsNil)
+		buf.WriteString("\n")
+	}
+	c.Check(buf.String(), check.Equals, "BAQAAAA\nBAQAAAA.YA\n")
+
+	var err = iabconsent.EncodeMspaTo(failingWriter{}, iabconsent.UsVirginiaSID, &iabconsent.MspaParsedConsent{Version: 1})
+	c.Check(err, check.ErrorMatches, "write mspa consent string: failed")
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func (s *MspaSuite) TestEncodeMspaSectionError(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		sid      int
+		consent  *iabconsent.MspaParsedConsent
+		expected string
+	}{
+		{
+			desc:     "Unsupported Section ID.",
+			sid:      2,
+			consent:  &iabconsent.MspaParsedConsent{Version: 1},
+			expected: "unsupported section id: 2",
+		},
+		{
+			desc:     "Unsupported Version.",
+			sid:      iabconsent.UsCaliforniaSID,
+			consent:  &iabconsent.MspaParsedConsent{Version: 2},
+			expected: "unsupported version: 2",
+		},
+		{
+			desc:     "Invalid Value.",
+			sid:      iabconsent.UsVirginiaSID,
+			consent:  &iabconsent.MspaParsedConsent{Version: 1, SaleOptOut: 4},
+			expected: "encode mspa consent string: write int: value 4 overflows 2 bits",
+		},
+		{
+			desc: "Too Many Sensitive Data Values.",
+			sid:  iabconsent.UsColoradoSID,
+			consent: &iabconsent.MspaParsedConsent{
+				Version:                         1,
+				SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{0: 0, 1: 0, 2: 0, 3: 0, 4: 0, 5: 0, 6: 0, 7: 0},
+			},
+			expected: "encode mspa consent string: write mspa bitfield consent: 8 values exceeds length 7",
+		},
+	}
+	for _, t := range tcs {
+		c.Log(t.desc)
+
+		var encoded, err = iabconsent.EncodeMspaSection(t.sid, t.consent)
+
+		c.Check(encoded, check.Equals, "")
+		c.Check(err, check.ErrorMatches, t.expected)
+
+		var buf bytes.Buffer
+		c.Check(iabconsent.EncodeMspaTo(&buf, t.sid, t.consent), check.ErrorMatches, t.expected)
+		c.Check(buf.Len(), check.Equals, 0)
+	}
+
+	// An unsupported version is returned as the same typed error as by the parsers.
+	var _, err = iabconsent.EncodeMspaSection(iabconsent.UsCaliforniaSID, &iabconsent.MspaParsedConsent{Version: 2})
+	c.Check(err, check.Equals, iabconsent.ErrUnsupportedVersion{SectionID: iabconsent.UsCaliforniaSID, Version: 2})
+}
+
+func (s *MspaSuite) TestReconcileGpc(c *check.C) {
+	var tcs = []struct {
+		desc      string
+		parsed    *iabconsent.MspaParsedConsent
+		headerGpc bool
+		expected  iabconsent.GpcResolution
+	}{
+		{
+			desc:      "Both false.",
+			parsed:    mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+			headerGpc: false,
+			expected:  iabconsent.GpcResolution{ConsentGpc: false, HeaderGpc: false, Agree: true, Gpc: false},
+		},
+		{
+			desc:      "Both true.",
+			parsed:    mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.YA"],
+			headerGpc: true,
+			expected:  iabconsent.GpcResolution{ConsentGpc: true, HeaderGpc: true, Agree: true, Gpc: true},
+		},
+		{
+			desc:      "Only header true.",
+			parsed:    mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+			headerGpc: true,
+			expected:  iabconsent.GpcResolution{ConsentGpc: false, HeaderGpc: true, Agree: false, Gpc: true},
+		},
+		{
+			desc:      "Only consent string true.",
+			parsed:    mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.YA"],
+			headerGpc: false,
+			expected:  iabconsent.GpcResolution{ConsentGpc: true, HeaderGpc: false, Agree: false, Gpc: true},
+		},
+		{
+			desc:      "No consent string, header true.",
+			parsed:    nil,
+			headerGpc: true,
+			expected:  iabconsent.GpcResolution{ConsentGpc: false, HeaderGpc: true, Agree: false, Gpc: true},
+		},
+	}
+	for _, t := range tcs {
+		c.Log(t.desc)
+
+		c.Check(iabconsent.ReconcileGpc(t.parsed, t.headerGpc), check.Equals, t.expected)
+	}
+}
+
+func (s *MspaSuite) TestGpcSource(c *check.C) {
+	// "BVVqAAEABCg" is "BVVqAAEABCA" with the first padding bit after the core fields set, and
+	// "CVVVVVVVVVVW" is "CVVVVVVVVVVU" with it set. Padding never signals GPC.
+	var tcs = []struct {
+		desc     string
+		section  string
+		gpc      bool
+		expected iabconsent.GpcSource
+	}{
+		{desc: "No GPC.", section: "BVVqAAEABCA", gpc: false, expected: iabconsent.GpcSourceNone},
+		{desc: "Subsection.", section: "BVVqAAEABCA.YA", gpc: true, expected: iabconsent.GpcSourceSubsection},
+		{desc: "Subsection false.", section: "BVVqAAEABCA.QA", gpc: false, expected: iabconsent.GpcSourceSubsection},
+		{desc: "Padding bit set.", section: "BVVqAAEABCg", gpc: false, expected: iabconsent.GpcSourceNone},
+		{desc: "Padding bit set v2.", section: "CVVVVVVVVVVW", gpc: false, expected: iabconsent.GpcSourceNone},
+		{desc: "Padding bit set and subsection false.", section: "BVVqAAEABCg.QA", gpc: false, expected: iabconsent.GpcSourceSubsection},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var parsed, err = iabconsent.NewMspa(iabconsent.UsNationalSID, tc.section).ParseConsent()
+		c.Assert(err, check.IsNil)
+		var compact, lazy *iabconsent.MspaParsedConsent
+		compact, err = iabconsent.ParseMspaCompact(iabconsent.UsNationalSID, tc.section)
+		c.Assert(err, check.IsNil)
+		lazy, err = iabconsent.ParseMspaLazy(iabconsent.UsNationalSID, tc.section)
+		c.Assert(err, check.IsNil)
+		for _, p := range []*iabconsent.MspaParsedConsent{parsed.(*iabconsent.MspaParsedConsent), compact, lazy} {
+			c.Check(p.Gpc, check.Equals, tc.gpc)
+			c.Check(p.GpcSource(), check.Equals, tc.expected)
+		}
+	}
+
+	// A consent built with Gpc set is encoded with a GPC subsection.
+	var built = &iabconsent.MspaParsedConsent{Gpc: true}
+	c.Check(built.GpcSource(), check.Equals, iabconsent.GpcSourceSubsection)
+}
+
+func (s *MspaSuite) TestSensitiveDataCategoryName(c *check.C) {
+	var tcs = []struct {
+		sid      int
+		index    int
+		expected string
+	}{
+		{sid: iabconsent.UsNationalSID, index: 0, expected: "racial or ethnic origin"},
+		{sid: iabconsent.UsNationalSID, index: 7, expected: "precise geolocation"},
+		{sid: iabconsent.UsCaliforniaSID, index: 7, expected: "health information"},
+		{sid: iabconsent.UsColoradoSID, index: 6, expected: "biometric unique identification"},
+		{sid: iabconsent.UsColoradoSID, index: 7, expected: ""},
+		{sid: iabconsent.UsUtahSID, index: -1, expected: ""},
+		{sid: 2, index: 0, expected: ""},
+	}
+	for _, t := range tcs {
+		c.Log(t)
+
+		c.Check(iabconsent.SensitiveDataCategoryName(t.sid, t.index), check.Equals, t.expected)
+	}
+}
+
+func (s *MspaSuite) TestSensitiveDataCategoryNameCoversFixtures(c *check.C) {
+	// Every decoded Sensitive Data index must have a category name.
+	for sid, sections := range mspaConsentFixtures {
+		for section, p := range sections {
+			c.Log(section)
+
+			var n = len(p.SensitiveDataProcessingConsents) + len(p.SensitiveDataProcessingOptOuts)
+			c.Check(iabconsent.SensitiveDataCategoryName(sid, n-1), check.Not(check.Equals), "")
+			if sid != iabconsent.UsNationalSID {
+				c.Check(iabconsent.SensitiveDataCategoryName(sid, n), check.Equals, "")
+			}
+		}
+	}
+}
+
+func (s *MspaSuite) TestMspaNoticeIsProvided(c *check.C) {
+	c.Check(iabconsent.NoticeNotApplicable.IsProvided(), check.Equals, false)
+	c.Check(iabconsent.NoticeProvided.IsProvided(), check.Equals, true)
+	c.Check(iabconsent.NoticeNotProvided.IsProvided(), check.Equals, false)
+	c.Check(iabconsent.InvalidNoticeValue.IsProvided(), check.Equals, false)
+}
+
+func (s *MspaSuite) TestAllNoticesProvided(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		consent  *iabconsent.MspaParsedConsent
+		expected bool
+	}{
+		{
+			desc:     "Nil consent.",
+			consent:  nil,
+			expected: false,
+		},
+		{
+			desc:     "All notices not applicable.",
+			consent:  &iabconsent.MspaParsedConsent{},
+			expected: true,
+		},
+		{
+			desc: "Provided and not applicable notices.",
+			consent: &iabconsent.MspaParsedConsent{
+				SharingNotice:    iabconsent.NoticeProvided,
+				SaleOptOutNotice: iabconsent.NoticeNotApplicable,
+			},
+			expected: true,
+		},
+		{
+			desc: "Provided and not provided notices.",
+			consent: &iabconsent.MspaParsedConsent{
+				SharingNotice:    iabconsent.NoticeProvided,
+				SaleOptOutNotice: iabconsent.NoticeNotProvided,
+			},
+			expected: false,
+		},
+		{
+			desc: "Not applicable and not provided notices.",
+			consent: &iabconsent.MspaParsedConsent{
+				SensitiveDataLimitUseNotice: iabconsent.NoticeNotProvided,
+			},
+			expected: false,
+		},
+		{
+			desc: "Invalid notice.",
+			consent: &iabconsent.MspaParsedConsent{
+				TargetedAdvertisingOptOutNotice: iabconsent.InvalidNoticeValue,
+			},
+			expected: false,
+		},
+		{
+			desc:     "Fixture with every notice provided.",
+			consent:  mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+			expected: true,
+		},
+		{
+			desc:     "Fixture with every notice not provided.",
+			consent:  mspaConsentFixtures[iabconsent.UsNationalSID]["BqqAqqqqqqA"],
+			expected: false,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+		c.Check(iabconsent.AllNoticesProvided(tc.consent), check.Equals, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestSensitiveDataCount(c *check.C) {
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, expected := range fixtures {
+			c.Log(sid, k)
+
+			var n = iabconsent.MspaSensitiveDataCount(sid, expected.Version)
+			c.Check(n > 0, check.Equals, true)
+			c.Check(expected.SensitiveDataCount(), check.Equals, n)
+		}
+	}
+
+	c.Check(iabconsent.MspaSensitiveDataCount(iabconsent.UsNationalSID, 1), check.Equals, 12)
+	c.Check(iabconsent.MspaSensitiveDataCount(iabconsent.UsNationalSID, 2), check.Equals, 16)
+	c.Check(iabconsent.MspaSensitiveDataCount(iabconsent.UsCaliforniaSID, 2), check.Equals, 0)
+	c.Check(iabconsent.MspaSensitiveDataCount(2, 1), check.Equals, 0)
+}
+
+func (s *MspaSuite) TestValidate(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		consent  *iabconsent.MspaParsedConsent
+		expected string
+	}{
+		{
+			desc: "Sale opt-out without sale notice.",
+			consent: &iabconsent.MspaParsedConsent{
+				SaleOptOutNotice: iabconsent.NoticeNotProvided,
+				SaleOptOut:       iabconsent.OptedOut,
+			},
+			expected: "sale opt-out must be not applicable when its notice was not provided, got 1",
+		},
+		{
+			desc: "Sharing opt-out without sharing notice.",
+			consent: &iabconsent.MspaParsedConsent{
+				SaleOptOutNotice:    iabconsent.NoticeProvided,
+				SaleOptOut:          iabconsent.NotOptedOut,
+				SharingOptOutNotice: iabconsent.NoticeNotProvided,
+				SharingOptOut:       iabconsent.NotOptedOut,
+			},
+			expected: "sharing opt-out must be not applicable when its notice was not provided, got 2",
+		},
+		{
+			desc: "Targeted advertising opt-out without targeted advertising notice.",
+			consent: &iabconsent.MspaParsedConsent{
+				TargetedAdvertisingOptOutNotice: iabconsent.NoticeNotProvided,
+				TargetedAdvertisingOptOut:       iabconsent.OptedOut,
+			},
+			expected: "targeted advertising opt-out must be not applicable when its notice was not provided, got 1",
+		},
+		{
+			desc: "Sensitive data consent without sensitive data opt-out notice.",
+			consent: &iabconsent.MspaParsedConsent{
+				SensitiveDataProcessingOptOutNotice: iabconsent.NoticeNotProvided,
+				SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{
+					0: iabconsent.ConsentNotApplicable, 1: iabconsent.ConsentNotApplicable, 2: iabconsent.NoConsent},
+			},
+			expected: "sensitive data processing consent 2 must be not applicable when its opt-out notice was not provided, got 1",
+		},
+		{
+			desc: "Parsed US National sensitive data consent without sensitive data opt-out notice.",
+			consent: mustParseMspa(c, iabconsent.UsNationalSID, iabconsent.NewMspaConsentBuilder().
+				SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeNotProvided).
+				SetSensitiveDataConsent(11, iabconsent.Consent)),
+			expected: "sensitive data processing consent 11 must be not applicable when its opt-out notice was not provided, got 2",
+		},
+		{
+			desc: "Utah sensitive data opt-out without sensitive data opt-out notice.",
+			consent: &iabconsent.MspaParsedConsent{
+				SensitiveDataProcessingOptOutNotice: iabconsent.NoticeNotProvided,
+				SensitiveDataProcessingOptOuts: map[int]iabconsent.MspaOptout{
+					0: iabconsent.OptOutNotApplicable, 3: iabconsent.OptedOut, 5: iabconsent.NotOptedOut},
+			},
+			expected: "sensitive data processing opt-out 3 must be not applicable when its notice was not provided, got 1",
+		},
+		{
+			desc: "Opt-Out Option Mode without a Covered Transaction.",
+			consent: &iabconsent.MspaParsedConsent{
+				MspaCoveredTransaction:  iabconsent.MspaNo,
+				MspaOptOutOptionMode:    iabconsent.MspaYes,
+				MspaServiceProviderMode: iabconsent.MspaNo,
+			},
+			expected: "mspa opt-out option mode must not be yes when the transaction is not covered",
+		},
+		{
+			desc: "Service Provider Mode with a Not Applicable Covered Transaction.",
+			consent: &iabconsent.MspaParsedConsent{
+				MspaCoveredTransaction:  iabconsent.MspaNotApplicable,
+				MspaServiceProviderMode: iabconsent.MspaYes,
+			},
+			expected: "mspa service provider mode must not be yes when the transaction is not covered",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+		c.Check(tc.consent.Validate(), check.ErrorMatches, tc.expected)
+	}
+
+	var valid = []*iabconsent.MspaParsedConsent{
+		{},
+		{
+			SaleOptOutNotice:                iabconsent.NoticeNotProvided,
+			SharingOptOutNotice:             iabconsent.NoticeNotProvided,
+			TargetedAdvertisingOptOutNotice: iabconsent.NoticeNotProvided,
+		},
+		{
+			SaleOptOutNotice:                iabconsent.NoticeProvided,
+			SaleOptOut:                      iabconsent.OptedOut,
+			SharingOptOutNotice:             iabconsent.NoticeProvided,
+			SharingOptOut:                   iabconsent.NotOptedOut,
+			TargetedAdvertisingOptOutNotice: iabconsent.NoticeProvided,
+			TargetedAdvertisingOptOut:       iabconsent.OptedOut,
+		},
+		mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"],
+		{
+			SensitiveDataProcessingOptOutNotice: iabconsent.NoticeNotProvided,
+			SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.ConsentNotApplicable, 1: iabconsent.ConsentNotApplicable},
+		},
+		{
+			SensitiveDataProcessingOptOutNotice: iabconsent.NoticeProvided,
+			SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.Consent, 1: iabconsent.NoConsent},
+		},
+		mustParseMspa(c, iabconsent.UsNationalSID, iabconsent.NewMspaConsentBuilder().
+			SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeNotProvided)),
+		{
+			MspaCoveredTransaction:  iabconsent.MspaNo,
+			MspaOptOutOptionMode:    iabconsent.MspaNo,
+			MspaServiceProviderMode: iabconsent.MspaNo,
+		},
+		{
+			MspaCoveredTransaction:  iabconsent.MspaYes,
+			MspaOptOutOptionMode:    iabconsent.MspaYes,
+			MspaServiceProviderMode: iabconsent.MspaNo,
+		},
+	}
+	for _, v := range valid {
+		c.Check(v.Validate(), check.IsNil)
+	}
+}
+
+func (s *MspaSuite) TestGpcPresent(c *check.C) {
+	var tcs = []struct {
+		consent    string
+		gpc        bool
+		gpcPresent bool
+	}{
+		{consent: "BVVqAAEABCA.YA", gpc: true, gpcPresent: true},
+		{consent: "BVVqAAEABCA.QA", gpc: false, gpcPresent: true},
+		{consent: "BVVqAAEABCA", gpc: false, gpcPresent: false},
+	}
+	for _, tc := range tcs {
+		c.Log(tc)
+
+		var p, err = iabconsent.NewMspa(iabconsent.UsNationalSID, tc.consent).ParseConsent()
+		c.Assert(err, check.IsNil)
+		var m = p.(*iabconsent.MspaParsedConsent)
+		c.Check(m.Gpc, check.Equals, tc.gpc)
+		c.Check(m.GpcPresent, check.Equals, tc.gpcPresent)
+
+		// All three are kept when encoding.
+		var encoded string
+		encoded, err = iabconsent.EncodeMspaSection(iabconsent.UsNationalSID, m)
+		c.Check(err, check.IsNil)
+		c.Check(encoded, check.Equals, tc.consent)
+	}
+}
+
+func (s *MspaSuite) TestFieldsForVersion(c *check.C) {
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, expected := range fixtures {
+			c.Log(sid, k)
+
+			// Every field following the 6 bit Version is 2 bits, besides Gpc, and the core
+			// segment is padded to whole bytes.
+			var fields = iabconsent.FieldsForVersion(sid, expected.Version)
+			c.Assert(fields, check.Not(check.HasLen), 0)
+			c.Check(fields[0], check.Equals, "Version")
+			c.Check(fields[len(fields)-1], check.Equals, "Gpc")
+			var b, err = base64.RawURLEncoding.DecodeString(strings.Split(k, ".")[0])
+			c.Assert(err, check.IsNil)
+			c.Check(len(b), check.Equals, (6+2*(len(fields)-2)+7)/8)
+		}
+	}
+
+	var v1 = iabconsent.FieldsForVersion(iabconsent.UsNationalSID, 1)
+	var v2 = iabconsent.FieldsForVersion(iabconsent.UsNationalSID, 2)
+	c.Check(v2, check.HasLen, len(v1)+5)
+	c.Check(v1, check.Not(check.DeepEquals), v2)
+	c.Check(v2[25], check.Equals, "SensitiveDataProcessingConsents[15]")
+	c.Check(iabconsent.FieldsForVersion(iabconsent.UsCaliforniaSID, 1)[6], check.Equals, "SensitiveDataProcessingOptOuts[0]")
+	c.Check(iabconsent.FieldsForVersion(iabconsent.UsCaliforniaSID, 2), check.IsNil)
+	c.Check(iabconsent.FieldsForVersion(2, 1), check.IsNil)
+}
+
+func (s *MspaSuite) TestNonDefaultFields(c *check.C) {
+	var expected = map[string]string{
+		"Version":                            "1",
+		"SharingNotice":                      "1",
+		"SaleOptOutNotice":                   "1",
+		"TargetedAdvertisingOptOutNotice":    "1",
+		"SaleOptOut":                         "2",
+		"TargetedAdvertisingOptOut":          "2",
+		"SensitiveDataProcessingConsents[1]": "1",
+		"SensitiveDataProcessingConsents[2]": "2",
+		"SensitiveDataProcessingConsents[4]": "1",
+		"SensitiveDataProcessingConsents[5]": "2",
+		"KnownChildSensitiveDataConsents[0]": "1",
+		"MspaServiceProviderMode":            "2",
+		"Gpc":                                "true",
+	}
+	var p, err = iabconsent.NewMspa(iabconsent.UsColoradoSID, "BVoYYQg.YA").ParseConsent()
+	c.Assert(err, check.IsNil)
+	c.Check(p.(*iabconsent.MspaParsedConsent).NonDefaultFields(), check.DeepEquals, expected)
+
+	var compact *iabconsent.MspaParsedConsent
+	compact, err = iabconsent.ParseMspaCompact(iabconsent.UsColoradoSID, "BVoYYQg.YA")
+	c.Assert(err, check.IsNil)
+	c.Check(compact.NonDefaultFields(), check.DeepEquals, expected)
+
+	c.Check((&iabconsent.MspaParsedConsent{}).NonDefaultFields(), check.HasLen, 0)
+
+	// Every non-default field is one of the fields encoded by the section.
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, m := range fixtures {
+			c.Log(sid, k)
+
+			var encoded = make(map[string]bool)
+			for _, f := range iabconsent.FieldsForVersion(sid, m.Version) {
+				encoded[f] = true
+			}
+			for f := range m.NonDefaultFields() {
+				c.Check(encoded[f], check.Equals, true, check.Commentf("%s is not encoded", f))
+			}
+		}
+	}
+}
+
+func (s *MspaSuite) TestCaliforniaCanSellAndShare(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		consent  string
+		canSell  bool
+		canShare bool
+	}{
+		{
+			desc:     "Opted out of sharing, but not of sale.",
+			consent:  "BVmqqpRo",
+			canSell:  true,
+			canShare: false,
+		},
+		{
+			desc:     "Not opted out of sale or sharing.",
+			consent:  "BVoYYZoI",
+			canSell:  true,
+			canShare: true,
+		},
+		{
+			desc:     "Not opted out of sale or sharing, with GPC.",
+			consent:  "BVoYYZoI.YA",
+			canSell:  false,
+			canShare: false,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p = mspaConsentFixtures[iabconsent.UsCaliforniaSID][tc.consent]
+		c.Check(p.CaliforniaCanSell(), check.Equals, tc.canSell)
+		c.Check(p.CaliforniaCanShare(), check.Equals, tc.canShare)
+	}
+}
+
+func (s *MspaSuite) TestTargetedAdvertisingAllowed(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		sid      int
+		consent  string
+		expected bool
+	}{
+		{
+			desc:     "US National, not opted out.",
+			sid:      iabconsent.UsNationalSID,
+			consent:  "BAQCAAAAAAA.QA",
+			expected: true,
+		},
+		{
+			desc:     "US National, opted out.",
+			sid:      iabconsent.UsNationalSID,
+			consent:  "BAQBAAAAAAA.QA",
+			expected: false,
+		},
+		{
+			desc:     "US National, not opted out, with GPC.",
+			sid:      iabconsent.UsNationalSID,
+			consent:  "BAQCAAAAAAA.YA",
+			expected: false,
+		},
+		{
+			desc:     "US National, not opted out, with a non-zero padding bit.",
+			sid:      iabconsent.UsNationalSID,
+			consent:  "BVVqAAEABCg",
+			expected: true,
+		},
+		{
+			desc:     "Colorado, opt-out Not Applicable.",
+			sid:      iabconsent.UsColoradoSID,
+			consent:  "BBAAAAA.QA",
+			expected: true,
+		},
+		{
+			desc:     "Colorado, opted out.",
+			sid:      iabconsent.UsColoradoSID,
+			consent:  "BBEAAAA.QA",
+			expected: false,
+		},
+		{
+			desc:     "Colorado, not opted out, with GPC.",
+			sid:      iabconsent.UsColoradoSID,
+			consent:  "BBIAAAA.YA",
+			expected: false,
+		},
+		{
+			desc:     "Virginia, not opted out, without GPC.",
+			sid:      iabconsent.UsVirginiaSID,
+			consent:  "BBIAAAA",
+			expected: true,
+		},
+		{
+			desc:     "California, with no targeted advertising fields.",
+			sid:      iabconsent.UsCaliforniaSID,
+			consent:  "BVmqqpRo",
+			expected: true,
+		},
+		{
+			desc:     "California, with GPC.",
+			sid:      iabconsent.UsCaliforniaSID,
+			consent:  "BVoYYZoI.YA",
+			expected: false,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.NewMspa(tc.sid, tc.consent).ParseConsent()
+		c.Assert(err, check.IsNil)
+		c.Check(p.(*iabconsent.MspaParsedConsent).TargetedAdvertisingAllowed(), check.Equals, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestAnySensitiveDataAllowed(c *check.C) {
+	var optedOut = iabconsent.NewMspaConsentBuilder().SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeProvided)
+	for i := 0; i < 9; i++ {
+		optedOut.SetSensitiveDataOptOut(i, iabconsent.OptedOut)
+	}
+	var tcs = []struct {
+		desc     string
+		sid      int
+		builder  *iabconsent.MspaConsentBuilder
+		expected bool
+	}{
+		{
+			desc:     "US National, no consents.",
+			sid:      iabconsent.UsNationalSID,
+			builder:  iabconsent.NewMspaConsentBuilder(),
+			expected: false,
+		},
+		{
+			desc: "US National, no consent for any category.",
+			sid:  iabconsent.UsNationalSID,
+			builder: iabconsent.NewMspaConsentBuilder().
+				SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeProvided).
+				SetSensitiveDataConsent(0, iabconsent.NoConsent).
+				SetSensitiveDataConsent(11, iabconsent.NoConsent),
+			expected: false,
+		},
+		{
+			desc: "US National, consent for the last category.",
+			sid:  iabconsent.UsNationalSID,
+			builder: iabconsent.NewMspaConsentBuilder().
+				SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeProvided).
+				SetSensitiveDataConsent(0, iabconsent.NoConsent).
+				SetSensitiveDataConsent(11, iabconsent.Consent),
+			expected: true,
+		},
+		{
+			desc: "Virginia, consent for one category.",
+			sid:  iabconsent.UsVirginiaSID,
+			builder: iabconsent.NewMspaConsentBuilder().
+				SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeProvided).
+				SetSensitiveDataConsent(3, iabconsent.Consent),
+			expected: true,
+		},
+		{
+			desc:     "California, opt-outs Not Applicable.",
+			sid:      iabconsent.UsCaliforniaSID,
+			builder:  iabconsent.NewMspaConsentBuilder(),
+			expected: false,
+		},
+		{
+			desc:     "Utah, opt-outs Not Applicable.",
+			sid:      iabconsent.UsUtahSID,
+			builder:  iabconsent.NewMspaConsentBuilder(),
+			expected: false,
+		},
+		{
+			desc:     "California, opted out of every category.",
+			sid:      iabconsent.UsCaliforniaSID,
+			builder:  optedOut,
+			expected: false,
+		},
+		{
+			desc: "California, not opted out of one category.",
+			sid:  iabconsent.UsCaliforniaSID,
+			builder: iabconsent.NewMspaConsentBuilder().
+				SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeProvided).
+				SetSensitiveDataOptOut(0, iabconsent.OptedOut).
+				SetSensitiveDataOptOut(8, iabconsent.NotOptedOut),
+			expected: true,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var section, err = tc.builder.Build(tc.sid)
+		c.Assert(err, check.IsNil)
+		c.Check(mustParseMspa(c, tc.sid, tc.builder).AnySensitiveDataAllowed(), check.Equals, tc.expected)
+
+		// The rollup is the same for the compact and lazy representations.
+		var m *iabconsent.MspaParsedConsent
+		m, err = iabconsent.ParseMspaCompact(tc.sid, section)
+		c.Assert(err, check.IsNil)
+		c.Check(m.AnySensitiveDataAllowed(), check.Equals, tc.expected)
+		m, err = iabconsent.ParseMspaLazy(tc.sid, section)
+		c.Assert(err, check.IsNil)
+		c.Check(m.AnySensitiveDataAllowed(), check.Equals, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestFields(c *check.C) {
+	var p, err = iabconsent.NewMspa(iabconsent.UsVirginiaSID, "BVoYYYI").ParseConsent()
+	c.Assert(err, check.IsNil)
+	c.Check(p.(*iabconsent.MspaParsedConsent).Fields(), check.DeepEquals, []iabconsent.KV{
+		{Key: "Version", Value: "1"},
+		{Key: "SharingNotice", Value: "Provided"},
+		{Key: "SaleOptOutNotice", Value: "Provided"},
+		{Key: "SharingOptOutNotice", Value: "NotApplicable"},
+		{Key: "TargetedAdvertisingOptOutNotice", Value: "Provided"},
+		{Key: "SensitiveDataProcessingOptOutNotice", Value: "NotApplicable"},
+		{Key: "SensitiveDataLimitUseNotice", Value: "NotApplicable"},
+		{Key: "SaleOptOut", Value: "NotOptedOut"},
+		{Key: "SharingOptOut", Value: "NotApplicable"},
+		{Key: "TargetedAdvertisingOptOut", Value: "NotOptedOut"},
+		{Key: "SensitiveDataProcessingConsents[0]", Value: "NotApplicable"},
+		{Key: "SensitiveDataProcessingConsents[1]", Value: "NoConsent"},
+		{Key: "SensitiveDataProcessingConsents[2]", Value: "Consent"},
+		{Key: "SensitiveDataProcessingConsents[3]", Value: "NotApplicable"},
+		{Key: "SensitiveDataProcessingConsents[4]", Value: "NoConsent"},
+		{Key: "SensitiveDataProcessingConsents[5]", Value: "Consent"},
+		{Key: "SensitiveDataProcessingConsents[6]", Value: "NotApplicable"},
+		{Key: "SensitiveDataProcessingConsents[7]", Value: "NoConsent"},
+		{Key: "KnownChildSensitiveDataConsents[0]", Value: "Consent"},
+		{Key: "PersonalDataConsents", Value: "NotApplicable"},
+		{Key: "MspaCoveredTransaction", Value: "NotApplicable"},
+		{Key: "MspaOptOutOptionMode", Value: "NotApplicable"},
+		{Key: "MspaServiceProviderMode", Value: "No"},
+		{Key: "Gpc", Value: "false"},
+	})
+
+	// The fields of every section are listed in the order of FieldsForVersion.
+	for sid, fixtures := range mspaConsentFixtures {
+		for section, m := range fixtures {
+			c.Log(section)
+
+			var names = iabconsent.FieldsForVersion(sid, m.Version)
+			var i = 0
+			for _, kv := range m.Fields() {
+				if i < len(names) && kv.Key == names[i] {
+					i++
+				}
+			}
+			c.Check(i, check.Equals, len(names))
+		}
+	}
+
+	var invalid = &iabconsent.MspaParsedConsent{
+		Version:                         1,
+		SaleOptOut:                      iabconsent.InvalidOptOutValue,
+		SensitiveDataProcessingOptOuts:  map[int]iabconsent.MspaOptout{10: iabconsent.OptedOut, 2: iabconsent.NotOptedOut},
+		KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{0: iabconsent.InvalidConsentValue},
+		Gpc:                             true,
+	}
+	var fields = invalid.Fields()
+	c.Check(fields[7], check.Equals, iabconsent.KV{Key: "SaleOptOut", Value: "Invalid"})
+	c.Check(fields[10:13], check.DeepEquals, []iabconsent.KV{
+		{Key: "SensitiveDataProcessingOptOuts[2]", Value: "NotOptedOut"},
+		{Key: "SensitiveDataProcessingOptOuts[10]", Value: "OptedOut"},
+		{Key: "KnownChildSensitiveDataConsents[0]", Value: "Invalid"},
+	})
+	c.Check(fields[len(fields)-1], check.Equals, iabconsent.KV{Key: "Gpc", Value: "true"})
+}
+
+func (s *MspaSuite) TestChildConsent(c *check.C) {
+	var tcs = []struct {
+		desc          string
+		sid           int
+		consent       *iabconsent.MspaParsedConsent
+		bracket       int
+		expected      iabconsent.MspaConsent
+		expectedUnder bool
+	}{
+		{
+			desc: "US National consent for 13 to 16, but not under 13.",
+			sid:  iabconsent.UsNationalSID,
+			consent: &iabconsent.MspaParsedConsent{KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.Consent, 1: iabconsent.ConsentNotApplicable}},
+			bracket:       iabconsent.UsNatChild13To16,
+			expected:      iabconsent.Consent,
+			expectedUnder: false,
+		},
+		{
+			desc: "US National no consent for under 13.",
+			sid:  iabconsent.UsNationalSID,
+			consent: &iabconsent.MspaParsedConsent{KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.ConsentNotApplicable, 1: iabconsent.NoConsent}},
+			bracket:       iabconsent.UsNatChildUnder13,
+			expected:      iabconsent.NoConsent,
+			expectedUnder: true,
+		},
+		{
+			desc: "US National has no third bracket in v1.",
+			sid:  iabconsent.UsNationalSID,
+			consent: &iabconsent.MspaParsedConsent{KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.ConsentNotApplicable, 1: iabconsent.ConsentNotApplicable}},
+			bracket:       2,
+			expected:      iabconsent.ConsentNotApplicable,
+			expectedUnder: false,
+		},
+		{
+			desc: "California, whose brackets are not named.",
+			sid:  iabconsent.UsCaliforniaSID,
+			consent: &iabconsent.MspaParsedConsent{KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+				0: iabconsent.Consent, 1: iabconsent.NoConsent}},
+			bracket:       0,
+			expected:      iabconsent.Consent,
+			expectedUnder: false,
+		},
+		{
+			desc:          "Colorado, whose bracket is not named.",
+			sid:           iabconsent.UsColoradoSID,
+			consent:       mspaConsentFixtures[iabconsent.UsColoradoSID]["BVoYYQg"],
+			bracket:       0,
+			expected:      iabconsent.NoConsent,
+			expectedUnder: false,
+		},
+		{
+			desc:          "Unsupported section.",
+			sid:           2,
+			consent:       mspaConsentFixtures[iabconsent.UsColoradoSID]["BVoYYQg"],
+			bracket:       0,
+			expected:      iabconsent.NoConsent,
+			expectedUnder: false,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		c.Check(tc.consent.ChildConsent(tc.bracket), check.Equals, tc.expected)
+		c.Check(tc.consent.HasKnownChildUnder13(tc.sid), check.Equals, tc.expectedUnder)
+	}
+}
+
+func (s *MspaSuite) TestInvolvesKnownChild(c *check.C) {
+	var tcs = []struct {
+		desc          string
+		sid           int
+		section       string
+		expected      bool
+		expectedUnder bool
+	}{
+		{
+			desc:    "Virginia, with one bracket, Not Applicable.",
+			sid:     iabconsent.UsVirginiaSID,
+			section: "BAAAAAA",
+		},
+		{
+			desc:     "Virginia, with one bracket, no consent.",
+			sid:      iabconsent.UsVirginiaSID,
+			section:  "BAAAAEA",
+			expected: true,
+		},
+		{
+			desc:     "US National, with two brackets, no consent for 13 to 16.",
+			sid:      iabconsent.UsNationalSID,
+			section:  "BAAAAAAAQAA",
+			expected: true,
+		},
+		{
+			desc:          "US National, with two brackets, no consent for under 13.",
+			sid:           iabconsent.UsNationalSID,
+			section:       "BAAAAAAAEAA",
+			expected:      true,
+			expectedUnder: true,
+		},
+		{
+			desc:     "California, with two brackets, no consent for 13 to 16.",
+			sid:      iabconsent.UsCaliforniaSID,
+			section:  "BAAAAAQA",
+			expected: true,
+		},
+		{
+			desc:    "Delaware, with five brackets, Not Applicable.",
+			sid:     iabconsent.UsDelawareSID,
+			section: "BAAAAAAAAA",
+		},
+		{
+			desc:     "Delaware, with five brackets, no consent for the fifth.",
+			sid:      iabconsent.UsDelawareSID,
+			section:  "BAAAAAAQAA",
+			expected: true,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.NewMspa(tc.sid, tc.section).ParseConsent()
+		c.Assert(err, check.IsNil)
+		var m = p.(*iabconsent.MspaParsedConsent)
+		c.Check(m.InvolvesKnownChild(), check.Equals, tc.expected)
+		c.Check(m.HasKnownChildUnder13(tc.sid), check.Equals, tc.expectedUnder)
+	}
+
+	// Invalid values are treated as a known minor.
+	var m = &iabconsent.MspaParsedConsent{KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{
+		0: iabconsent.ConsentNotApplicable, 1: iabconsent.InvalidConsentValue}}
+	c.Check(m.InvolvesKnownChild(), check.Equals, true)
+	c.Check((&iabconsent.MspaParsedConsent{}).InvolvesKnownChild(), check.Equals, false)
+}
+
+func (s *MspaSuite) TestVirginiaSpecVersion(c *check.C) {
+	// The usva section is implemented as defined by version 1 of the IAB GPP US-States
+	// Virginia specification, which is the only published version.
+	c.Check(iabconsent.FieldsForVersion(iabconsent.UsVirginiaSID, 1), check.DeepEquals, []string{
+		"Version", "SharingNotice", "SaleOptOutNotice", "TargetedAdvertisingOptOutNotice", "SaleOptOut",
+		"TargetedAdvertisingOptOut", "SensitiveDataProcessingConsents[0]", "SensitiveDataProcessingConsents[1]",
+		"SensitiveDataProcessingConsents[2]", "SensitiveDataProcessingConsents[3]",
+		"SensitiveDataProcessingConsents[4]", "SensitiveDataProcessingConsents[5]",
+		"SensitiveDataProcessingConsents[6]", "SensitiveDataProcessingConsents[7]",
+		"KnownChildSensitiveDataConsents[0]", "MspaCoveredTransaction", "MspaOptOutOptionMode",
+		"MspaServiceProviderMode", "Gpc",
+	})
+	c.Check(iabconsent.MspaSensitiveDataCount(iabconsent.UsVirginiaSID, 1), check.Equals, 8)
+	c.Check(iabconsent.FieldsForVersion(iabconsent.UsVirginiaSID, 2), check.IsNil)
+
+	var p, err = iabconsent.NewMspa(iabconsent.UsVirginiaSID, "ClaSSZk").ParseConsent()
+	c.Check(p, check.IsNil)
+	c.Check(err, check.ErrorMatches, "unsupported version: 2")
+}
+
+func (s *MspaSuite) TestNewOptedOutMspaConsent(c *check.C) {
+	for sid := range mspaConsentFixtures {
+		c.Log(sid)
+
+		var m = iabconsent.NewOptedOutMspaConsent(sid)
+		c.Assert(m, check.NotNil)
+		c.Check(m.Validate(), check.IsNil)
+		c.Check(m.SensitiveDataCount(), check.Equals, iabconsent.MspaSensitiveDataCount(sid, m.Version))
+		c.Check(m.ConsentedSensitiveCategories(), check.HasLen, 0)
+		c.Check(m.UsPrivacyDecision().SaleAllowed(), check.Equals, false)
+		c.Check(m.InvolvesKnownChild(), check.Equals, true)
+		c.Check(m.HasKnownChildUnder13(sid), check.Equals, sid == iabconsent.UsNationalSID)
+
+		// The consent is encodable, and decodes to the same values.
+		var encoded, err = iabconsent.EncodeMspaSection(sid, m)
+		c.Assert(err, check.IsNil)
+		var p iabconsent.GppParsedConsent
+		p, err = iabconsent.NewMspa(sid, encoded).ParseConsent()
+		c.Assert(err, check.IsNil)
+		c.Check(p, check.DeepEquals, m)
+	}
+
+	c.Check(iabconsent.NewOptedOutMspaConsent(iabconsent.UsNationalSID).Version, check.Equals, 2)
+	c.Check(iabconsent.NewOptedOutMspaConsent(iabconsent.UsCaliforniaSID).SensitiveDataProcessingOptOuts, check.HasLen, 9)
+	c.Check(iabconsent.NewOptedOutMspaConsent(2), check.IsNil)
+}
+
+func (s *MspaSuite) TestUsNationalFieldTable(c *check.C) {
+	// The usnat core segment fields, as defined by versions 1 and 2 of the IAB GPP US National
+	// specification. The GPC subsection is separated by a `.`, so it is not read at a bit offset
+	// of the core segment.
+	var notices = []string{
+		"Version", "SharingNotice", "SaleOptOutNotice", "SharingOptOutNotice", "TargetedAdvertisingOptOutNotice",
+		"SensitiveDataProcessingOptOutNotice", "SensitiveDataLimitUseNotice", "SaleOptOut", "SharingOptOut",
+		"TargetedAdvertisingOptOut",
+	}
+	var trailer = []string{"PersonalDataConsents", "MspaCoveredTransaction", "MspaOptOutOptionMode",
+		"MspaServiceProviderMode", "Gpc"}
+	var tcs = []struct {
+		version       int
+		sensitiveData int
+		knownChild    int
+		bits          int
+	}{
+		{version: 1, sensitiveData: 12, knownChild: 2, bits: 60},
+		{version: 2, sensitiveData: 16, knownChild: 3, bits: 70},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.version)
+
+		var expected = append([]string(nil), notices...)
+		for i := 0; i < tc.sensitiveData; i++ {
+			expected = append(expected, fmt.Sprintf("SensitiveDataProcessingConsents[%d]", i))
+		}
+		for i := 0; i < tc.knownChild; i++ {
+			expected = append(expected, fmt.Sprintf("KnownChildSensitiveDataConsents[%d]", i))
+		}
+		expected = append(expected, trailer...)
+
+		var fields = iabconsent.FieldsForVersion(iabconsent.UsNationalSID, tc.version)
+		c.Check(fields, check.DeepEquals, expected)
+		// The 6 bit Version, and 2 bits for every other field besides Gpc.
+		c.Check(6+2*(len(fields)-2), check.Equals, tc.bits)
+	}
+}
+
+func (s *MspaSuite) TestMspaMode(c *check.C) {
+	var values = []iabconsent.MspaNaYesNo{iabconsent.MspaNotApplicable, iabconsent.MspaYes, iabconsent.MspaNo}
+	for _, covered := range values {
+		for _, optOutOption := range values {
+			for _, serviceProvider := range values {
+				var m = &iabconsent.MspaParsedConsent{
+					MspaCoveredTransaction:  covered,
+					MspaOptOutOptionMode:    optOutOption,
+					MspaServiceProviderMode: serviceProvider,
+				}
+				c.Log(covered, optOutOption, serviceProvider)
+
+				var expected = iabconsent.MspaModeNotCovered
+				switch {
+				case covered != iabconsent.MspaYes:
+				case optOutOption == iabconsent.MspaYes && serviceProvider != iabconsent.MspaYes:
+					expected = iabconsent.MspaModeOptOutOption
+				case serviceProvider == iabconsent.MspaYes && optOutOption != iabconsent.MspaYes:
+					expected = iabconsent.MspaModeServiceProvider
+				default:
+					expected = iabconsent.MspaModeUnspecified
+				}
+				c.Check(m.MspaMode(), check.Equals, expected)
+				c.Check(m.IsMspaCovered(), check.Equals, covered == iabconsent.MspaYes)
+			}
+		}
+	}
+	var invalid = &iabconsent.MspaParsedConsent{MspaCoveredTransaction: iabconsent.InvalidMspaValue}
+	c.Check(invalid.IsMspaCovered(), check.Equals, false)
+}
+
+func (s *MspaSuite) TestNationalScope(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		covered  iabconsent.MspaNaYesNo
+		sid      int
+		expected bool
+	}{
+		{desc: "US National Covered Transaction.", covered: iabconsent.MspaYes, sid: iabconsent.UsNationalSID, expected: true},
+		{desc: "US National not a Covered Transaction.", covered: iabconsent.MspaNo, sid: iabconsent.UsNationalSID, expected: false},
+		{desc: "US National Not Applicable.", covered: iabconsent.MspaNotApplicable, sid: iabconsent.UsNationalSID, expected: false},
+		{desc: "State Covered Transaction.", covered: iabconsent.MspaYes, sid: iabconsent.UsCaliforniaSID, expected: false},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var section, err = iabconsent.NewMspaConsentBuilder().
+			SetMspaCoveredTransaction(tc.covered).
+			SetMspaOptOutOptionMode(iabconsent.MspaYes).
+			SetMspaServiceProviderMode(iabconsent.MspaNo).
+			Build(tc.sid)
+		c.Assert(err, check.IsNil)
+		var header = map[int]string{iabconsent.UsNationalSID: "DBABLA", iabconsent.UsCaliforniaSID: "DBABBg"}[tc.sid]
+		var r *iabconsent.GppResult
+		r, err = iabconsent.ParseGpp(header + "~" + section)
+		c.Assert(err, check.IsNil)
+		c.Check(r.Sections, check.HasLen, 1)
+		c.Check(r.NationalScope(), check.Equals, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestUtahKnownChildAndModes(c *check.C) {
+	// Version 1 of the usut specification has a single known child field, which is followed by
+	// the MSPA fields. The fixture is generated by the encoder, with a different value in each
+	// MSPA field, so that a misaligned known child field would change the decoded modes.
+	var b = iabconsent.NewMspaConsentBuilder().
+		SetSharingNotice(iabconsent.NoticeProvided).
+		SetSaleOptOutNotice(iabconsent.NoticeProvided).
+		SetTargetedAdvertisingOptOutNotice(iabconsent.NoticeProvided).
+		SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeProvided).
+		SetSaleOptOut(iabconsent.OptedOut).
+		SetTargetedAdvertisingOptOut(iabconsent.NotOptedOut).
+		SetKnownChildSensitiveDataConsent(0, iabconsent.Consent).
+		SetMspaCoveredTransaction(iabconsent.MspaYes).
+		SetMspaOptOutOptionMode(iabconsent.MspaNo).
+		SetMspaServiceProviderMode(iabconsent.MspaYes)
+	for i, o := range []iabconsent.MspaOptout{0, 1, 2, 0, 1, 2, 0, 1} {
+		b.SetSensitiveDataOptOut(i, o)
+	}
+	var encoded, err = b.Build(iabconsent.UsUtahSID)
+	c.Assert(err, check.IsNil)
+	c.Check(encoded, check.Equals, "BVWGGGZA")
+
+	var p iabconsent.GppParsedConsent
+	p, err = iabconsent.NewMspa(iabconsent.UsUtahSID, encoded).ParseConsent()
+	c.Assert(err, check.IsNil)
+	var m = p.(*iabconsent.MspaParsedConsent)
+	c.Check(m.KnownChildSensitiveDataConsents, check.DeepEquals, map[int]iabconsent.MspaConsent{0: iabconsent.Consent})
+	c.Check(m.MspaCoveredTransaction, check.Equals, iabconsent.MspaYes)
+	c.Check(m.MspaOptOutOptionMode, check.Equals, iabconsent.MspaNo)
+	c.Check(m.MspaServiceProviderMode, check.Equals, iabconsent.MspaYes)
+	c.Check(iabconsent.FieldsForVersion(iabconsent.UsUtahSID, 1)[15:], check.DeepEquals, []string{
+		"KnownChildSensitiveDataConsents[0]", "MspaCoveredTransaction", "MspaOptOutOptionMode",
+		"MspaServiceProviderMode", "Gpc",
+	})
+}
+
+func (s *MspaSuite) TestConnecticutSpecVersion(c *check.C) {
+	// The usct section is implemented as defined by version 1 of the IAB GPP US-States
+	// Connecticut specification, which is the only published version. It has 8 Sensitive Data
+	// Processing categories and 3 known child fields. The fixture has a different value in the
+	// last sensitive data field and in each known child field, so that a misaligned field would
+	// change the decoded values.
+	var b = iabconsent.NewMspaConsentBuilder().
+		SetSensitiveDataConsent(7, iabconsent.Consent).
+		SetKnownChildSensitiveDataConsent(0, iabconsent.NoConsent).
+		SetKnownChildSensitiveDataConsent(1, iabconsent.Consent).
+		SetKnownChildSensitiveDataConsent(2, iabconsent.NoConsent).
+		SetMspaCoveredTransaction(iabconsent.MspaYes).
+		SetMspaOptOutOptionMode(iabconsent.MspaNo).
+		SetMspaServiceProviderMode(iabconsent.MspaNo)
+	var encoded, err = b.Build(iabconsent.UsConnecticutSID)
+	c.Assert(err, check.IsNil)
+	c.Check(encoded, check.Equals, "BAAAAmWg")
+
+	var p iabconsent.GppParsedConsent
+	p, err = iabconsent.NewMspa(iabconsent.UsConnecticutSID, encoded).ParseConsent()
+	c.Assert(err, check.IsNil)
+	var m = p.(*iabconsent.MspaParsedConsent)
+	c.Check(m.SensitiveDataCount(), check.Equals, 8)
+	c.Check(m.ConsentedSensitiveCategories(), check.DeepEquals, []int{7})
+	c.Check(m.KnownChildSensitiveDataConsents, check.DeepEquals, map[int]iabconsent.MspaConsent{
+		0: iabconsent.NoConsent,
+		1: iabconsent.Consent,
+		2: iabconsent.NoConsent,
+	})
+	c.Check(m.MspaCoveredTransaction, check.Equals, iabconsent.MspaYes)
+	c.Check(m.MspaOptOutOptionMode, check.Equals, iabconsent.MspaNo)
+	c.Check(m.MspaServiceProviderMode, check.Equals, iabconsent.MspaNo)
+
+	c.Check(iabconsent.MspaSensitiveDataCount(iabconsent.UsConnecticutSID, 1), check.Equals, 8)
+	c.Check(iabconsent.SensitiveDataCategoryName(iabconsent.UsConnecticutSID, 2), check.Equals,
+		"mental or physical health condition or diagnosis")
+	c.Check(iabconsent.SensitiveDataCategoryName(iabconsent.UsConnecticutSID, 8), check.Equals, "")
+	c.Check(iabconsent.FieldsForVersion(iabconsent.UsConnecticutSID, 1)[14:], check.DeepEquals, []string{
+		"KnownChildSensitiveDataConsents[0]", "KnownChildSensitiveDataConsents[1]",
+		"KnownChildSensitiveDataConsents[2]", "MspaCoveredTransaction", "MspaOptOutOptionMode",
+		"MspaServiceProviderMode", "Gpc",
+	})
+	c.Check(iabconsent.FieldsForVersion(iabconsent.UsConnecticutSID, 2), check.IsNil)
+}
+
+func (s *MspaSuite) TestIsEntirelyNotApplicable(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		sid      int
+		builder  *iabconsent.MspaConsentBuilder
+		expected bool
+	}{
+		{
+			desc: "Only the MSPA fields and Gpc, which describe the transaction rather than the user.",
+			sid:  iabconsent.UsNationalSID,
+			builder: iabconsent.NewMspaConsentBuilder().
+				SetMspaCoveredTransaction(iabconsent.MspaNo).
+				SetMspaOptOutOptionMode(iabconsent.MspaYes).
+				SetGpc(true),
+			expected: true,
+		},
+		{
+			desc:     "Notice.",
+			sid:      iabconsent.UsNationalSID,
+			builder:  iabconsent.NewMspaConsentBuilder().SetSaleOptOutNotice(iabconsent.NoticeNotProvided),
+			expected: false,
+		},
+		{
+			desc:     "Opt-out.",
+			sid:      iabconsent.UsNationalSID,
+			builder:  iabconsent.NewMspaConsentBuilder().SetTargetedAdvertisingOptOut(iabconsent.NotOptedOut),
+			expected: false,
+		},
+		{
+			desc:     "Sensitive data consent.",
+			sid:      iabconsent.UsNationalSID,
+			builder:  iabconsent.NewMspaConsentBuilder().SetSensitiveDataConsent(11, iabconsent.NoConsent),
+			expected: false,
+		},
+		{
+			desc:     "Sensitive data opt-out.",
+			sid:      iabconsent.UsUtahSID,
+			builder:  iabconsent.NewMspaConsentBuilder().SetSensitiveDataOptOut(3, iabconsent.OptedOut),
+			expected: false,
+		},
+		{
+			desc:     "Known child consent.",
+			sid:      iabconsent.UsNationalSID,
+			builder:  iabconsent.NewMspaConsentBuilder().SetKnownChildSensitiveDataConsent(1, iabconsent.Consent),
+			expected: false,
+		},
+		{
+			desc:     "Personal data consent.",
+			sid:      iabconsent.UsNationalSID,
+			builder:  iabconsent.NewMspaConsentBuilder().SetPersonalDataConsents(iabconsent.Consent),
+			expected: false,
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var encoded, err = tc.builder.Build(tc.sid)
+		c.Assert(err, check.IsNil)
+		var p iabconsent.GppParsedConsent
+		p, err = iabconsent.NewMspa(tc.sid, encoded).ParseConsent()
+		c.Assert(err, check.IsNil)
+		c.Check(p.(*iabconsent.MspaParsedConsent).IsEntirelyNotApplicable(), check.Equals, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestUnsupportedVersionIsReported(c *check.C) {
+	// Version 3 is not supported by any section, so only the Version is decoded. Every section
+	// reports it, along with the Section ID, in the error.
+	for sid := 0; sid <= 64; sid++ {
+		var parser = iabconsent.NewMspa(sid, "DVVqAAEABA")
+		if parser == nil {
+			continue
+		}
+		c.Log(sid)
+
+		var p, err = parser.ParseConsent()
+		c.Check(p, check.IsNil)
+		c.Check(err, check.Equals, iabconsent.ErrUnsupportedVersion{SectionID: sid, Version: 3})
+		c.Check(err, check.ErrorMatches, "unsupported version: 3")
+	}
+
+	var err = iabconsent.ParseMspaReuse(iabconsent.UsCaliforniaSID, "CVoYYZoI", &iabconsent.MspaParsedConsent{})
+	c.Check(err, check.Equals, iabconsent.ErrUnsupportedVersion{SectionID: iabconsent.UsCaliforniaSID, Version: 2})
+}
+
+func (s *MspaSuite) TestRedacted(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		consent  *iabconsent.MspaParsedConsent
+		expected iabconsent.RedactedConsent
+	}{
+		{
+			desc: "Opted out of sale only, with granular fields dropped.",
+			consent: &iabconsent.MspaParsedConsent{
+				Version:                         1,
+				SaleOptOutNotice:                iabconsent.NoticeProvided,
+				SaleOptOut:                      iabconsent.OptedOut,
+				SharingOptOut:                   iabconsent.NotOptedOut,
+				SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{3: iabconsent.Consent},
+				KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{0: iabconsent.NoConsent},
+			},
+			expected: iabconsent.RedactedConsent{
+				Version:              1,
+				CanShare:             true,
+				CanTargetAdvertising: true,
+			},
+		},
+		{
+			desc: "Opted out of targeted advertising.",
+			consent: &iabconsent.MspaParsedConsent{
+				Version:                   2,
+				TargetedAdvertisingOptOut: iabconsent.OptedOut,
+			},
+			expected: iabconsent.RedactedConsent{
+				Version:  2,
+				CanSell:  true,
+				CanShare: true,
+			},
+		},
+		{
+			desc: "GPC opts out of everything.",
+			consent: &iabconsent.MspaParsedConsent{
+				Version:    1,
+				SaleOptOut: iabconsent.NotOptedOut,
+				Gpc:        true,
+				GpcPresent: true,
+			},
+			expected: iabconsent.RedactedConsent{
+				Version: 1,
+				Gpc:     true,
+			},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		c.Check(tc.consent.Redacted(), check.DeepEquals, tc.expected)
+	}
+
+	var p, err = iabconsent.NewMspa(iabconsent.UsCaliforniaSID, "BVoYYZoI").ParseConsent()
+	c.Assert(err, check.IsNil)
+	var m = p.(*iabconsent.MspaParsedConsent)
+	c.Check(m.Redacted().CanSell, check.Equals, m.CaliforniaCanSell())
+	c.Check(m.Redacted().CanShare, check.Equals, m.CaliforniaCanShare())
+}
+
+func (s *MspaSuite) TestDecisionKey(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		sid      int
+		builder  *iabconsent.MspaConsentBuilder
+		expected string
+	}{
+		{
+			desc:     "Nothing opted out.",
+			sid:      iabconsent.UsNationalSID,
+			builder:  iabconsent.NewMspaConsentBuilder(),
+			expected: "sale=ok,share=ok,ta=ok,sd=no,gpc=0",
+		},
+		{
+			desc: "Opted out of sale, with consent to Sensitive Data.",
+			sid:  iabconsent.UsNationalSID,
+			builder: iabconsent.NewMspaConsentBuilder().
+				SetSaleOptOutNotice(iabconsent.NoticeProvided).
+				SetSaleOptOut(iabconsent.OptedOut).
+				SetSensitiveDataProcessingOptOutNotice(iabconsent.NoticeProvided).
+				SetSensitiveDataConsent(2, iabconsent.Consent),
+			expected: "sale=no,share=ok,ta=ok,sd=ok,gpc=0",
+		},
+		{
+			desc: "California, opted out of sharing.",
+			sid:  iabconsent.UsCaliforniaSID,
+			builder: iabconsent.NewMspaConsentBuilder().
+				SetSharingOptOutNotice(iabconsent.NoticeProvided).
+				SetSharingOptOut(iabconsent.OptedOut),
+			expected: "sale=ok,share=no,ta=ok,sd=no,gpc=0",
+		},
+		{
+			desc:     "GPC opts out of everything.",
+			sid:      iabconsent.UsVirginiaSID,
+			builder:  iabconsent.NewMspaConsentBuilder().SetGpc(true),
+			expected: "sale=no,share=no,ta=no,sd=no,gpc=1",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+		c.Check(mustParseMspa(c, tc.sid, tc.builder).DecisionKey(), check.Equals, tc.expected)
+	}
+
+	// Strings that only differ in fields that do not change a decision have the same key.
+	var notApplicable = mustParseMspa(c, iabconsent.UsCaliforniaSID, iabconsent.NewMspaConsentBuilder())
+	var notOptedOut = mustParseMspa(c, iabconsent.UsCaliforniaSID, iabconsent.NewMspaConsentBuilder().
+		SetSaleOptOutNotice(iabconsent.NoticeProvided).
+		SetSaleOptOut(iabconsent.NotOptedOut).
+		SetSharingOptOutNotice(iabconsent.NoticeProvided).
+		SetSharingOptOut(iabconsent.NotOptedOut).
+		SetMspaCoveredTransaction(iabconsent.MspaYes))
+	c.Check(notOptedOut.DecisionKey(), check.Equals, notApplicable.DecisionKey())
+	c.Check(notOptedOut.Fingerprint(), check.Not(check.Equals), notApplicable.Fingerprint())
+	c.Check(iabconsent.SectionName(iabconsent.UsCaliforniaSID)+":"+notApplicable.DecisionKey(), check.Equals,
+		"usca:sale=ok,share=ok,ta=ok,sd=no,gpc=0")
+}
+
+func (s *MspaSuite) TestOptOutSignals(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		sid      int
+		section  string
+		expected map[string]iabconsent.MspaOptout
+	}{
+		{
+			desc:    "US National encodes every opt-out.",
+			sid:     iabconsent.UsNationalSID,
+			section: "BVVqAAEABCA.QA",
+			expected: map[string]iabconsent.MspaOptout{
+				"sale":                 iabconsent.NotOptedOut,
+				"sharing":              iabconsent.NotOptedOut,
+				"targeted_advertising": iabconsent.NotOptedOut,
+			},
+		},
+		{
+			desc:    "California has no targeted advertising opt-out.",
+			sid:     iabconsent.UsCaliforniaSID,
+			section: "BVoYYZoI",
+			expected: map[string]iabconsent.MspaOptout{
+				"sale":                 iabconsent.NotOptedOut,
+				"sharing":              iabconsent.NotOptedOut,
+				"targeted_advertising": iabconsent.OptOutNotApplicable,
+			},
+		},
+		{
+			desc:    "Virginia has no sharing opt-out.",
+			sid:     iabconsent.UsVirginiaSID,
+			section: "BVoYYYI",
+			expected: map[string]iabconsent.MspaOptout{
+				"sale":                 iabconsent.NotOptedOut,
+				"sharing":              iabconsent.OptOutNotApplicable,
+				"targeted_advertising": iabconsent.NotOptedOut,
+			},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.NewMspa(tc.sid, tc.section).ParseConsent()
+		c.Assert(err, check.IsNil)
+		c.Check(p.(*iabconsent.MspaParsedConsent).OptOutSignals(), check.DeepEquals, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestConsumedBits(c *check.C) {
+	for sid, sections := range mspaConsentFixtures {
+		for section, p := range sections {
+			c.Log(section)
+
+			var b, err = base64.RawURLEncoding.DecodeString(strings.SplitN(section, ".", 2)[0])
+			c.Assert(err, check.IsNil)
+			var consumed = p.ConsumedBits(sid)
+			var r = iabconsent.NewConsentReader(b)
+			c.Check(r.SkipBits(uint(consumed)), check.IsNil)
+			// The core segment is only padded to a whole number of bytes.
+			c.Check(consumed+r.NumUnread(), check.Equals, len(b)*8)
+			c.Check(r.NumUnread() < 8, check.Equals, true)
+		}
+	}
+
+	// The lengths documented by the string length constants.
+	c.Check((&iabconsent.MspaParsedConsent{Version: 1}).ConsumedBits(iabconsent.UsNationalSID), check.Equals, 60)
+	c.Check((&iabconsent.MspaParsedConsent{Version: 2}).ConsumedBits(iabconsent.UsNationalSID), check.Equals, 70)
+	c.Check((&iabconsent.MspaParsedConsent{Version: 3}).ConsumedBits(iabconsent.UsNationalSID), check.Equals, 0)
+	c.Check((&iabconsent.MspaParsedConsent{Version: 1}).ConsumedBits(2), check.Equals, 0)
+}
+
+// mustParseMspa builds the section of the given Section ID with b, and parses it.
+func mustParseMspa(c *check.C, sid int, b *iabconsent.MspaConsentBuilder) *iabconsent.MspaParsedConsent {
+	var section, err = b.Build(sid)
+	c.Assert(err, check.IsNil)
+	var p iabconsent.GppParsedConsent
+	p, err = iabconsent.NewMspa(sid, section).ParseConsent()
+	c.Assert(err, check.IsNil)
+	return p.(*iabconsent.MspaParsedConsent)
+}
@@ -0,0 +1,228 @@
+package iabconsent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseMspaReuse parses the MSPA section string s of the given GPP Section ID into dst,
+// which is Reset first. It returns the same values as NewMspa(sid, s).ParseConsent(), but
+// reuses the maps of dst, which allows MspaParsedConsents to be pooled (e.g. with a sync.Pool)
+// to reduce allocations on hot paths. Maps that the section does not use are left empty,
+// rather than nil. dst should not be used if an error is returned.
+func ParseMspaReuse(sid int, s string, dst *MspaParsedConsent) error {
+	if _, ok := mspaSectionLayouts[sid]; !ok {
+		return errors.New("unsupported section id: " + fmt.Sprint(sid))
+	}
+	dst.Reset()
+	if dst.SensitiveDataProcessingConsents == nil {
+		dst.SensitiveDataProcessingConsents = make(map[int]MspaConsent)
+	}
+	if dst.SensitiveDataProcessingOptOuts == nil {
+		dst.SensitiveDataProcessingOptOuts = make(map[int]MspaOptout)
+	}
+	if dst.KnownChildSensitiveDataConsents == nil {
+		dst.KnownChildSensitiveDataConsents = make(map[int]MspaConsent)
+	}
+	var _, err = parseMspaInto(sid, s, dst)
+	return err
+}
+
+// ParseMspaCompact parses the MSPA section string s of the given GPP Section ID, like
+// NewMspa(sid, s).ParseConsent(), but keeps Sensitive Data Processing consents in the compact
+// form of the core segment instead of the SensitiveDataProcessingConsents map, which is left nil,
+// like ParseMspaLazy. Opt-outs are decoded into SensitiveDataProcessingOptOuts as usual. This
+// reduces the allocations of each parse, and the consents are accessed with SensitiveDataSlice.
+func ParseMspaCompact(sid int, s string) (*MspaParsedConsent, error) {
+	if _, ok := mspaSectionLayouts[sid]; !ok {
+		return nil, errors.New("unsupported section id: " + fmt.Sprint(sid))
+	}
+	var p = &MspaParsedConsent{KnownChildSensitiveDataConsents: make(map[int]MspaConsent)}
+	if usesSensitiveDataOptOuts(sid) {
+		p.SensitiveDataProcessingOptOuts = make(map[int]MspaOptout)
+	} else {
+		p.packedSensitiveData = &packedSensitiveData{}
+	}
+	if _, err := parseMspaInto(sid, s, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewMspaCompact returns the GppSectionParser of a section like NewMspa, but MSPA sections are
+// parsed with ParseMspaCompact. It can be used to parse GPP strings compactly with:
+//
+//	iabconsent.ParseGppConsent(s, &iabconsent.Options{GppSectionParser: iabconsent.NewMspaCompact})
+func NewMspaCompact(sid int, section string) GppSectionParser {
+	if _, ok := mspaSectionLayouts[sid]; !ok {
+		return NewMspa(sid, section)
+	}
+	return &mspaCompactSection{GppSection{sectionId: sid, sectionValue: section}}
+}
+
+// mspaCompactSection is the GppSectionParser of MSPA sections parsed with ParseMspaCompact.
+type mspaCompactSection struct {
+	GppSection
+}
+
+func (m *mspaCompactSection) ParseConsent() (GppParsedConsent, error) {
+	var p, err = ParseMspaCompact(m.sectionId, m.sectionValue)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseMspaLazy parses the MSPA section string s of the given GPP Section ID, like
+// NewMspa(sid, s).ParseConsent(), but only records the location of the Sensitive Data Processing
+// fields. The methods of MspaParsedConsent read the fields where they are needed, without
+// modifying the consent, and DecodeSensitiveData returns a copy with the fields decoded. This is
+// faster for callers that never inspect the sensitive data categories, e.g. decisions based
+// only on the opt-outs and GPC. Parsing a US National v2 section with GPC takes less than half
+// of the time, and two thirds of the allocations, of NewMspa; see BenchmarkParseMspaLazy.
+func ParseMspaLazy(sid int, s string) (*MspaParsedConsent, error) {
+	if _, ok := mspaSectionLayouts[sid]; !ok {
+		return nil, errors.New("unsupported section id: " + fmt.Sprint(sid))
+	}
+	var p = &MspaParsedConsent{
+		KnownChildSensitiveDataConsents: make(map[int]MspaConsent),
+		packedSensitiveData:             &packedSensitiveData{optOuts: usesSensitiveDataOptOuts(sid)},
+	}
+	if _, err := parseMspaInto(sid, s, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewMspaLazy returns the GppSectionParser of a section like NewMspa, but MSPA sections are
+// parsed with ParseMspaLazy. It can be used to parse GPP strings lazily with:
+//
+//	iabconsent.ParseGppConsent(s, &iabconsent.Options{GppSectionParser: iabconsent.NewMspaLazy})
+func NewMspaLazy(sid int, section string) GppSectionParser {
+	if _, ok := mspaSectionLayouts[sid]; !ok {
+		return NewMspa(sid, section)
+	}
+	return &mspaLazySection{GppSection{sectionId: sid, sectionValue: section}}
+}
+
+// mspaLazySection is the GppSectionParser of MSPA sections parsed with ParseMspaLazy.
+type mspaLazySection struct {
+	GppSection
+}
+
+func (m *mspaLazySection) ParseConsent() (GppParsedConsent, error) {
+	var p, err = ParseMspaLazy(m.sectionId, m.sectionValue)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseMspaInto parses the MSPA section string s of the given GPP Section ID into dst, whose
+// maps must be allocated, except for the Sensitive Data Processing fields, which are skipped,
+// and their location recorded, if dst.packedSensitiveData is set. It returns whether the core
+// segment was decoded into dst, which is the case for errors of the subsections.
+func parseMspaInto(sid int, s string, dst *MspaParsedConsent) (bool, error) {
+	var versions, ok = mspaSectionLayouts[sid]
+	if !ok {
+		return false, errors.New("unsupported section id: " + fmt.Sprint(sid))
+	}
+
+	var core, subsections = s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		core, subsections = s[:i], s[i+1:]
+	}
+	var b, err = base64.RawURLEncoding.DecodeString(core)
+	if err != nil {
+		return false, errors.Wrap(err, "parse "+SectionName(sid)+" consent string")
+	}
+
+	if dst.packedSensitiveData != nil {
+		dst.packedSensitiveData.core = b
+	}
+	var r = NewConsentReader(b)
+	dst.Version, _ = r.ReadInt(6)
+	var layout mspaSectionLayout
+	if layout, ok = versions[dst.Version]; !ok {
+		return false, ErrUnsupportedVersion{SectionID: sid, Version: dst.Version}
+	}
+	if int(r.Size()) != layout.length {
+		return false, errors.New("invalid consent string length for v" + fmt.Sprint(dst.Version))
+	}
+
+	// The Version was read first to find the fields of its layout.
+	readMspaFields(r, dst, layout.fields[1:])
+
+	if subsections != "" || strings.HasSuffix(s, ".") {
+		var gppSubsectionConsent *GppSubSection
+		gppSubsectionConsent, err = ParseGppSubSections(strings.Split(subsections, "."))
+		if err != nil {
+			return true, err
+		}
+		dst.Gpc = gppSubsectionConsent.Gpc
+		dst.GpcPresent = gppSubsectionConsent.GpcPresent
+	}
+	return true, r.Err
+}
+
+// readMspaFields reads the given fields of a core segment into p.
+func readMspaFields(r *ConsentReader, p *MspaParsedConsent, fields []MspaField) {
+	for _, f := range fields {
+		switch f.Name {
+		case "SensitiveDataProcessingConsents":
+			readMspaSensitiveDataConsents(r, p, uint(f.Count))
+		case "SensitiveDataProcessingOptOuts":
+			readMspaSensitiveDataOptOuts(r, p, uint(f.Count))
+		case "KnownChildSensitiveDataConsents":
+			readMspaBitfieldConsentInto(r, p.KnownChildSensitiveDataConsents, uint(f.Count))
+		default:
+			var v, _ = r.ReadInt(uint(fieldTypeLength(f.Type)))
+			p.setFieldValue(f.Name, v)
+		}
+	}
+}
+
+// readMspaSensitiveDataConsents reads l Sensitive Data Processing consents into
+// SensitiveDataProcessingConsents.
+func readMspaSensitiveDataConsents(r *ConsentReader, p *MspaParsedConsent, l uint) {
+	if !skipPackedSensitiveData(r, p, l) {
+		readMspaBitfieldConsentInto(r, p.SensitiveDataProcessingConsents, l)
+	}
+}
+
+// readMspaSensitiveDataOptOuts reads l Sensitive Data Processing opt-outs into
+// SensitiveDataProcessingOptOuts.
+func readMspaSensitiveDataOptOuts(r *ConsentReader, p *MspaParsedConsent, l uint) {
+	if !skipPackedSensitiveData(r, p, l) {
+		readMspaBitfieldOptOutInto(r, p.SensitiveDataProcessingOptOuts, l)
+	}
+}
+
+// skipPackedSensitiveData skips l Sensitive Data Processing fields, and records their location
+// in p.packedSensitiveData, if it is set. It returns whether the fields were skipped.
+func skipPackedSensitiveData(r *ConsentReader, p *MspaParsedConsent, l uint) bool {
+	if p.packedSensitiveData == nil {
+		return false
+	}
+	p.packedSensitiveData.offset = uint(r.Size()) - uint(r.NumUnread())
+	p.packedSensitiveData.count = l
+	r.ReadBits(2 * l)
+	return true
+}
+
+// readMspaBitfieldConsentInto reads l MSPA Consent values into m.
+func readMspaBitfieldConsentInto(r *ConsentReader, m map[int]MspaConsent, l uint) {
+	for i := 0; i < int(l); i++ {
+		m[i], _ = r.ReadMspaConsent()
+	}
+}
+
+// readMspaBitfieldOptOutInto reads l MSPA OptOut values into m.
+func readMspaBitfieldOptOutInto(r *ConsentReader, m map[int]MspaOptout, l uint) {
+	for i := 0; i < int(l); i++ {
+		m[i], _ = r.ReadMspaOptOut()
+	}
+}
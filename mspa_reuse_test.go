@@ -0,0 +1,246 @@
+package iabconsent_test
+
+import (
+	"testing"
+
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+func (s *MspaSuite) TestParseMspaReuse(c *check.C) {
+	// Reuse a single MspaParsedConsent across every section, to check that no values
+	// leak from one parse to the next.
+	var dst = &iabconsent.MspaParsedConsent{}
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, expected := range fixtures {
+			c.Log(sid, k)
+
+			var err = iabconsent.ParseMspaReuse(sid, k, dst)
+			c.Check(err, check.IsNil)
+
+			// Maps that are not used by the section are empty, rather than nil.
+			var e = *expected
+			if e.SensitiveDataProcessingConsents == nil {
+				e.SensitiveDataProcessingConsents = map[int]iabconsent.MspaConsent{}
+			}
+			if e.SensitiveDataProcessingOptOuts == nil {
+				e.SensitiveDataProcessingOptOuts = map[int]iabconsent.MspaOptout{}
+			}
+			c.Check(dst, check.DeepEquals, &e)
+		}
+	}
+}
+
+func (s *MspaSuite) TestParseMspaReuseError(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		sid      int
+		consent  string
+		expected string
+	}{
+		{
+			desc:     "Unsupported Section ID.",
+			sid:      2,
+			consent:  "BVVqAAEABCA",
+			expected: "unsupported section id: 2",
+		},
+		{
+			desc:     "Unsupported Version.",
+			sid:      iabconsent.UsCaliforniaSID,
+			consent:  "CVoYYZoI",
+			expected: "unsupported version: 2",
+		},
+		{
+			desc:     "Invalid Length.",
+			sid:      iabconsent.UsNationalSID,
+			consent:  "BVVqAAEABC",
+			expected: "invalid consent string length for v1",
+		},
+		{
+			desc:     "Invalid base64.",
+			sid:      iabconsent.UsNationalSID,
+			consent:  "BVVqAAEABC*",
+			expected: "parse usnat consent string: .*",
+		},
+		{
+			desc:     "Empty Subsection.",
+			sid:      iabconsent.UsNationalSID,
+			consent:  "BVVqAAEABCA.",
+			expected: "parse gpp subsection type: .*",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var err = iabconsent.ParseMspaReuse(tc.sid, tc.consent, &iabconsent.MspaParsedConsent{})
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+}
+
+func (s *MspaSuite) TestParseConsentError(c *check.C) {
+	// The section parsers share the reader of ParseMspaReuse, and return the consent of the core
+	// segment with the error of its subsections.
+	var expected, err = iabconsent.NewMspa(iabconsent.UsNationalSID, "BVVqAAEABCA").ParseConsent()
+	c.Assert(err, check.IsNil)
+	var p iabconsent.GppParsedConsent
+	p, err = iabconsent.NewMspa(iabconsent.UsNationalSID, "BVVqAAEABCA.").ParseConsent()
+	c.Check(err, check.ErrorMatches, "parse gpp subsection type: .*")
+	c.Check(p, check.DeepEquals, expected)
+
+	p, err = iabconsent.NewMspa(iabconsent.UsCaliforniaSID, "BVoYYZ*").ParseConsent()
+	c.Check(err, check.ErrorMatches, "parse usca consent string: .*")
+	c.Check(p, check.IsNil)
+}
+
+func (s *MspaSuite) TestMspaParsedConsentReset(c *check.C) {
+	var p, err = iabconsent.NewMspa(iabconsent.UsNationalSID, "BVVqAAEABCA.YA").ParseConsent()
+	c.Assert(err, check.IsNil)
+
+	var m = p.(*iabconsent.MspaParsedConsent)
+	m.Reset()
+	c.Check(m, check.DeepEquals, &iabconsent.MspaParsedConsent{
+		SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{},
+		KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{},
+	})
+}
+
+func (s *MspaSuite) TestParseMspaCompact(c *check.C) {
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, expected := range fixtures {
+			c.Log(sid, k)
+
+			var p, err = iabconsent.ParseMspaCompact(sid, k)
+			c.Assert(err, check.IsNil)
+			c.Check(p.SensitiveDataProcessingConsents, check.IsNil)
+			c.Check(p.SensitiveDataSlice(), check.DeepEquals, expected.SensitiveDataSlice())
+			c.Check(p.SensitiveDataCount(), check.Equals, expected.SensitiveDataCount())
+			c.Check(p.ConsentedSensitiveCategories(), check.DeepEquals, expected.ConsentedSensitiveCategories())
+			c.Check(p.DecodeSensitiveData(), check.DeepEquals, expected)
+
+			// Every other field is the same, and is encoded the same.
+			var compact, encoded string
+			compact, err = iabconsent.EncodeMspaSection(sid, p)
+			c.Check(err, check.IsNil)
+			encoded, err = iabconsent.EncodeMspaSection(sid, expected)
+			c.Check(err, check.IsNil)
+			c.Check(compact, check.Equals, encoded)
+			c.Check(p.SensitiveDataProcessingOptOuts, check.DeepEquals, expected.SensitiveDataProcessingOptOuts)
+			c.Check(p.KnownChildSensitiveDataConsents, check.DeepEquals, expected.KnownChildSensitiveDataConsents)
+		}
+	}
+
+	var _, err = iabconsent.ParseMspaCompact(2, "BVVqAAEABCA")
+	c.Check(err, check.ErrorMatches, "unsupported section id: 2")
+}
+
+func (s *MspaSuite) TestNewMspaCompact(c *check.C) {
+	var p, err = iabconsent.ParseGppConsent("DBABzw~1YNN~BVVqAAEABCA.QA",
+		&iabconsent.Options{GppSectionParser: iabconsent.NewMspaCompact})
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.HasLen, 1)
+	var usnat = p[iabconsent.UsNationalSID].(*iabconsent.MspaParsedConsent)
+	c.Check(usnat.SensitiveDataProcessingConsents, check.IsNil)
+	c.Check(usnat.SensitiveDataSlice(), check.DeepEquals,
+		mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"].SensitiveDataSlice())
+
+	// Sections which are not MSPA sections are parsed as with NewMspa.
+	var sections []iabconsent.GppSectionParser
+	sections, err = iabconsent.MapGppSectionToParser("DBABjw~BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA~1YNN",
+		&iabconsent.Options{GppSectionParser: iabconsent.NewMspaCompact})
+	c.Assert(err, check.IsNil)
+	c.Assert(sections, check.HasLen, 1)
+	c.Check(sections[0], check.FitsTypeOf, &iabconsent.TcfCaV1{})
+}
+
+func (s *MspaSuite) TestParseMspaLazy(c *check.C) {
+	for sid, fixtures := range mspaConsentFixtures {
+		for k, expected := range fixtures {
+			c.Log(sid, k)
+
+			var p, err = iabconsent.ParseMspaLazy(sid, k)
+			c.Assert(err, check.IsNil)
+			c.Check(p.SensitiveDataProcessingConsents, check.IsNil)
+			c.Check(p.SensitiveDataProcessingOptOuts, check.IsNil)
+			c.Check(p.Gpc, check.Equals, expected.Gpc)
+			c.Check(p.SaleOptOut, check.Equals, expected.SaleOptOut)
+
+			// The accessors read the undecoded fields without modifying the consent.
+			var parsed = *p
+			c.Check(p.SensitiveDataCount(), check.Equals, expected.SensitiveDataCount())
+			c.Check(p.SensitiveDataSlice(), check.DeepEquals, expected.SensitiveDataSlice())
+			c.Check(p.OptedOutSensitiveCategories(), check.DeepEquals, expected.OptedOutSensitiveCategories())
+			c.Check(p.ConsentedSensitiveCategories(), check.DeepEquals, expected.ConsentedSensitiveCategories())
+			c.Check(p.AnySensitiveDataAllowed(), check.Equals, expected.AnySensitiveDataAllowed())
+			c.Check(p.Fields(), check.DeepEquals, expected.Fields())
+			c.Check(p.Fingerprint(), check.Equals, expected.Fingerprint())
+			c.Check(*p, check.DeepEquals, parsed)
+
+			// DecodeSensitiveData returns a decoded copy.
+			c.Check(p.DecodeSensitiveData(), check.DeepEquals, expected)
+			c.Check(*p, check.DeepEquals, parsed)
+			c.Check(expected.DecodeSensitiveData(), check.Equals, expected)
+		}
+	}
+
+	// Skipping the fields saves allocations.
+	var lazy = testing.AllocsPerRun(10, func() {
+		iabconsent.ParseMspaLazy(iabconsent.UsNationalSID, "CVVVVVVVVVVU.YA")
+	})
+	var eager = testing.AllocsPerRun(10, func() {
+		iabconsent.NewMspa(iabconsent.UsNationalSID, "CVVVVVVVVVVU.YA").ParseConsent()
+	})
+	c.Check(lazy < eager, check.Equals, true, check.Commentf("%v lazy, %v eager allocations", lazy, eager))
+
+	var _, err = iabconsent.ParseMspaLazy(2, "BVVqAAEABCA")
+	c.Check(err, check.ErrorMatches, "unsupported section id: 2")
+	_, err = iabconsent.ParseMspaLazy(iabconsent.UsNationalSID, "BVVqAA")
+	c.Check(err, check.ErrorMatches, "invalid consent string length for v1")
+}
+
+func (s *MspaSuite) TestNewMspaLazy(c *check.C) {
+	var p, err = iabconsent.ParseGppConsent("DBACLMA~BVVqAAEABCA.QA~BVoYYYI",
+		&iabconsent.Options{GppSectionParser: iabconsent.NewMspaLazy})
+	c.Assert(err, check.IsNil)
+	c.Assert(p, check.HasLen, 2)
+	var usnat = p[iabconsent.UsNationalSID].(*iabconsent.MspaParsedConsent)
+	c.Check(usnat.SensitiveDataProcessingConsents, check.IsNil)
+	c.Check(usnat.GpcPresent, check.Equals, true)
+	var expected = mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"]
+	var encoded, expectedEncoded string
+	encoded, err = iabconsent.EncodeMspaSection(iabconsent.UsNationalSID, usnat)
+	c.Check(err, check.IsNil)
+	expectedEncoded, err = iabconsent.EncodeMspaSection(iabconsent.UsNationalSID, expected)
+	c.Check(err, check.IsNil)
+	c.Check(encoded, check.Equals, expectedEncoded)
+	c.Check(usnat.SensitiveDataProcessingConsents, check.IsNil)
+	c.Check(usnat.DecodeSensitiveData(), check.DeepEquals, expected)
+}
+
+func (s *MspaSuite) TestSensitiveDataSlice(c *check.C) {
+	var p = &iabconsent.MspaParsedConsent{
+		SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{0: iabconsent.Consent, 2: iabconsent.NoConsent},
+	}
+	// Missing categories are Not Applicable.
+	c.Check(p.SensitiveDataSlice(), check.DeepEquals, []iabconsent.MspaConsent{iabconsent.Consent, 0, iabconsent.NoConsent})
+
+	// Opt-out sections do not have Sensitive Data Processing consents.
+	var ca = mspaConsentFixtures[iabconsent.UsCaliforniaSID]["BVoYYZoI"]
+	c.Check(ca.SensitiveDataSlice(), check.IsNil)
+}
+
+// BenchmarkParseMspaLazy and BenchmarkParseMspa compare parsing a US National v2 section with
+// GPC lazily, and with NewMspa, as documented by ParseMspaLazy.
+func BenchmarkParseMspaLazy(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		iabconsent.ParseMspaLazy(iabconsent.UsNationalSID, "CVVVVVVVVVVU.YA")
+	}
+}
+
+func BenchmarkParseMspa(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		iabconsent.NewMspa(iabconsent.UsNationalSID, "CVVVVVVVVVVU.YA").ParseConsent()
+	}
+}
@@ -0,0 +1,324 @@
+package iabconsent
+
+import (
+	"fmt"
+)
+
+// FieldType is an enum type of the value types of MSPA section fields.
+type FieldType int
+
+const (
+	// FieldTypeInt is an unsigned integer, which is only used for the Version.
+	FieldTypeInt FieldType = iota
+	// FieldTypeNotice is a MspaNotice.
+	FieldTypeNotice
+	// FieldTypeOptOut is a MspaOptout.
+	FieldTypeOptOut
+	// FieldTypeConsent is a MspaConsent.
+	FieldTypeConsent
+	// FieldTypeNaYesNo is a MspaNaYesNo.
+	FieldTypeNaYesNo
+	// FieldTypeBool is a single bit boolean, which is only used for Gpc.
+	FieldTypeBool
+)
+
+// MspaField is a field of the core segment of a MSPA section, or a bitfield of consecutive
+// fields of the same type, e.g. the Sensitive Data Processing consents.
+type MspaField struct {
+	// The name of the MspaParsedConsent field.
+	Name string
+	// The type of the value of the field, which determines its length in bits.
+	Type FieldType
+	// The number of fields of a bitfield, or 0 for a single field. The fields of a bitfield
+	// are named by their zero-based index, e.g. "SensitiveDataProcessingConsents[11]".
+	Count int
+}
+
+// mspaFieldTable lists the fields of the core segment of each supported version of each MSPA
+// section, by GPP Section ID and version, in the order they are encoded. The core segment is
+// read and written by walking the fields of its version, and FieldsForVersion, SectionSchema,
+// MspaSensitiveDataCount, NewOptedOutMspaConsent, and the valid string length of each version
+// are derived from it, so that a change of the spec is made by updating the table and the
+// fixtures. The GPC subsection is not part of the core segment, so Gpc is not listed.
+var mspaFieldTable = map[int]map[int][]MspaField{
+	UsNationalSID: {
+		1: {
+			{"Version", FieldTypeInt, 0},
+			{"SharingNotice", FieldTypeNotice, 0},
+			{"SaleOptOutNotice", FieldTypeNotice, 0},
+			{"SharingOptOutNotice", FieldTypeNotice, 0},
+			{"TargetedAdvertisingOptOutNotice", FieldTypeNotice, 0},
+			{"SensitiveDataProcessingOptOutNotice", FieldTypeNotice, 0},
+			{"SensitiveDataLimitUseNotice", FieldTypeNotice, 0},
+			{"SaleOptOut", FieldTypeOptOut, 0},
+			{"SharingOptOut", FieldTypeOptOut, 0},
+			{"TargetedAdvertisingOptOut", FieldTypeOptOut, 0},
+			{"SensitiveDataProcessingConsents", FieldTypeConsent, 12},
+			{"KnownChildSensitiveDataConsents", FieldTypeConsent, 2},
+			{"PersonalDataConsents", FieldTypeConsent, 0},
+			{"MspaCoveredTransaction", FieldTypeNaYesNo, 0},
+			{"MspaOptOutOptionMode", FieldTypeNaYesNo, 0},
+			{"MspaServiceProviderMode", FieldTypeNaYesNo, 0},
+		},
+		2: {
+			{"Version", FieldTypeInt, 0},
+			{"SharingNotice", FieldTypeNotice, 0},
+			{"SaleOptOutNotice", FieldTypeNotice, 0},
+			{"SharingOptOutNotice", FieldTypeNotice, 0},
+			{"TargetedAdvertisingOptOutNotice", FieldTypeNotice, 0},
+			{"SensitiveDataProcessingOptOutNotice", FieldTypeNotice, 0},
+			{"SensitiveDataLimitUseNotice", FieldTypeNotice, 0},
+			{"SaleOptOut", FieldTypeOptOut, 0},
+			{"SharingOptOut", FieldTypeOptOut, 0},
+			{"TargetedAdvertisingOptOut", FieldTypeOptOut, 0},
+			{"SensitiveDataProcessingConsents", FieldTypeConsent, 16},
+			{"KnownChildSensitiveDataConsents", FieldTypeConsent, 3},
+			{"PersonalDataConsents", FieldTypeConsent, 0},
+			{"MspaCoveredTransaction", FieldTypeNaYesNo, 0},
+			{"MspaOptOutOptionMode", FieldTypeNaYesNo, 0},
+			{"MspaServiceProviderMode", FieldTypeNaYesNo, 0},
+		},
+	},
+	UsCaliforniaSID: {
+		1: {
+			{"Version", FieldTypeInt, 0},
+			{"SaleOptOutNotice", FieldTypeNotice, 0},
+			{"SharingOptOutNotice", FieldTypeNotice, 0},
+			{"SensitiveDataLimitUseNotice", FieldTypeNotice, 0},
+			{"SaleOptOut", FieldTypeOptOut, 0},
+			{"SharingOptOut", FieldTypeOptOut, 0},
+			{"SensitiveDataProcessingOptOuts", FieldTypeOptOut, 9},
+			{"KnownChildSensitiveDataConsents", FieldTypeConsent, 2},
+			{"PersonalDataConsents", FieldTypeConsent, 0},
+			{"MspaCoveredTransaction", FieldTypeNaYesNo, 0},
+			{"MspaOptOutOptionMode", FieldTypeNaYesNo, 0},
+			{"MspaServiceProviderMode", FieldTypeNaYesNo, 0},
+		},
+	},
+	UsVirginiaSID:     {1: mspaStateConsentFields(8, 1, false)},
+	UsColoradoSID:     {1: mspaStateConsentFields(7, 1, false)},
+	UsUtahSID:         {1: mspaStateOptOutFields(8, 1)},
+	UsConnecticutSID:  {1: mspaStateConsentFields(8, 3, false)},
+	UsFloridaSID:      {1: mspaStateConsentFields(8, 3, true)},
+	UsMontanaSID:      {1: mspaStateConsentFields(8, 3, true)},
+	UsOregonSID:       {1: mspaStateConsentFields(11, 3, true)},
+	UsTexasSID:        {1: mspaStateConsentFields(8, 1, true)},
+	UsDelawareSID:     {1: mspaStateConsentFields(9, 5, true)},
+	UsIowaSID:         {1: mspaStateOptOutFields(8, 1)},
+	UsNebraskaSID:     {1: mspaStateConsentFields(8, 1, true)},
+	UsNewHampshireSID: {1: mspaStateConsentFields(8, 3, true)},
+	UsNewJerseySID:    {1: mspaStateConsentFields(10, 5, true)},
+	UsTennesseeSID:    {1: mspaStateConsentFields(8, 1, true)},
+}
+
+// MspaFieldTable returns a copy of the fields of the core segment of each supported version of
+// each MSPA section, by GPP Section ID and version, in the order they are encoded.
+func MspaFieldTable() map[int]map[int][]MspaField {
+	var table = make(map[int]map[int][]MspaField, len(mspaFieldTable))
+	for sid, versions := range mspaFieldTable {
+		table[sid] = make(map[int][]MspaField, len(versions))
+		for version, fields := range versions {
+			table[sid][version] = append([]MspaField(nil), fields...)
+		}
+	}
+	return table
+}
+
+// mspaStateConsentFields returns the fields of the state sections that signal Sensitive Data
+// Processing as consents, which only differ in the number of Sensitive Data Processing and
+// Known Child Sensitive Data fields, and whether Personal Data Consents is encoded.
+func mspaStateConsentFields(sensitiveData, knownChild int, personalData bool) []MspaField {
+	var fields = []MspaField{
+		{"Version", FieldTypeInt, 0},
+		{"SharingNotice", FieldTypeNotice, 0},
+		{"SaleOptOutNotice", FieldTypeNotice, 0},
+		{"TargetedAdvertisingOptOutNotice", FieldTypeNotice, 0},
+		{"SaleOptOut", FieldTypeOptOut, 0},
+		{"TargetedAdvertisingOptOut", FieldTypeOptOut, 0},
+		{"SensitiveDataProcessingConsents", FieldTypeConsent, sensitiveData},
+		{"KnownChildSensitiveDataConsents", FieldTypeConsent, knownChild},
+	}
+	if personalData {
+		fields = append(fields, MspaField{"PersonalDataConsents", FieldTypeConsent, 0})
+	}
+	return append(fields,
+		MspaField{"MspaCoveredTransaction", FieldTypeNaYesNo, 0},
+		MspaField{"MspaOptOutOptionMode", FieldTypeNaYesNo, 0},
+		MspaField{"MspaServiceProviderMode", FieldTypeNaYesNo, 0})
+}
+
+// mspaStateOptOutFields returns the fields of the state sections that signal Sensitive Data
+// Processing as opt-outs, besides California, which only differ in the number of Sensitive
+// Data Processing and Known Child Sensitive Data fields.
+func mspaStateOptOutFields(sensitiveData, knownChild int) []MspaField {
+	return []MspaField{
+		{"Version", FieldTypeInt, 0},
+		{"SharingNotice", FieldTypeNotice, 0},
+		{"SaleOptOutNotice", FieldTypeNotice, 0},
+		{"TargetedAdvertisingOptOutNotice", FieldTypeNotice, 0},
+		{"SensitiveDataProcessingOptOutNotice", FieldTypeNotice, 0},
+		{"SaleOptOut", FieldTypeOptOut, 0},
+		{"TargetedAdvertisingOptOut", FieldTypeOptOut, 0},
+		{"SensitiveDataProcessingOptOuts", FieldTypeOptOut, sensitiveData},
+		{"KnownChildSensitiveDataConsents", FieldTypeConsent, knownChild},
+		{"MspaCoveredTransaction", FieldTypeNaYesNo, 0},
+		{"MspaOptOutOptionMode", FieldTypeNaYesNo, 0},
+		{"MspaServiceProviderMode", FieldTypeNaYesNo, 0},
+	}
+}
+
+// mspaConsentFields lists every field of a MspaParsedConsent, in an order that contains the
+// order of the fields of each section version of mspaFieldTable, followed by Gpc. Fields and
+// NonDefaultFields list the fields in this order. The Count of its bitfields is not used, as
+// it differs between sections.
+var mspaConsentFields = []MspaField{
+	{"Version", FieldTypeInt, 0},
+	{"SharingNotice", FieldTypeNotice, 0},
+	{"SaleOptOutNotice", FieldTypeNotice, 0},
+	{"SharingOptOutNotice", FieldTypeNotice, 0},
+	{"TargetedAdvertisingOptOutNotice", FieldTypeNotice, 0},
+	{"SensitiveDataProcessingOptOutNotice", FieldTypeNotice, 0},
+	{"SensitiveDataLimitUseNotice", FieldTypeNotice, 0},
+	{"SaleOptOut", FieldTypeOptOut, 0},
+	{"SharingOptOut", FieldTypeOptOut, 0},
+	{"TargetedAdvertisingOptOut", FieldTypeOptOut, 0},
+	{"SensitiveDataProcessingConsents", FieldTypeConsent, 0},
+	{"SensitiveDataProcessingOptOuts", FieldTypeOptOut, 0},
+	{"KnownChildSensitiveDataConsents", FieldTypeConsent, 0},
+	{"PersonalDataConsents", FieldTypeConsent, 0},
+	{"MspaCoveredTransaction", FieldTypeNaYesNo, 0},
+	{"MspaOptOutOptionMode", FieldTypeNaYesNo, 0},
+	{"MspaServiceProviderMode", FieldTypeNaYesNo, 0},
+	{"Gpc", FieldTypeBool, 0},
+}
+
+// fieldValue returns the value of the MspaParsedConsent field with the given name, which is not
+// a bitfield, as an int. Gpc is 1 if it is true. 0 is returned for unknown names.
+func (m *MspaParsedConsent) fieldValue(name string) int {
+	switch name {
+	case "Version":
+		return m.Version
+	case "SharingNotice":
+		return int(m.SharingNotice)
+	case "SaleOptOutNotice":
+		return int(m.SaleOptOutNotice)
+	case "SharingOptOutNotice":
+		return int(m.SharingOptOutNotice)
+	case "TargetedAdvertisingOptOutNotice":
+		return int(m.TargetedAdvertisingOptOutNotice)
+	case "SensitiveDataProcessingOptOutNotice":
+		return int(m.SensitiveDataProcessingOptOutNotice)
+	case "SensitiveDataLimitUseNotice":
+		return int(m.SensitiveDataLimitUseNotice)
+	case "SaleOptOut":
+		return int(m.SaleOptOut)
+	case "SharingOptOut":
+		return int(m.SharingOptOut)
+	case "TargetedAdvertisingOptOut":
+		return int(m.TargetedAdvertisingOptOut)
+	case "PersonalDataConsents":
+		return int(m.PersonalDataConsents)
+	case "MspaCoveredTransaction":
+		return int(m.MspaCoveredTransaction)
+	case "MspaOptOutOptionMode":
+		return int(m.MspaOptOutOptionMode)
+	case "MspaServiceProviderMode":
+		return int(m.MspaServiceProviderMode)
+	case "Gpc":
+		if m.Gpc {
+			return 1
+		}
+	}
+	return 0
+}
+
+// setFieldValue sets the MspaParsedConsent field with the given name, which is not a bitfield,
+// to v. Gpc is set to whether v is not 0. Unknown names are ignored.
+func (m *MspaParsedConsent) setFieldValue(name string, v int) {
+	switch name {
+	case "Version":
+		m.Version = v
+	case "SharingNotice":
+		m.SharingNotice = MspaNotice(v)
+	case "SaleOptOutNotice":
+		m.SaleOptOutNotice = MspaNotice(v)
+	case "SharingOptOutNotice":
+		m.SharingOptOutNotice = MspaNotice(v)
+	case "TargetedAdvertisingOptOutNotice":
+		m.TargetedAdvertisingOptOutNotice = MspaNotice(v)
+	case "SensitiveDataProcessingOptOutNotice":
+		m.SensitiveDataProcessingOptOutNotice = MspaNotice(v)
+	case "SensitiveDataLimitUseNotice":
+		m.SensitiveDataLimitUseNotice = MspaNotice(v)
+	case "SaleOptOut":
+		m.SaleOptOut = MspaOptout(v)
+	case "SharingOptOut":
+		m.SharingOptOut = MspaOptout(v)
+	case "TargetedAdvertisingOptOut":
+		m.TargetedAdvertisingOptOut = MspaOptout(v)
+	case "PersonalDataConsents":
+		m.PersonalDataConsents = MspaConsent(v)
+	case "MspaCoveredTransaction":
+		m.MspaCoveredTransaction = MspaNaYesNo(v)
+	case "MspaOptOutOptionMode":
+		m.MspaOptOutOptionMode = MspaNaYesNo(v)
+	case "MspaServiceProviderMode":
+		m.MspaServiceProviderMode = MspaNaYesNo(v)
+	case "Gpc":
+		m.Gpc = v != 0
+	}
+}
+
+// fieldTypeLength returns the length in bits of a field of type t.
+func fieldTypeLength(t FieldType) int {
+	switch t {
+	case FieldTypeInt:
+		return 6
+	case FieldTypeBool:
+		return 1
+	}
+	return 2
+}
+
+// FieldSpec describes where a field of a MSPA section is encoded, e.g. to highlight the bits
+// of each field in a consent string inspector.
+type FieldSpec struct {
+	// The name of the field, as returned by FieldsForVersion.
+	Name string
+	// The segment the field is encoded in, which is the core segment for every field but Gpc.
+	Segment GppSubSectionTypes
+	// The offset of the first bit of the field from the start of its segment. The GPC
+	// subsection starts with its 2 bit SubsectionType, so Gpc is at offset 2.
+	Offset int
+	// The number of bits of the field.
+	Length int
+	// The type of the value of the field.
+	Type FieldType
+}
+
+// SectionSchema returns the FieldSpec of each field encoded by the given version of a MSPA
+// section, in the order of FieldsForVersion, or nil if the section or version is not
+// supported.
+func SectionSchema(sid, version int) []FieldSpec {
+	var table, ok = mspaFieldTable[sid][version]
+	if !ok {
+		return nil
+	}
+	var schema []FieldSpec
+	var offset = 0
+	for _, f := range table {
+		var length = fieldTypeLength(f.Type)
+		if f.Count == 0 {
+			schema = append(schema, FieldSpec{Name: f.Name, Segment: SubSectCore, Offset: offset, Length: length,
+				Type: f.Type})
+			offset += length
+			continue
+		}
+		for i := 0; i < f.Count; i++ {
+			schema = append(schema, FieldSpec{Name: f.Name + "[" + fmt.Sprint(i) + "]", Segment: SubSectCore,
+				Offset: offset, Length: length, Type: f.Type})
+			offset += length
+		}
+	}
+	return append(schema, FieldSpec{Name: "Gpc", Segment: SubSectGpc, Offset: 2, Length: 1, Type: FieldTypeBool})
+}
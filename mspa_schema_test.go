@@ -0,0 +1,144 @@
+package iabconsent_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+func (s *MspaSuite) TestSectionSchema(c *check.C) {
+	var schema = iabconsent.SectionSchema(iabconsent.UsVirginiaSID, 1)
+	c.Assert(schema, check.HasLen, 19)
+	c.Check(schema[:3], check.DeepEquals, []iabconsent.FieldSpec{
+		{Name: "Version", Segment: iabconsent.SubSectCore, Offset: 0, Length: 6, Type: iabconsent.FieldTypeInt},
+		{Name: "SharingNotice", Segment: iabconsent.SubSectCore, Offset: 6, Length: 2, Type: iabconsent.FieldTypeNotice},
+		{Name: "SaleOptOutNotice", Segment: iabconsent.SubSectCore, Offset: 8, Length: 2, Type: iabconsent.FieldTypeNotice},
+	})
+	c.Check(schema[4], check.DeepEquals, iabconsent.FieldSpec{
+		Name: "SaleOptOut", Segment: iabconsent.SubSectCore, Offset: 12, Length: 2, Type: iabconsent.FieldTypeOptOut})
+	c.Check(schema[6], check.DeepEquals, iabconsent.FieldSpec{
+		Name: "SensitiveDataProcessingConsents[0]", Segment: iabconsent.SubSectCore, Offset: 16, Length: 2,
+		Type: iabconsent.FieldTypeConsent})
+	c.Check(schema[15], check.DeepEquals, iabconsent.FieldSpec{
+		Name: "MspaCoveredTransaction", Segment: iabconsent.SubSectCore, Offset: 34, Length: 2,
+		Type: iabconsent.FieldTypeNaYesNo})
+	c.Check(schema[18], check.DeepEquals, iabconsent.FieldSpec{
+		Name: "Gpc", Segment: iabconsent.SubSectGpc, Offset: 2, Length: 1, Type: iabconsent.FieldTypeBool})
+	c.Check(iabconsent.SectionSchema(iabconsent.UsUtahSID, 1)[7].Type, check.Equals, iabconsent.FieldTypeOptOut)
+	c.Check(iabconsent.SectionSchema(iabconsent.UsVirginiaSID, 2), check.IsNil)
+	c.Check(iabconsent.SectionSchema(2, 1), check.IsNil)
+
+	// The bits at the offset of each field decode to the parsed value of the field.
+	for sid, fixtures := range mspaConsentFixtures {
+		for section, expected := range fixtures {
+			c.Log(section)
+
+			var segments = strings.Split(section, ".")
+			var schema = iabconsent.SectionSchema(sid, expected.Version)
+			c.Assert(schema, check.NotNil)
+			var last = schema[len(schema)-2]
+			c.Check(last.Offset+last.Length, check.Equals, expected.ConsumedBits(sid))
+
+			var values = expected.NonDefaultFields()
+			for _, f := range schema {
+				var segment = segments[0]
+				if f.Segment == iabconsent.SubSectGpc {
+					if len(segments) < 2 {
+						continue
+					}
+					segment = segments[1]
+				}
+				var b, err = base64.RawURLEncoding.DecodeString(segment)
+				c.Assert(err, check.IsNil)
+				var r = iabconsent.NewConsentReader(b)
+				c.Assert(r.SkipBits(uint(f.Offset)), check.IsNil)
+				var v int
+				v, err = r.ReadInt(uint(f.Length))
+				c.Assert(err, check.IsNil)
+
+				// NonDefaultFields omits zero values, and formats Gpc as a bool.
+				var value, ok = values[f.Name]
+				if !ok {
+					value = "0"
+				} else if value == "true" {
+					value = "1"
+				}
+				c.Check(fmt.Sprint(v), check.Equals, value, check.Commentf(f.Name))
+			}
+		}
+	}
+}
+
+func (s *MspaSuite) TestMspaFieldTable(c *check.C) {
+	// Every parser of each section version reads exactly the fields of the table: a core segment
+	// with a single field set to 1 parses to a consent with only that field set.
+	for sid, versions := range iabconsent.MspaFieldTable() {
+		for version := range versions {
+			var schema = iabconsent.SectionSchema(sid, version)
+			var core = schema[:len(schema)-1]
+			for _, set := range append([]iabconsent.FieldSpec{{}}, core[1:]...) {
+				c.Logf("section %d version %d field %q", sid, version, set.Name)
+
+				var w = iabconsent.NewConsentWriter()
+				w.WriteInt(version, 6)
+				for _, f := range core[1:] {
+					var v = 0
+					if f.Name == set.Name {
+						v = 1
+					}
+					w.WriteInt(v, uint(f.Length))
+				}
+				var section = base64.RawURLEncoding.EncodeToString(w.Bytes())
+
+				var expected = map[string]string{"Version": fmt.Sprint(version)}
+				if set.Name != "" {
+					expected[set.Name] = "1"
+				}
+				var p, err = iabconsent.NewMspa(sid, section).ParseConsent()
+				c.Assert(err, check.IsNil)
+				c.Check(p.(*iabconsent.MspaParsedConsent).NonDefaultFields(), check.DeepEquals, expected)
+				var compact, lazy *iabconsent.MspaParsedConsent
+				compact, err = iabconsent.ParseMspaCompact(sid, section)
+				c.Assert(err, check.IsNil)
+				c.Check(compact.NonDefaultFields(), check.DeepEquals, expected)
+				lazy, err = iabconsent.ParseMspaLazy(sid, section)
+				c.Assert(err, check.IsNil)
+				c.Check(lazy.NonDefaultFields(), check.DeepEquals, expected)
+			}
+		}
+	}
+
+	// Fields lists the fields of every section version in the order they are encoded.
+	var all = &iabconsent.MspaParsedConsent{
+		SensitiveDataProcessingConsents: map[int]iabconsent.MspaConsent{0: iabconsent.Consent},
+		SensitiveDataProcessingOptOuts:  map[int]iabconsent.MspaOptout{0: iabconsent.OptedOut},
+		KnownChildSensitiveDataConsents: map[int]iabconsent.MspaConsent{0: iabconsent.Consent},
+	}
+	var order []string
+	for _, kv := range all.Fields() {
+		order = append(order, strings.TrimSuffix(kv.Key, "[0]"))
+	}
+	for sid, versions := range iabconsent.MspaFieldTable() {
+		for version, fields := range versions {
+			var next = 0
+			for _, f := range fields {
+				for next < len(order) && order[next] != f.Name {
+					next++
+				}
+				c.Check(next < len(order), check.Equals, true,
+					check.Commentf("section %d version %d field %q", sid, version, f.Name))
+			}
+		}
+	}
+
+	// The table is copied, so it cannot be modified.
+	var table = iabconsent.MspaFieldTable()
+	table[iabconsent.UsNationalSID][1][0].Name = "Modified"
+	delete(table, iabconsent.UsCaliforniaSID)
+	c.Check(iabconsent.MspaFieldTable()[iabconsent.UsNationalSID][1][0].Name, check.Equals, "Version")
+	c.Check(iabconsent.MspaFieldTable()[iabconsent.UsCaliforniaSID], check.HasLen, 1)
+}
@@ -3,7 +3,7 @@ package iabconsent
 import (
 	"encoding/base64"
 	"fmt"
-	"strings"
+	"io"
 
 	"github.com/pkg/errors"
 )
@@ -45,66 +45,383 @@ const (
 	MspaUsTnV1StringLength = 48
 )
 
+// mspaSectionLayout describes the size of a version of a MSPA section.
+type mspaSectionLayout struct {
+	// The valid string length in bits, including padding.
+	length int
+	// The number of Sensitive Data Processing fields.
+	sensitiveData uint
+	// The number of Known Child Sensitive Data Consents fields.
+	knownChild uint
+	// The fields of the core segment, in the order they are encoded.
+	fields []MspaField
+}
+
+// mspaSectionLayouts maps each supported GPP Section ID and version to its layout, as
+// described by mspaFieldTable.
+var mspaSectionLayouts = mspaLayoutsOf(mspaFieldTable)
+
+// mspaLayoutsOf returns the layout of each section version of a field table. The valid
+// string length is the length of the fields, padded to whole bytes.
+func mspaLayoutsOf(table map[int]map[int][]MspaField) map[int]map[int]mspaSectionLayout {
+	var layouts = make(map[int]map[int]mspaSectionLayout, len(table))
+	for sid, versions := range table {
+		layouts[sid] = make(map[int]mspaSectionLayout, len(versions))
+		for version, fields := range versions {
+			var layout = mspaSectionLayout{fields: fields}
+			for _, f := range fields {
+				var count = f.Count
+				if count == 0 {
+					count = 1
+				}
+				layout.length += count * fieldTypeLength(f.Type)
+				switch f.Name {
+				case "SensitiveDataProcessingConsents", "SensitiveDataProcessingOptOuts":
+					layout.sensitiveData = uint(f.Count)
+				case "KnownChildSensitiveDataConsents":
+					layout.knownChild = uint(f.Count)
+				}
+			}
+			layout.length = (layout.length + 7) / 8 * 8
+			layouts[sid][version] = layout
+		}
+	}
+	return layouts
+}
+
+// usesSensitiveDataOptOuts returns true if the GPP Section ID signals Sensitive Data
+// Processing as opt-outs, rather than consents.
+func usesSensitiveDataOptOuts(sid int) bool {
+	for _, fields := range mspaFieldTable[sid] {
+		for _, f := range fields {
+			if f.Name == "SensitiveDataProcessingOptOuts" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MspaSensitiveDataCount returns the number of Sensitive Data Processing fields in the given
+// version of a MSPA section, or 0 if the section or version is not supported.
+func MspaSensitiveDataCount(sid, version int) int {
+	return int(mspaSectionLayouts[sid][version].sensitiveData)
+}
+
+// FieldsForVersion returns the names of the MspaParsedConsent fields encoded by the given
+// version of a MSPA section, in the order they are encoded, or nil if the section or version
+// is not supported. Each Sensitive Data Processing and Known Child Sensitive Data field is
+// listed by its zero-based map key, e.g. "SensitiveDataProcessingConsents[11]", as the number
+// of these fields differs between versions. Gpc is always listed last, as every MSPA section
+// supports the GPC subsection.
+func FieldsForVersion(sid, version int) []string {
+	var schema = SectionSchema(sid, version)
+	if schema == nil {
+		return nil
+	}
+	var fields = make([]string, len(schema))
+	for i, f := range schema {
+		fields[i] = f.Name
+	}
+	return fields
+}
+
+// ConsumedBits returns the number of bits of the core segment used by the fields of the parsed
+// consent, when parsed from the section of the given GPP Section ID, or 0 if the section or
+// version is not supported. The core segment may be longer, as it is padded to a whole number of
+// bytes, so any bits after ConsumedBits are padding or trailing data.
+func (m *MspaParsedConsent) ConsumedBits(sid int) int {
+	var fields = FieldsForVersion(sid, m.Version)
+	if len(fields) < 2 {
+		return 0
+	}
+	// The 6 bit Version, and 2 bits for every other field besides Gpc, which is a subsection.
+	return 6 + 2*(len(fields)-2)
+}
+
+// NewOptedOutMspaConsent returns the most private consent that can be encoded by the latest
+// version of a MSPA section, e.g. as a safe default for users in a regulated state without a
+// consent string. Every notice that the section encodes was provided, every opt-out is Opted
+// Out, every consent is No Consent, GPC is signaled, and the transaction is not a MSPA Covered
+// Transaction. nil is returned if the section is not supported.
+func NewOptedOutMspaConsent(sid int) *MspaParsedConsent {
+	var version = latestMspaVersion(sid)
+	if version == 0 {
+		return nil
+	}
+	var m = &MspaParsedConsent{
+		Version:                version,
+		MspaCoveredTransaction: MspaNo,
+		Gpc:                    true,
+		GpcPresent:             true,
+	}
+	for _, f := range mspaFieldTable[sid][version][1:] {
+		switch f.Name {
+		case "SensitiveDataProcessingConsents":
+			m.SensitiveDataProcessingConsents = make(map[int]MspaConsent, f.Count)
+			for i := 0; i < f.Count; i++ {
+				m.SensitiveDataProcessingConsents[i] = NoConsent
+			}
+		case "SensitiveDataProcessingOptOuts":
+			m.SensitiveDataProcessingOptOuts = make(map[int]MspaOptout, f.Count)
+			for i := 0; i < f.Count; i++ {
+				m.SensitiveDataProcessingOptOuts[i] = OptedOut
+			}
+		case "KnownChildSensitiveDataConsents":
+			m.KnownChildSensitiveDataConsents = make(map[int]MspaConsent, f.Count)
+			for i := 0; i < f.Count; i++ {
+				m.KnownChildSensitiveDataConsents[i] = NoConsent
+			}
+		default:
+			switch f.Type {
+			case FieldTypeNotice:
+				m.setFieldValue(f.Name, int(NoticeProvided))
+			case FieldTypeOptOut:
+				m.setFieldValue(f.Name, int(OptedOut))
+			case FieldTypeConsent:
+				m.setFieldValue(f.Name, int(NoConsent))
+			}
+		}
+	}
+	return m
+}
+
+// latestMspaVersion returns the latest supported version of a MSPA section, or 0 if the
+// section is not supported.
+func latestMspaVersion(sid int) int {
+	var latest = 0
+	for v := range mspaSectionLayouts[sid] {
+		if v > latest {
+			latest = v
+		}
+	}
+	return latest
+}
+
+const (
+	sdRacialOrEthnicOrigin     = "racial or ethnic origin"
+	sdReligiousBeliefs         = "religious beliefs"
+	sdReligiousOrPhilosophical = "religious or philosophical beliefs"
+	sdHealth                   = "mental or physical health condition or diagnosis"
+	sdSexLifeOrOrientation     = "sex life or sexual orientation"
+	sdCitizenshipOrImmigration = "citizenship or immigration status"
+	sdGenetic                  = "genetic unique identification"
+	sdBiometric                = "biometric unique identification"
+	sdGeneticOrBiometric       = "genetic or biometric data"
+	sdPreciseGeolocation       = "precise geolocation"
+	sdIdentificationDocuments  = "identification documents"
+	sdFinancialAccount         = "financial account information"
+	sdUnionMembership          = "union membership"
+	sdCommunicationContents    = "mail, email, or text message contents"
+	sdNationalOrigin           = "national origin"
+	sdTransgenderOrNonbinary   = "transgender or nonbinary status"
+	sdCrimeVictim              = "crime victim status"
+	sdConsumerHealthData       = "consumer health data"
+	sdRacialReligiousOrUnion   = "racial or ethnic origin, religious or philosophical beliefs, or union membership"
+	sdHealthInformation        = "health information"
+	sdFinancialInformation     = "financial information"
+)
+
+// sensitiveDataCategories are the meanings of each Sensitive Data Processing index for a
+// given GPP Section ID, in the order defined by each section's specification. Indexes
+// only added in later versions of a section are appended to the end.
+var sensitiveDataCategories = map[int][]string{
+	UsNationalSID: {
+		sdRacialOrEthnicOrigin, sdReligiousOrPhilosophical, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+		sdIdentificationDocuments, sdFinancialAccount, sdUnionMembership, sdCommunicationContents,
+		// Version 2.
+		sdTransgenderOrNonbinary, sdNationalOrigin, sdCrimeVictim, sdConsumerHealthData,
+	},
+	UsCaliforniaSID: {
+		sdIdentificationDocuments, sdFinancialAccount, sdPreciseGeolocation, sdRacialReligiousOrUnion,
+		sdCommunicationContents, sdGenetic, sdBiometric, sdHealthInformation, sdSexLifeOrOrientation,
+	},
+	UsVirginiaSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsColoradoSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric,
+	},
+	UsUtahSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdSexLifeOrOrientation, sdCitizenshipOrImmigration,
+		sdHealth, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsConnecticutSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsFloridaSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsMontanaSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsOregonSID: {
+		sdRacialOrEthnicOrigin, sdNationalOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdTransgenderOrNonbinary, sdCrimeVictim, sdCitizenshipOrImmigration, sdGenetic, sdBiometric,
+		sdPreciseGeolocation,
+	},
+	UsTexasSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsDelawareSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdTransgenderOrNonbinary, sdNationalOrigin, sdCitizenshipOrImmigration, sdGeneticOrBiometric,
+		sdPreciseGeolocation,
+	},
+	UsIowaSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsNebraskaSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsNewHampshireSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+	UsNewJerseySID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+		sdTransgenderOrNonbinary, sdFinancialInformation,
+	},
+	UsTennesseeSID: {
+		sdRacialOrEthnicOrigin, sdReligiousBeliefs, sdHealth, sdSexLifeOrOrientation,
+		sdCitizenshipOrImmigration, sdGenetic, sdBiometric, sdPreciseGeolocation,
+	},
+}
+
+// SensitiveDataCategoryName returns the human readable category of Sensitive Data for the
+// zero-based index of the SensitiveDataProcessingConsents or SensitiveDataProcessingOptOuts
+// of the given GPP Section ID. The same index has different meanings for different sections,
+// e.g. index 7 is precise geolocation for US National, but health information for California. An empty string is returned for unsupported sections or indexes.
+func SensitiveDataCategoryName(sid, index int) string {
+	var categories = sensitiveDataCategories[sid]
+	if index < 0 || index >= len(categories) {
+		return ""
+	}
+	return categories[index]
+}
+
+// The zero-based KnownChildSensitiveDataConsents indexes, or brackets, of the US National
+// section, for use with MspaParsedConsent.ChildConsent, in the order the US National spec lists
+// them. The age bracket of an index differs between sections.
+const (
+	// UsNatChild13To16 is the consent to process the data of consumers from age 13 to 16.
+	UsNatChild13To16 = 0
+	// UsNatChildUnder13 is the consent to process the data of consumers younger than 13.
+	UsNatChildUnder13 = 1
+)
+
+// knownChildUnder13Bracket returns the bracket of the consent for a known child younger than
+// 13 of the given GPP Section ID, or -1 if the bracket of the section is not known. Only the
+// US National brackets are named, so -1 is returned for every other section.
+func knownChildUnder13Bracket(sid int) int {
+	if sid == UsNationalSID {
+		return UsNatChildUnder13
+	}
+	return -1
+}
+
+// MspaUsNational is the GppSectionParser of the US National section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/blob/main/Sections/US-National/IAB%20Privacy%E2%80%99s%20Multi-State%20Privacy%20Agreement%20(MSPA)%20US%20National%20Technical%20Specification.md
 type MspaUsNational struct {
 	GppSection
 }
 
+// MspaUsCA is the GppSectionParser of the California section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/CA
 type MspaUsCA struct {
 	GppSection
 }
 
+// MspaUsVA is the GppSectionParser of the Virginia section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/VA
 type MspaUsVA struct {
 	GppSection
 }
 
+// MspaUsCO is the GppSectionParser of the Colorado section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/CO
 type MspaUsCO struct {
 	GppSection
 }
 
+// MspaUsUT is the GppSectionParser of the Utah section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/UT
 type MspaUsUT struct {
 	GppSection
 }
 
+// MspaUsCT is the GppSectionParser of the Connecticut section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/CT
 type MspaUsCT struct {
 	GppSection
 }
 
+// MspaUsFL is the GppSectionParser of the Florida section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/FL
 type MspaUsFL struct {
 	GppSection
 }
 
+// MspaUsMT is the GppSectionParser of the Montana section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/MT
 type MspaUsMT struct {
 	GppSection
 }
 
+// MspaUsOR is the GppSectionParser of the Oregon section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/OR
 type MspaUsOR struct {
 	GppSection
 }
 
+// MspaUsTX is the GppSectionParser of the Texas section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/TX
 type MspaUsTX struct {
 	GppSection
 }
 
+// MspaUsDE is the GppSectionParser of the Delaware section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/DE
 type MspaUsDE struct {
 	GppSection
 }
 
+// MspaUsIA is the GppSectionParser of the Iowa section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/IA
 type MspaUsIA struct {
 	GppSection
 }
 
+// MspaUsNE is the GppSectionParser of the Nebraska section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/NE
 type MspaUsNE struct {
 	GppSection
 }
 
+// MspaUsNH is the GppSectionParser of the New Hampshire section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/NH
 type MspaUsNH struct {
 	GppSection
 }
 
+// MspaUsNJ is the GppSectionParser of the New Jersey section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/NJ
 type MspaUsNJ struct {
 	GppSection
 }
 
+// MspaUsTN is the GppSectionParser of the Tennessee section, whose spec can be found here:
+// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/TN
 type MspaUsTN struct {
 	GppSection
 }
@@ -112,861 +429,176 @@ type MspaUsTN struct {
 // NewMspa returns a supported parser given a GPP Section ID.
 // If the SID is not yet supported, it will be null.
 func NewMspa(sid int, section string) GppSectionParser {
-	switch sid {
-	case UsNationalSID:
-		return &MspaUsNational{GppSection{sectionId: UsNationalSID, sectionValue: section}}
-	case UsCaliforniaSID:
-		return &MspaUsCA{GppSection{sectionId: UsCaliforniaSID, sectionValue: section}}
-	case UsVirginiaSID:
-		return &MspaUsVA{GppSection{sectionId: UsVirginiaSID, sectionValue: section}}
-	case UsColoradoSID:
-		return &MspaUsCO{GppSection{sectionId: UsColoradoSID, sectionValue: section}}
-	case UsUtahSID:
-		return &MspaUsUT{GppSection{sectionId: UsUtahSID, sectionValue: section}}
-	case UsConnecticutSID:
-		return &MspaUsCT{GppSection{sectionId: UsConnecticutSID, sectionValue: section}}
-	case UsFloridaSID:
-		return &MspaUsFL{GppSection{sectionId: UsFloridaSID, sectionValue: section}}
-	case UsMontanaSID:
-		return &MspaUsMT{GppSection{sectionId: UsMontanaSID, sectionValue: section}}
-	case UsOregonSID:
-		return &MspaUsOR{GppSection{sectionId: UsOregonSID, sectionValue: section}}
-	case UsTexasSID:
-		return &MspaUsTX{GppSection{sectionId: UsTexasSID, sectionValue: section}}
-	case UsDelawareSID:
-		return &MspaUsDE{GppSection{sectionId: UsDelawareSID, sectionValue: section}}
-	case UsIowaSID:
-		return &MspaUsIA{GppSection{sectionId: UsIowaSID, sectionValue: section}}
-	case UsNebraskaSID:
-		return &MspaUsNE{GppSection{sectionId: UsNebraskaSID, sectionValue: section}}
-	case UsNewHampshireSID:
-		return &MspaUsNH{GppSection{sectionId: UsNewHampshireSID, sectionValue: section}}
-	case UsNewJerseySID:
-		return &MspaUsNJ{GppSection{sectionId: UsNewJerseySID, sectionValue: section}}
-	case UsTennesseeSID:
-		return &MspaUsTN{GppSection{sectionId: UsTennesseeSID, sectionValue: section}}
+	var info = gppSections[sid]
+	if info.newParser == nil {
+		// Skip if no parser is registered, as Section ID is not supported yet.
+		// Any newly supported Section IDs should be added to gppSections.
+		return nil
 	}
-	// Skip if no matching struct, as Section ID is not supported yet.
-	// Any newly supported Section IDs should be added as cases here.
-	return nil
+	return info.newParser(GppSection{sectionId: sid, sectionValue: section})
 }
 
 func (m *MspaUsNational) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usnat consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/blob/main/Sections/US-National/IAB%20Privacy%E2%80%99s%20Multi-State%20Privacy%20Agreement%20(MSPA)%20US%20National%20Technical%20Specification.md
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	// Support both v1 and v2
-	if p.Version != 1 && p.Version != 2 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1 and v2
-
-	if p.Version == 1 && r.Size() != MspaUsNationalV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	} else if p.Version == 2 && r.Size() != MspaUsNationalV2StringLength {
-		return nil, errors.New("invalid consent string length for v2")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.SharingOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SensitiveDataProcessingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SensitiveDataLimitUseNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.SharingOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-
-	// see spec in IAB GPP repo for differences between v1 and v2
-	if p.Version == 1 {
-		p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(12)
-		p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(2)
-	} else if p.Version == 2 {
-		p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(16)
-		p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(3)
-	}
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsCA) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usca consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/CA
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsCaV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.SharingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SensitiveDataLimitUseNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.SharingOptOut, _ = r.ReadMspaOptOut()
-	// SensitiveDataProcessingOptOuts, as opposed to Consent.
-	p.SensitiveDataProcessingOptOuts, _ = r.ReadMspaBitfieldOptOut(9)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(2)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsVA) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usva consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/VA
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsVaV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(1)
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsCO) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usco consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/CO
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsCoV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(7)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(1)
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsUT) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usut consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/UT
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsUtV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SensitiveDataProcessingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingOptOuts, _ = r.ReadMspaBitfieldOptOut(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(1)
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsCT) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usct consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/CT
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsCtV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(3)
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsFL) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usfl consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/FL
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsFlV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(3)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsMT) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usmt consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/MT
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsMtV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(3)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsOR) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usor consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/OR
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsOrV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(11)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(3)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsTX) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse ustx consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/TX
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsTxV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(1)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsDE) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usde consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/DE
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsDeV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(9)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(5)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	// 0 is not a valid value according to the docs for MspaCoveredTransaction. Instead of erroring,
-	// return the value of the string, and let downstream processing handle if the value is 0.
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
-// Fix Iowa implementation
 func (m *MspaUsIA) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usia consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/IA
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsIaV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SensitiveDataProcessingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingOptOuts, _ = r.ReadMspaBitfieldOptOut(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(1)
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsNE) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usne consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/NE
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsNeV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
-	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(1)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
-	}
-
-	return p, r.Err
+	return parseMspaConsent(m.sectionId, m.sectionValue)
 }
 
 func (m *MspaUsNH) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse usnh consent string")
-	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/NH
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
+	return parseMspaConsent(m.sectionId, m.sectionValue)
+}
 
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
-	}
+func (m *MspaUsNJ) ParseConsent() (GppParsedConsent, error) {
+	return parseMspaConsent(m.sectionId, m.sectionValue)
+}
 
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsNhV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
+func (m *MspaUsTN) ParseConsent() (GppParsedConsent, error) {
+	return parseMspaConsent(m.sectionId, m.sectionValue)
+}
+
+// parseMspaConsent parses the MSPA section string s of the given GPP Section ID for the
+// ParseConsent methods of the sections. If only the subsections fail to parse, the consent of
+// the core segment is returned with the error.
+func parseMspaConsent(sid int, s string) (GppParsedConsent, error) {
+	var p = &MspaParsedConsent{KnownChildSensitiveDataConsents: make(map[int]MspaConsent)}
+	if usesSensitiveDataOptOuts(sid) {
+		p.SensitiveDataProcessingOptOuts = make(map[int]MspaOptout)
+	} else {
+		p.SensitiveDataProcessingConsents = make(map[int]MspaConsent)
+	}
+	var decoded, err = parseMspaInto(sid, s, p)
+	if !decoded {
+		return nil, err
+	}
+	return p, err
+}
+
+// EncodeMspaSection takes a MspaParsedConsent and encodes it into the base64 Raw URL Encoded
+// string for the given GPP Section ID, including any GPC subsection. It is the inverse of
+// parsing the section with NewMspa(sid, s).ParseConsent().
+//
+// The spec leaves some of the string's representation open, so the following canonical
+// form is chosen:
+//   - The core segment is padded with 0s up to the valid string length of the section version
+//     (e.g. MspaUsNationalV1StringLength), which is the smallest number of whole bytes.
+//   - The GPC subsection is only appended when Gpc or GpcPresent is true, so a GPC subsection
+//     explicitly set to false is kept, and an absent one is not added.
+func EncodeMspaSection(sid int, p *MspaParsedConsent) (string, error) {
+	var b, err = encodeMspaCore(sid, p)
+	if err != nil {
+		return "", err
 	}
 
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(3)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
+	var s = base64.RawURLEncoding.EncodeToString(b)
+	if p.Gpc || p.GpcPresent {
+		s += "." + encodeGpcSubsection(p.Gpc)
 	}
-
-	return p, r.Err
+	return s, nil
 }
 
-func (m *MspaUsNJ) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
+// EncodeMspaTo encodes a MspaParsedConsent like EncodeMspaSection, but writes the string to w
+// instead of returning it, e.g. to write many strings to a file without building each one.
+// Nothing is written if the consent can not be encoded, and errors of w are returned.
+func EncodeMspaTo(w io.Writer, sid int, p *MspaParsedConsent) error {
+	var b, err = encodeMspaCore(sid, p)
 	if err != nil {
-		return nil, errors.Wrap(err, "parse usnj consent string")
+		return err
 	}
 
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/NJ
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
+	var enc = base64.NewEncoder(base64.RawURLEncoding, w)
+	if _, err = enc.Write(b); err == nil {
+		err = enc.Close()
 	}
-
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsNjV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
+	if err == nil && (p.Gpc || p.GpcPresent) {
+		_, err = io.WriteString(w, "."+encodeGpcSubsection(p.Gpc))
 	}
-
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(10)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(5)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
-		}
-		p.Gpc = gppSubsectionConsent.Gpc
+	if err != nil {
+		return errors.Wrap(err, "write mspa consent string")
 	}
-
-	return p, r.Err
+	return nil
 }
 
-func (m *MspaUsTN) ParseConsent() (GppParsedConsent, error) {
-	var segments = strings.Split(m.sectionValue, ".")
-
-	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
-	if err != nil {
-		return nil, errors.Wrap(err, "parse ustn consent string")
+// encodeMspaCore returns the bytes of the core segment of a MspaParsedConsent for the given
+// GPP Section ID, padded to the valid string length of its version.
+func encodeMspaCore(sid int, p *MspaParsedConsent) ([]byte, error) {
+	var versions, ok = mspaSectionLayouts[sid]
+	if !ok {
+		return nil, errors.New("unsupported section id: " + fmt.Sprint(sid))
 	}
-
-	var r = NewConsentReader(b)
-
-	// This block of code directly describes the format of the payload.
-	// The spec for the consent string can be found here:
-	// https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform/tree/main/Sections/US-States/TN
-	var p = &MspaParsedConsent{}
-	p.Version, _ = r.ReadInt(6)
-
-	if p.Version != 1 {
-		return nil, errors.New("unsupported version: " + fmt.Sprint(p.Version))
+	var layout mspaSectionLayout
+	if layout, ok = versions[p.Version]; !ok {
+		return nil, ErrUnsupportedVersion{SectionID: sid, Version: p.Version}
 	}
 
-	// validate the length of the bit string for v1
-	if p.Version == 1 && r.Size() != MspaUsTnV1StringLength {
-		return nil, errors.New("invalid consent string length for v1")
+	p = p.DecodeSensitiveData()
+
+	var w = NewConsentWriter()
+	writeMspaFields(w, p, layout.fields)
+	w.WritePadding(layout.length)
+	if w.Err != nil {
+		return nil, errors.Wrap(w.Err, "encode mspa consent string")
 	}
+	return w.Bytes(), nil
+}
 
-	p.SharingNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOutNotice, _ = r.ReadMspaNotice()
-	p.TargetedAdvertisingOptOutNotice, _ = r.ReadMspaNotice()
-	p.SaleOptOut, _ = r.ReadMspaOptOut()
-	p.TargetedAdvertisingOptOut, _ = r.ReadMspaOptOut()
-	p.SensitiveDataProcessingConsents, _ = r.ReadMspaBitfieldConsent(8)
-	p.KnownChildSensitiveDataConsents, _ = r.ReadMspaBitfieldConsent(1)
-	p.PersonalDataConsents, _ = r.ReadMspaConsent()
-	p.MspaCoveredTransaction, _ = r.ReadMspaNaYesNo()
-	p.MspaOptOutOptionMode, _ = r.ReadMspaNaYesNo()
-	p.MspaServiceProviderMode, _ = r.ReadMspaNaYesNo()
-
-	if len(segments) > 1 {
-		var gppSubsectionConsent *GppSubSection
-		gppSubsectionConsent, err = ParseGppSubSections(segments[1:])
-		if err != nil {
-			return p, err
+// writeMspaFields writes the given fields of a core segment from p.
+func writeMspaFields(w *ConsentWriter, p *MspaParsedConsent, fields []MspaField) {
+	for _, f := range fields {
+		switch f.Name {
+		case "SensitiveDataProcessingConsents":
+			w.WriteMspaBitfieldConsent(p.SensitiveDataProcessingConsents, uint(f.Count))
+		case "SensitiveDataProcessingOptOuts":
+			w.WriteMspaBitfieldOptOut(p.SensitiveDataProcessingOptOuts, uint(f.Count))
+		case "KnownChildSensitiveDataConsents":
+			w.WriteMspaBitfieldConsent(p.KnownChildSensitiveDataConsents, uint(f.Count))
+		default:
+			w.WriteInt(p.fieldValue(f.Name), uint(fieldTypeLength(f.Type)))
 		}
-		p.Gpc = gppSubsectionConsent.Gpc
 	}
-
-	return p, r.Err
 }
@@ -0,0 +1,262 @@
+package iabconsent
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ConsolidatedConsent combines the consent signals of a request, such as those of an
+// OpenRTB 2.x bid request, into a single struct. The signals are kept as received, along
+// with their parsed values.
+type ConsolidatedConsent struct {
+	// The GPP string, e.g. from regs.gpp or regs.ext.gpp.
+	Gpp string
+	// The GPP Section IDs applicable to the request, e.g. from regs.gpp_sid.
+	GppSID []int
+	// The parsed GPP sections applicable to the request, keyed by Section ID. When GppSID
+	// is set, only the listed sections are applicable, otherwise every parsed section is.
+	GppSections map[int]GppParsedConsent
+	// The legacy US Privacy string, e.g. from regs.us_privacy or regs.ext.us_privacy.
+	UsPrivacy string
+	// The parsed legacy US Privacy string. GPP takes precedence over the legacy string, so
+	// this is nil when an applicable GPP section covers the US, even if UsPrivacy is set.
+	CCPA *CCPAConsent
+	// Whether GDPR applies, e.g. from regs.gdpr: "1" if it applies, "0" if it does not, or
+	// empty if unknown.
+	Gdpr string
+	// The TCF v2 consent string, e.g. from user.consent or user.ext.consent. It is not part of
+	// the regs object, so it is not set by ParseOpenRTBRegs.
+	TcfConsent string
+	// The parsed TCF v2 consent string, or nil if TcfConsent is not set, or GDPR does not
+	// apply.
+	TcfV2 *V2ParsedConsent
+}
+
+// ParseOpenRTBRegs parses the consent signals of an OpenRTB 2.x bid request's regs object
+// into a ConsolidatedConsent. Any of the signals may be empty, in which case they are skipped.
+//
+// Per IAB guidance, GPP takes precedence over the legacy US Privacy string: when a GPP
+// section covering the US (US Privacy or any MSPA section) is applicable, the legacy string
+// is not parsed.
+func ParseOpenRTBRegs(gpp string, gppSID []int, usPrivacy string) (*ConsolidatedConsent, error) {
+	var c = &ConsolidatedConsent{
+		Gpp:         gpp,
+		GppSID:      gppSID,
+		GppSections: make(map[int]GppParsedConsent),
+		UsPrivacy:   usPrivacy,
+	}
+
+	if CleanGppString(gpp) != "" {
+		var sections, err = ParseGppConsent(gpp)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse gpp")
+		}
+		for sid, section := range sections {
+			if len(gppSID) == 0 || containsSID(gppSID, sid) {
+				c.GppSections[sid] = section
+			}
+		}
+	}
+
+	if strings.TrimSpace(usPrivacy) != "" && !c.hasUsGppSection() {
+		var ccpa, err = ParseCCPA(strings.TrimSpace(usPrivacy))
+		if err != nil {
+			return nil, errors.Wrap(err, "parse us privacy")
+		}
+		c.CCPA = ccpa
+	}
+
+	return c, nil
+}
+
+// ParseSignals parses a map of consent signals, as passed to bid adapters, e.g. by Prebid
+// Server, into a ConsolidatedConsent. The recognized keys are:
+//   - "gpp": the GPP string.
+//   - "gpp_sid": the comma separated GPP Section IDs applicable to the request, e.g. "7,8".
+//   - "gdpr": "1" if GDPR applies, or "0" if it does not.
+//   - "gdpr_consent": the TCF v2 consent string, which is not parsed if GDPR does not apply.
+//   - "us_privacy": the legacy US Privacy string.
+//
+// Keys that are missing or empty are skipped, and other keys are ignored. The GPP and US
+// Privacy signals are parsed, with the same precedence, as by ParseOpenRTBRegs.
+func ParseSignals(signals map[string]string) (*ConsolidatedConsent, error) {
+	var gppSID []int
+	for _, v := range strings.Split(signals["gpp_sid"], ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		var sid, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.New("parse gpp_sid: invalid section id " + v)
+		}
+		gppSID = append(gppSID, sid)
+	}
+
+	var c, err = ParseOpenRTBRegs(signals["gpp"], gppSID, signals["us_privacy"])
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Gdpr = strings.TrimSpace(signals["gdpr"]); c.Gdpr {
+	case "", "0", "1":
+	default:
+		return nil, errors.New("parse gdpr: invalid value " + c.Gdpr)
+	}
+	if c.TcfConsent = signals["gdpr_consent"]; c.Gdpr != "0" && strings.TrimSpace(c.TcfConsent) != "" {
+		if c.TcfV2, err = ParseV2(strings.TrimSpace(c.TcfConsent)); err != nil {
+			return nil, errors.Wrap(err, "parse gdpr_consent")
+		}
+	}
+	return c, nil
+}
+
+// ToOpenRTBRegs serializes the consent back into the fields of an OpenRTB 2.x regs object, e.g.
+// to normalize the signals of a request before sending them downstream:
+//   - gpp is a GPP string of only the applicable GppSections, with a new header. MSPA sections
+//     are re-encoded with EncodeMspaSection, and other sections are copied from Gpp. Sections
+//     that can be neither encoded nor copied are dropped.
+//   - gppSID is the Section IDs of the sections of gpp, in ascending order.
+//   - usPrivacy is UsPrivacy, if it was parsed into CCPA.
+//
+// When the legacy US Privacy string was parsed, and no GPP section covers the US, it is also
+// translated into a usnat section of gpp, see CCPAToUsnatDecision, for bidders that only accept
+// GPP. Empty values are returned for signals that are not set.
+func (c *ConsolidatedConsent) ToOpenRTBRegs() (gpp string, gppSID []int, usPrivacy string) {
+	var sections = make(map[int]string, len(c.GppSections)+1)
+	var raw = rawGppSections(c.Gpp)
+	for sid, section := range c.GppSections {
+		if m, ok := section.(*MspaParsedConsent); ok {
+			if encoded, err := EncodeMspaSection(sid, m); err == nil {
+				sections[sid] = encoded
+				continue
+			}
+		}
+		if r, ok := raw[sid]; ok {
+			sections[sid] = r
+		}
+	}
+
+	if c.CCPA != nil {
+		usPrivacy = strings.TrimSpace(c.UsPrivacy)
+		if !c.hasUsGppSection() {
+			var d = CCPAToUsnatDecision(*c.CCPA)
+			// The values of a decision are always valid, so this can not fail.
+			sections[UsNationalSID], _ = NewMspaConsentBuilder().
+				SetSaleOptOutNotice(d.SaleOptOutNotice).
+				SetSaleOptOut(d.SaleOptOut).
+				SetMspaCoveredTransaction(d.CoveredTransaction).
+				Build(UsNationalSID)
+		}
+	}
+
+	if len(sections) == 0 {
+		return "", nil, usPrivacy
+	}
+	for sid := range sections {
+		gppSID = append(gppSID, sid)
+	}
+	sort.Ints(gppSID)
+	// The Section IDs are the positive IDs of parsed sections, so this can not fail.
+	gpp, _ = BuildGppString(nil, sections)
+	return gpp, gppSID, usPrivacy
+}
+
+// rawGppSections returns the unparsed sections of the GPP string gpp, keyed by Section ID, or
+// nil if its header can not be read.
+func rawGppSections(gpp string) map[int]string {
+	var header, segments, err = SplitGppSegments(gpp)
+	if err != nil {
+		return nil
+	}
+	var h *GppHeader
+	if h, err = ParseGppHeader(header); err != nil || len(h.Sections) != len(segments) {
+		return nil
+	}
+	var sections = make(map[int]string, len(segments))
+	for i, sid := range h.Sections {
+		sections[sid] = segments[i]
+	}
+	return sections
+}
+
+// ErrSIDMismatch is returned by VerifySIDs when the GPP Section IDs declared by gpp_sid do not
+// match the sections of the GPP string.
+type ErrSIDMismatch struct {
+	// The declared Section IDs that are not in the GPP string.
+	Missing []int
+	// The Section IDs of the GPP string that are not declared.
+	Extra []int
+}
+
+func (e *ErrSIDMismatch) Error() string {
+	var s = "gpp_sid mismatch"
+	if len(e.Missing) > 0 {
+		s += ", missing " + joinSIDs(e.Missing)
+	}
+	if len(e.Extra) > 0 {
+		s += ", extra " + joinSIDs(e.Extra)
+	}
+	return s
+}
+
+// VerifySIDs checks that the Section IDs declared separately from a GPP string, e.g. by the
+// OpenRTB gpp_sid field, are exactly the sections listed in the header of the GPP string, in
+// any order. An *ErrSIDMismatch listing the missing and extra Section IDs is returned when they
+// differ, which catches integrations that update one of them but not the other. Any error
+// reading the header is returned as is.
+func VerifySIDs(gpp string, declaredSIDs []int) error {
+	var s = CleanGppString(gpp)
+	if s == "" {
+		return ErrEmptyInput
+	}
+	var header, err = ParseGppHeader(strings.SplitN(s, "~", 2)[0])
+	if err != nil {
+		return errors.Wrap(err, "read gpp header")
+	}
+
+	var mismatch = &ErrSIDMismatch{}
+	for _, sid := range declaredSIDs {
+		if !containsSID(header.Sections, sid) && !containsSID(mismatch.Missing, sid) {
+			mismatch.Missing = append(mismatch.Missing, sid)
+		}
+	}
+	for _, sid := range header.Sections {
+		if !containsSID(declaredSIDs, sid) {
+			mismatch.Extra = append(mismatch.Extra, sid)
+		}
+	}
+	if len(mismatch.Missing) > 0 || len(mismatch.Extra) > 0 {
+		return mismatch
+	}
+	return nil
+}
+
+func joinSIDs(sids []int) string {
+	var s = make([]string, len(sids))
+	for i, sid := range sids {
+		s[i] = strconv.Itoa(sid)
+	}
+	return strings.Join(s, ",")
+}
+
+// hasUsGppSection returns true if any applicable GPP section covers the US.
+func (c *ConsolidatedConsent) hasUsGppSection() bool {
+	for sid := range c.GppSections {
+		if _, ok := mspaSectionLayouts[sid]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSID(sids []int, sid int) bool {
+	for _, s := range sids {
+		if s == sid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,392 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type OpenRTBSuite struct{}
+
+var _ = check.Suite(&OpenRTBSuite{})
+
+func (s *OpenRTBSuite) TestParseOpenRTBRegs(c *check.C) {
+	var ccpa = &iabconsent.CCPAConsent{
+		Version:                1,
+		ExplicitNotice:         iabconsent.MspaYes,
+		OptOutSale:             iabconsent.MspaYes,
+		LSPACoveredTransaction: iabconsent.MspaNo,
+	}
+	var usnat = gpcAbsent(mspaConsentFixtures[iabconsent.UsNationalSID]["BVVqAAEABCA.QA"])
+	var usva = mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"]
+
+	var tcs = []struct {
+		desc      string
+		gpp       string
+		gppSID    []int
+		usPrivacy string
+		expected  *iabconsent.ConsolidatedConsent
+	}{
+		{
+			desc:     "No signals.",
+			expected: &iabconsent.ConsolidatedConsent{GppSections: map[int]iabconsent.GppParsedConsent{}},
+		},
+		{
+			desc:      "Legacy US Privacy only.",
+			usPrivacy: "1YYN",
+			expected: &iabconsent.ConsolidatedConsent{
+				GppSections: map[int]iabconsent.GppParsedConsent{},
+				UsPrivacy:   "1YYN",
+				CCPA:        ccpa,
+			},
+		},
+		{
+			desc:   "GPP only, without Section IDs.",
+			gpp:    "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			gppSID: nil,
+			expected: &iabconsent.ConsolidatedConsent{
+				Gpp: "DBACLMA~BVVqAAEABCA~BVoYYYI",
+				GppSections: map[int]iabconsent.GppParsedConsent{
+					iabconsent.UsNationalSID: usnat,
+					iabconsent.UsVirginiaSID: usva,
+				},
+			},
+		},
+		{
+			desc:   "GPP only, with applicable Section IDs.",
+			gpp:    "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			gppSID: []int{iabconsent.UsVirginiaSID},
+			expected: &iabconsent.ConsolidatedConsent{
+				Gpp:    "DBACLMA~BVVqAAEABCA~BVoYYYI",
+				GppSID: []int{iabconsent.UsVirginiaSID},
+				GppSections: map[int]iabconsent.GppParsedConsent{
+					iabconsent.UsVirginiaSID: usva,
+				},
+			},
+		},
+		{
+			desc:      "GPP US section takes precedence over legacy US Privacy.",
+			gpp:       "DBABLA~BVVqAAEABCA",
+			gppSID:    []int{iabconsent.UsNationalSID},
+			usPrivacy: "1YYN",
+			expected: &iabconsent.ConsolidatedConsent{
+				Gpp:    "DBABLA~BVVqAAEABCA",
+				GppSID: []int{iabconsent.UsNationalSID},
+				GppSections: map[int]iabconsent.GppParsedConsent{
+					iabconsent.UsNationalSID: usnat,
+				},
+				UsPrivacy: "1YYN",
+			},
+		},
+		{
+			desc:      "Legacy US Privacy is used when no GPP US section is applicable.",
+			gpp:       "DBABLA~BVVqAAEABCA",
+			gppSID:    []int{2},
+			usPrivacy: "1YYN",
+			expected: &iabconsent.ConsolidatedConsent{
+				Gpp:         "DBABLA~BVVqAAEABCA",
+				GppSID:      []int{2},
+				GppSections: map[int]iabconsent.GppParsedConsent{},
+				UsPrivacy:   "1YYN",
+				CCPA:        ccpa,
+			},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.ParseOpenRTBRegs(tc.gpp, tc.gppSID, tc.usPrivacy)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.DeepEquals, tc.expected)
+	}
+}
+
+func (s *OpenRTBSuite) TestParseOpenRTBRegsError(c *check.C) {
+	var tcs = []struct {
+		desc      string
+		gpp       string
+		usPrivacy string
+		expected  string
+	}{
+		{
+			desc:     "Bad GPP header.",
+			gpp:      "badheader~BVVqAAEABCA",
+			expected: "parse gpp: read gpp header: wrong gpp header type 27",
+		},
+		{
+			desc:      "Bad US Privacy.",
+			usPrivacy: "1YN",
+			expected:  "parse us privacy: invalid us privacy string length 3",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.ParseOpenRTBRegs(tc.gpp, nil, tc.usPrivacy)
+		c.Check(p, check.IsNil)
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+}
+
+func (s *OpenRTBSuite) TestParseSignals(c *check.C) {
+	var tcf = "COvzTO5OvzTO5BRAAAENAPCoALIAADgAAAAAAewAwABAAlAB6ABBFAAA"
+	var tcfV2, err = iabconsent.ParseV2(tcf)
+	c.Assert(err, check.IsNil)
+	var usva = mspaConsentFixtures[iabconsent.UsVirginiaSID]["BVoYYYI"]
+
+	var tcs = []struct {
+		desc     string
+		signals  map[string]string
+		expected *iabconsent.ConsolidatedConsent
+	}{
+		{
+			desc:     "No signals.",
+			signals:  nil,
+			expected: &iabconsent.ConsolidatedConsent{GppSections: map[int]iabconsent.GppParsedConsent{}},
+		},
+		{
+			desc: "Every signal, with GPP taking precedence over US Privacy.",
+			signals: map[string]string{
+				"gpp":          "DBACLMA~BVVqAAEABCA~BVoYYYI",
+				"gpp_sid":      "9, 2",
+				"gdpr":         "1",
+				"gdpr_consent": tcf,
+				"us_privacy":   "1YYN",
+				"other":        "ignored",
+			},
+			expected: &iabconsent.ConsolidatedConsent{
+				Gpp:    "DBACLMA~BVVqAAEABCA~BVoYYYI",
+				GppSID: []int{iabconsent.UsVirginiaSID, iabconsent.TcfEuV2SID},
+				GppSections: map[int]iabconsent.GppParsedConsent{
+					iabconsent.UsVirginiaSID: usva,
+				},
+				UsPrivacy:  "1YYN",
+				Gdpr:       "1",
+				TcfConsent: tcf,
+				TcfV2:      tcfV2,
+			},
+		},
+		{
+			desc: "GDPR does not apply, so the TC string is not parsed, even if it is invalid.",
+			signals: map[string]string{
+				"gdpr":         "0",
+				"gdpr_consent": "invalid",
+			},
+			expected: &iabconsent.ConsolidatedConsent{
+				GppSections: map[int]iabconsent.GppParsedConsent{},
+				Gdpr:        "0",
+				TcfConsent:  "invalid",
+			},
+		},
+		{
+			desc: "Empty signals.",
+			signals: map[string]string{
+				"gpp":          "",
+				"gpp_sid":      "",
+				"gdpr_consent": " ",
+				"us_privacy":   "",
+			},
+			expected: &iabconsent.ConsolidatedConsent{
+				GppSections: map[int]iabconsent.GppParsedConsent{},
+				TcfConsent:  " ",
+			},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.ParseSignals(tc.signals)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.DeepEquals, tc.expected)
+	}
+
+	var errs = []struct {
+		desc     string
+		signals  map[string]string
+		expected string
+	}{
+		{
+			desc:     "Bad gpp_sid.",
+			signals:  map[string]string{"gpp_sid": "7,x"},
+			expected: "parse gpp_sid: invalid section id x",
+		},
+		{
+			desc:     "Bad GPP.",
+			signals:  map[string]string{"gpp": "badheader~BVVqAAEABCA"},
+			expected: "parse gpp: read gpp header: wrong gpp header type 27",
+		},
+		{
+			desc:     "Bad gdpr.",
+			signals:  map[string]string{"gdpr": "yes"},
+			expected: "parse gdpr: invalid value yes",
+		},
+		{
+			desc:     "Bad gdpr_consent.",
+			signals:  map[string]string{"gdpr_consent": "BONJ5bvONJ5bvAMAPyFRAL7AAAAMhuqKklS-gAAAAAAAAAAAAAAAAAAAAAAAAAA"},
+			expected: "parse gdpr_consent: non-v2 string passed to v2 parse method",
+		},
+	}
+	for _, tc := range errs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.ParseSignals(tc.signals)
+		c.Check(p, check.IsNil)
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+}
+
+func (s *OpenRTBSuite) TestParseSignalsGdpr(c *check.C) {
+	// The TC string has no consent for Purpose 4, so it does not allow personalized ads.
+	var signals = map[string]string{
+		"gdpr":         "1",
+		"gdpr_consent": "COvzTO5OvzTO5B7ABCENAPCYAKdAADkAAIqIFhwBAAGAAXAFGAsMAhYAgAMAAegBYAEKAAA",
+	}
+	var p, err = iabconsent.ParseSignals(signals)
+	c.Assert(err, check.IsNil)
+	c.Check(p.TcfV2, check.NotNil)
+	c.Check(iabconsent.CanServePersonalizedAds(p), check.Equals, false)
+
+	// The TC string is ignored when GDPR does not apply.
+	signals["gdpr"] = "0"
+	p, err = iabconsent.ParseSignals(signals)
+	c.Assert(err, check.IsNil)
+	c.Check(p.TcfV2, check.IsNil)
+	c.Check(iabconsent.CanServePersonalizedAds(p), check.Equals, true)
+
+	// A TC string set directly is also ignored.
+	var tcf = &iabconsent.V2ParsedConsent{}
+	c.Check(iabconsent.CanServePersonalizedAds(&iabconsent.ConsolidatedConsent{Gdpr: "0", TcfV2: tcf}), check.Equals, true)
+	c.Check(iabconsent.CanServePersonalizedAds(&iabconsent.ConsolidatedConsent{Gdpr: "1", TcfV2: tcf}), check.Equals, false)
+}
+
+func (s *OpenRTBSuite) TestVerifySIDs(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		gpp      string
+		declared []int
+		expected error
+	}{
+		{
+			desc:     "Matching, in any order.",
+			gpp:      "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			declared: []int{iabconsent.UsVirginiaSID, iabconsent.UsNationalSID},
+		},
+		{
+			desc:     "Missing.",
+			gpp:      "DBABLA~BVVqAAEABCA",
+			declared: []int{iabconsent.UsNationalSID, iabconsent.UsVirginiaSID, iabconsent.TcfEuV2SID, iabconsent.TcfEuV2SID},
+			expected: &iabconsent.ErrSIDMismatch{Missing: []int{iabconsent.UsVirginiaSID, iabconsent.TcfEuV2SID}},
+		},
+		{
+			desc:     "Extra.",
+			gpp:      "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			declared: []int{iabconsent.UsVirginiaSID},
+			expected: &iabconsent.ErrSIDMismatch{Extra: []int{iabconsent.UsNationalSID}},
+		},
+		{
+			desc:     "Missing and extra.",
+			gpp:      "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			declared: []int{iabconsent.UsVirginiaSID, iabconsent.UsCaliforniaSID},
+			expected: &iabconsent.ErrSIDMismatch{
+				Missing: []int{iabconsent.UsCaliforniaSID},
+				Extra:   []int{iabconsent.UsNationalSID},
+			},
+		},
+		{
+			desc:     "Nothing declared.",
+			gpp:      "DBABLA~BVVqAAEABCA",
+			expected: &iabconsent.ErrSIDMismatch{Extra: []int{iabconsent.UsNationalSID}},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		c.Check(iabconsent.VerifySIDs(tc.gpp, tc.declared), check.DeepEquals, tc.expected)
+	}
+
+	c.Check(iabconsent.VerifySIDs("DBACLMA~BVVqAAEABCA~BVoYYYI", []int{8, 2}), check.ErrorMatches,
+		"gpp_sid mismatch, missing 8,2, extra 7,9")
+	c.Check(iabconsent.VerifySIDs(" ", nil), check.Equals, iabconsent.ErrEmptyInput)
+	c.Check(iabconsent.VerifySIDs("badheader~BVVqAAEABCA", nil), check.ErrorMatches,
+		"read gpp header: wrong gpp header type 27")
+}
+
+func (s *OpenRTBSuite) TestToOpenRTBRegs(c *check.C) {
+	var tcs = []struct {
+		desc              string
+		gpp               string
+		gppSID            []int
+		usPrivacy         string
+		expectedGpp       string
+		expectedGppSID    []int
+		expectedUsPrivacy string
+	}{
+		{
+			desc: "No signals.",
+		},
+		{
+			desc:           "GPP only.",
+			gpp:            "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			expectedGpp:    "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			expectedGppSID: []int{iabconsent.UsNationalSID, iabconsent.UsVirginiaSID},
+		},
+		{
+			desc:           "Only applicable sections are kept.",
+			gpp:            "DBACLMA~BVVqAAEABCA~BVoYYYI",
+			gppSID:         []int{iabconsent.UsVirginiaSID},
+			expectedGpp:    "DBABRg~BVoYYYI",
+			expectedGppSID: []int{iabconsent.UsVirginiaSID},
+		},
+		{
+			desc:           "Sections are re-encoded canonically.",
+			gpp:            "DBABLA~CVVVVVVVVVVW.YA",
+			expectedGpp:    "DBABLA~CVVVVVVVVVVU.YA",
+			expectedGppSID: []int{iabconsent.UsNationalSID},
+		},
+		{
+			desc:           "Sections that are not MSPA sections are copied.",
+			gpp:            "DBABjw~BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA~1YNN",
+			expectedGpp:    "DBABDA~BPrZN2wPrZN2wAfACBFRCyCQAcAAAISAAAAQRQAogBazUYA",
+			expectedGppSID: []int{iabconsent.CaTcfSID},
+		},
+		{
+			desc:              "Legacy US Privacy is translated to usnat.",
+			usPrivacy:         " 1YYN ",
+			expectedGpp:       "DBABLA~BEAQAAAAAgA",
+			expectedGppSID:    []int{iabconsent.UsNationalSID},
+			expectedUsPrivacy: "1YYN",
+		},
+		{
+			desc:              "Legacy US Privacy is not translated with a US GPP section.",
+			gpp:               "DBABRg~BVoYYYI",
+			usPrivacy:         "1YYN",
+			expectedGpp:       "DBABRg~BVoYYYI",
+			expectedGppSID:    []int{iabconsent.UsVirginiaSID},
+			expectedUsPrivacy: "",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var consent, err = iabconsent.ParseOpenRTBRegs(tc.gpp, tc.gppSID, tc.usPrivacy)
+		c.Assert(err, check.IsNil)
+		var gpp, gppSID, usPrivacy = consent.ToOpenRTBRegs()
+		c.Check(gpp, check.Equals, tc.expectedGpp)
+		c.Check(gppSID, check.DeepEquals, tc.expectedGppSID)
+		c.Check(usPrivacy, check.Equals, tc.expectedUsPrivacy)
+
+		// The serialized signals parse to the same sections.
+		var reparsed *iabconsent.ConsolidatedConsent
+		reparsed, err = iabconsent.ParseOpenRTBRegs(gpp, gppSID, usPrivacy)
+		c.Assert(err, check.IsNil)
+		if consent.CCPA == nil {
+			c.Check(reparsed.GppSections, check.DeepEquals, consent.GppSections)
+			continue
+		}
+		// The translated usnat section takes precedence over the legacy string, and makes the
+		// same decision.
+		c.Check(reparsed.CCPA, check.IsNil)
+		var usnat = reparsed.GppSections[iabconsent.UsNationalSID].(*iabconsent.MspaParsedConsent)
+		c.Check(usnat.UsPrivacyDecision(), check.Equals, iabconsent.CCPAToUsnatDecision(*consent.CCPA))
+	}
+}
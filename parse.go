@@ -39,6 +39,22 @@ func (r *ConsentReader) ReadInt(n uint) (int, error) {
 	}
 }
 
+// SkipBits skips the next n bits. Unlike ReadBits, which reads at most 64 bits, n may be any
+// number of bits, e.g. the 70 bits of the fields of a US National v2 core segment.
+func (r *ConsentReader) SkipBits(n uint) error {
+	for n > 0 {
+		var l = n
+		if l > 64 {
+			l = 64
+		}
+		if _, err := r.ReadBits(l); err != nil {
+			return errors.WithMessage(err, "skip bits")
+		}
+		n -= l
+	}
+	return nil
+}
+
 // FibonacciIndexValue is a helper function to get the Fibonacci value of an index for Fibonacci encoding.
 // These are currently only used for the various consent types, which there are not many, so
 // we should only expect to use the smaller indexes. Therefore, create a map, but still allow for
@@ -182,6 +198,86 @@ func (r *ConsentReader) ReadRangeEntries(n uint) ([]*RangeEntry, error) {
 	return ret, nil
 }
 
+// readVendorBitField reads a vendor bit field of maxVendorID bits. If assumedMaxVendorID is not
+// 0, a bit field that is cut short by the end of r is read up to the end, and vendors past
+// assumedMaxVendorID are dropped.
+func readVendorBitField(r *ConsentReader, maxVendorID, assumedMaxVendorID int) (map[int]bool, error) {
+	if assumedMaxVendorID == 0 {
+		return r.ReadBitField(uint(maxVendorID))
+	}
+	var n = maxVendorID
+	if r.Err == nil && r.NumUnread() < n {
+		n = r.NumUnread()
+	}
+	var m, err = r.ReadBitField(uint(n))
+	for v := range m {
+		if v > assumedMaxVendorID {
+			delete(m, v)
+		}
+	}
+	return m, err
+}
+
+// readRangeEntries reads n range entries. If assumedMaxVendorID is not 0, the entries that
+// were read before reaching the end of r are returned, instead of none.
+func readRangeEntries(r *ConsentReader, n, assumedMaxVendorID int) ([]*RangeEntry, error) {
+	if assumedMaxVendorID == 0 {
+		return r.ReadRangeEntries(uint(n))
+	}
+	var entries = make([]*RangeEntry, 0, n)
+	for i := 0; i < n; i++ {
+		var entry, err = r.ReadRangeEntries(1)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry...)
+	}
+	return entries, nil
+}
+
+// boundVendors caps maxVendorID, and the range entries of a vendor section, at
+// assumedMaxVendorID.
+func boundVendors(maxVendorID int, entries []*RangeEntry, assumedMaxVendorID int) (int, []*RangeEntry) {
+	if maxVendorID > assumedMaxVendorID {
+		maxVendorID = assumedMaxVendorID
+	}
+	var bounded = entries[:0]
+	for _, re := range entries {
+		if re.StartVendorID > assumedMaxVendorID {
+			continue
+		}
+		if re.EndVendorID > assumedMaxVendorID {
+			re = &RangeEntry{StartVendorID: re.StartVendorID, EndVendorID: assumedMaxVendorID}
+		}
+		bounded = append(bounded, re)
+	}
+	return maxVendorID, bounded
+}
+
+// maxFibonacciRangeID is the largest ID that can be read from a Fibonacci range. IDs in GPP
+// ranges are Section IDs or Vendor IDs, and Vendor IDs are encoded elsewhere as int(16).
+const maxFibonacciRangeID = 1<<16 - 1
+
+// ErrVendorRangeOutOfBounds is returned when a vendor range entry ends after the declared
+// maximum Vendor ID, or ends before it starts. Rather than being clamped, such strings are
+// rejected, as the intended vendors can not be known.
+var ErrVendorRangeOutOfBounds = errors.New("vendor range out of bounds")
+
+// maxEncodedVendorID is the largest Vendor ID that can be encoded as int(16), which bounds the
+// range entries of publisher restrictions, as they do not declare a maximum Vendor ID.
+const maxEncodedVendorID = 1<<16 - 1
+
+// checkRangeEntries returns ErrVendorRangeOutOfBounds if any of the entries are not within
+// maxVendorID.
+func checkRangeEntries(maxVendorID int, entries []*RangeEntry) error {
+	for _, re := range entries {
+		if re.EndVendorID > maxVendorID || re.StartVendorID > re.EndVendorID {
+			return ErrVendorRangeOutOfBounds
+		}
+	}
+	return nil
+}
+
 // ReadFibonacciRange reads a range entries of Fibonacci encoded integers.
 // Returns an array of numbers. The format of the range field always consists of:
 // - int(12) - representing the amount of items to follow
@@ -218,6 +314,12 @@ func (r *ConsentReader) ReadFibonacciRange() ([]int, error) {
 			if groupLength, err = r.ReadFibonacciInt(); err != nil {
 				return nil, errors.WithMessage(err, "fibonacci range length")
 			}
+			// Bound the range, so that a malformed (or overflowed) length cannot expand into an
+			// unbounded number of IDs.
+			if offset < 0 || groupLength < 0 || offset > maxFibonacciRangeID-lastSeen ||
+				groupLength > maxFibonacciRangeID-lastSeen-offset {
+				return nil, errors.New("fibonacci range exceeds max id of " + strconv.Itoa(maxFibonacciRangeID))
+			}
 			// Add offset to last seen value as starting point of range.
 			lastSeen += offset
 			// Keep appending integers until we reach the group length.
@@ -227,6 +329,9 @@ func (r *ConsentReader) ReadFibonacciRange() ([]int, error) {
 			}
 		} else {
 			// If a single ID, add value to last seen value.
+			if offset < 0 || offset > maxFibonacciRangeID-lastSeen {
+				return nil, errors.New("fibonacci range exceeds max id of " + strconv.Itoa(maxFibonacciRangeID))
+			}
 			ret = append(ret, lastSeen+offset)
 		}
 	}
@@ -299,6 +404,9 @@ func (r *ConsentReader) ReadVendors(t SegmentType) (*OOBVendorList, error) {
 		if v.VendorEntries, err = r.ReadRangeEntries(uint(v.NumEntries)); err != nil {
 			return nil, errors.WithMessage(err, "reading vendor range entries")
 		}
+		if err = checkRangeEntries(v.MaxVendorID, v.VendorEntries); err != nil {
+			return nil, err
+		}
 	} else {
 		if v.Vendors, err = r.ReadBitField(uint(v.MaxVendorID)); err != nil {
 			return nil, errors.WithMessage(err, "reading vendor bit field")
@@ -397,6 +505,22 @@ func ParseV1(s string) (*ParsedConsent, error) {
 //
 //   var pc, err = iabconsent.ParseV2("COvzTO5OvzTO5BRAAAENAPCoALIAADgAAAAAAewAwABAAlAB6ABBFAAA")
 func ParseV2(s string) (*V2ParsedConsent, error) {
+	return parseV2(s, 0)
+}
+
+// ParseV2WithOptions is ParseV2 with Options. The only Options that apply to TC strings are
+// the ones returned by WithAssumedMaxVendorID, which tolerate strings with a truncated vendor
+// section.
+//
+// Example Usage:
+//
+//   var pc, err = iabconsent.ParseV2WithOptions(s, iabconsent.WithAssumedMaxVendorID(1000))
+func ParseV2WithOptions(s string, options ...*Options) (*V2ParsedConsent, error) {
+	return parseV2(s, optionsOrDefault(options).AssumedMaxVendorID)
+}
+
+// parseV2 implements ParseV2, reading no vendors past assumedMaxVendorID if it is not 0.
+func parseV2(s string, assumedMaxVendorID int) (*V2ParsedConsent, error) {
 	var segments = strings.Split(s, ".")
 
 	var b, err = base64.RawURLEncoding.DecodeString(segments[0])
@@ -441,27 +565,54 @@ func ParseV2(s string) (*V2ParsedConsent, error) {
 	}
 	p.PurposeOneTreatment, _ = r.ReadBool()
 	p.PublisherCC, _ = r.ReadString(2)
+	// Only truncated vendor sections are tolerated.
+	if assumedMaxVendorID > 0 && r.Err != nil {
+		return p, r.Err
+	}
 
 	p.MaxConsentVendorID, _ = r.ReadInt(16)
 	p.IsConsentRangeEncoding, _ = r.ReadBool()
 	if p.IsConsentRangeEncoding {
 		p.NumConsentEntries, _ = r.ReadInt(12)
-		p.ConsentedVendorsRange, _ = r.ReadRangeEntries(uint(p.NumConsentEntries))
+		p.ConsentedVendorsRange, _ = readRangeEntries(r, p.NumConsentEntries, assumedMaxVendorID)
+		if err = checkRangeEntries(p.MaxConsentVendorID, p.ConsentedVendorsRange); err != nil {
+			return nil, err
+		}
 	} else {
-		p.ConsentedVendors, _ = r.ReadBitField(uint(p.MaxConsentVendorID))
+		p.ConsentedVendors, _ = readVendorBitField(r, p.MaxConsentVendorID, assumedMaxVendorID)
+	}
+	if assumedMaxVendorID > 0 {
+		p.MaxConsentVendorID, p.ConsentedVendorsRange = boundVendors(p.MaxConsentVendorID, p.ConsentedVendorsRange, assumedMaxVendorID)
+		if r.Err != nil {
+			return p, nil
+		}
 	}
 
 	p.MaxInterestsVendorID, _ = r.ReadInt(16)
 	p.IsInterestsRangeEncoding, _ = r.ReadBool()
 	if p.IsInterestsRangeEncoding {
 		p.NumInterestsEntries, _ = r.ReadInt(12)
-		p.InterestsVendorsRange, _ = r.ReadRangeEntries(uint(p.NumInterestsEntries))
+		p.InterestsVendorsRange, _ = readRangeEntries(r, p.NumInterestsEntries, assumedMaxVendorID)
+		if err = checkRangeEntries(p.MaxInterestsVendorID, p.InterestsVendorsRange); err != nil {
+			return nil, err
+		}
 	} else {
-		p.InterestsVendors, _ = r.ReadBitField(uint(p.MaxInterestsVendorID))
+		p.InterestsVendors, _ = readVendorBitField(r, p.MaxInterestsVendorID, assumedMaxVendorID)
+	}
+	if assumedMaxVendorID > 0 {
+		p.MaxInterestsVendorID, p.InterestsVendorsRange = boundVendors(p.MaxInterestsVendorID, p.InterestsVendorsRange, assumedMaxVendorID)
+		if r.Err != nil {
+			return p, nil
+		}
 	}
 
 	p.NumPubRestrictions, _ = r.ReadInt(12)
 	p.PubRestrictionEntries, _ = r.ReadPubRestrictionEntries(uint(p.NumPubRestrictions))
+	for _, pr := range p.PubRestrictionEntries {
+		if err = checkRangeEntries(maxEncodedVendorID, pr.RestrictionsRange); err != nil {
+			return nil, err
+		}
+	}
 
 	// Parse remaining non-core string segments if they exist.
 	for i, segment := range segments[1:] {
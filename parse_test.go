@@ -33,6 +33,16 @@ func (s *ParseSuite) TestConsentReader_ReadInt(c *check.C) {
 	c.Check(r.HasUnread(), check.Equals, false)
 }
 
+func (s *ParseSuite) TestConsentReader_SkipBits(c *check.C) {
+	var r = iabconsent.NewConsentReader([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0x02})
+	c.Check(r.SkipBits(70), check.IsNil)
+	var v, err = r.ReadInt(2)
+	c.Check(err, check.IsNil)
+	c.Check(v, check.Equals, 2)
+
+	c.Check(r.SkipBits(8), check.NotNil)
+}
+
 func (s *ParseSuite) TestConsentReader_ReadFibonacciInt(c *check.C) {
 	var tests = []struct {
 		testBytes []byte
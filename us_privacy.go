@@ -0,0 +1,126 @@
+package iabconsent
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// UsPrivacySID is the GPP Section ID of the legacy US Privacy (CCPA) section.
+const UsPrivacySID = 6
+
+// CCPAConsent represents data extracted from a legacy IAB US Privacy String, e.g. "1YNN".
+// Each field is Not Applicable (`-`), Yes (`Y`), or No (`N`).
+type CCPAConsent struct {
+	// The version of the US Privacy String specification used to encode the string.
+	Version int
+	// Explicit Notice/Opportunity to Opt Out of the sale of personal data was provided.
+	ExplicitNotice MspaNaYesNo
+	// The user has opted out of the sale of personal data.
+	OptOutSale MspaNaYesNo
+	// The publisher is a signatory to the IAB Limited Service Provider Agreement (LSPA), and
+	// the transaction is a Covered Transaction.
+	LSPACoveredTransaction MspaNaYesNo
+}
+
+// ParseCCPA takes a US Privacy String, and returns a CCPAConsent with its fields populated
+// with the values stored in the string. Only version 1 is supported.
+//
+// Example Usage:
+//
+//	var c, err = iabconsent.ParseCCPA("1YNN")
+func ParseCCPA(s string) (*CCPAConsent, error) {
+	if len(s) != 4 {
+		return nil, errors.New("invalid us privacy string length " + strconv.Itoa(len(s)))
+	}
+	if s[0] != '1' {
+		return nil, errors.New("unsupported us privacy version " + string(s[0]))
+	}
+	var c = &CCPAConsent{Version: 1}
+	for i, f := range []*MspaNaYesNo{&c.ExplicitNotice, &c.OptOutSale, &c.LSPACoveredTransaction} {
+		switch s[i+1] {
+		case '-':
+			*f = MspaNotApplicable
+		case 'Y', 'y':
+			*f = MspaYes
+		case 'N', 'n':
+			*f = MspaNo
+		default:
+			return nil, errors.New("invalid us privacy value " + string(s[i+1]))
+		}
+	}
+	return c, nil
+}
+
+// UsPrivacyDecision is the subset of a US privacy signal that both a legacy US Privacy String
+// and a usnat section can express, so that decisions made from either can be compared.
+type UsPrivacyDecision struct {
+	// Notice of the opportunity to opt out of the sale of personal data.
+	SaleOptOutNotice MspaNotice
+	// The user's opt-out of the sale of personal data.
+	SaleOptOut MspaOptout
+	// The transaction is a Covered Transaction under the applicable service provider agreement.
+	CoveredTransaction MspaNaYesNo
+}
+
+// SaleAllowed returns true if the decision allows the sale of personal data, i.e. the user has
+// not opted out of sale.
+func (d UsPrivacyDecision) SaleAllowed() bool {
+	return d.SaleOptOut != OptedOut
+}
+
+// CCPAToUsnatDecision converts a legacy US Privacy decision into the equivalent usnat decision.
+// The mapping assumes that:
+//   - ExplicitNotice maps to SaleOptOutNotice, as the notice in the US Privacy String is of
+//     the opportunity to opt out of sale. Yes is NoticeProvided, and No is NoticeNotProvided.
+//   - OptOutSale maps to SaleOptOut. Yes is OptedOut, and No is NotOptedOut.
+//   - LSPACoveredTransaction maps to CoveredTransaction, treating the LSPA as the predecessor
+//     of the MSPA, and keeping the Yes or No value.
+//
+// Not Applicable (`-`) always maps to the usnat Not Applicable value.
+func CCPAToUsnatDecision(c CCPAConsent) UsPrivacyDecision {
+	var d = UsPrivacyDecision{CoveredTransaction: c.LSPACoveredTransaction}
+	switch c.ExplicitNotice {
+	case MspaYes:
+		d.SaleOptOutNotice = NoticeProvided
+	case MspaNo:
+		d.SaleOptOutNotice = NoticeNotProvided
+	}
+	switch c.OptOutSale {
+	case MspaYes:
+		d.SaleOptOut = OptedOut
+	case MspaNo:
+		d.SaleOptOut = NotOptedOut
+	}
+	return d
+}
+
+// MinimalGppForDecision returns the shortest GPP string with a single MSPA section of the
+// given Section ID that expresses decision d, e.g. to generate test vectors. Only the fields of
+// d are set, every other field is Not Applicable, the first version of the section is used,
+// and no GPC subsection is encoded. An error is returned if the section is not supported, or if
+// d is not valid, see MspaParsedConsent.Validate.
+func MinimalGppForDecision(sid int, d UsPrivacyDecision) (string, error) {
+	var b = NewMspaConsentBuilder().
+		SetSaleOptOutNotice(d.SaleOptOutNotice).
+		SetSaleOptOut(d.SaleOptOut).
+		SetMspaCoveredTransaction(d.CoveredTransaction)
+	if err := b.consent.Validate(); err != nil {
+		return "", errors.Wrap(err, "minimal gpp for decision")
+	}
+	var section, err = b.Build(sid)
+	if err != nil {
+		return "", errors.Wrap(err, "minimal gpp for decision")
+	}
+	return BuildGppString(nil, map[int]string{sid: section})
+}
+
+// UsPrivacyDecision returns the usnat fields of the parsed consent that are comparable with a
+// legacy US Privacy String, see CCPAToUsnatDecision.
+func (m *MspaParsedConsent) UsPrivacyDecision() UsPrivacyDecision {
+	return UsPrivacyDecision{
+		SaleOptOutNotice:   m.SaleOptOutNotice,
+		SaleOptOut:         m.SaleOptOut,
+		CoveredTransaction: m.MspaCoveredTransaction,
+	}
+}
@@ -0,0 +1,216 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type UsPrivacySuite struct{}
+
+var _ = check.Suite(&UsPrivacySuite{})
+
+func (s *UsPrivacySuite) TestParseCCPA(c *check.C) {
+	var tcs = []struct {
+		usPrivacy string
+		expected  *iabconsent.CCPAConsent
+	}{
+		{
+			usPrivacy: "1YNN",
+			expected: &iabconsent.CCPAConsent{
+				Version:                1,
+				ExplicitNotice:         iabconsent.MspaYes,
+				OptOutSale:             iabconsent.MspaNo,
+				LSPACoveredTransaction: iabconsent.MspaNo,
+			},
+		},
+		{
+			usPrivacy: "1YYY",
+			expected: &iabconsent.CCPAConsent{
+				Version:                1,
+				ExplicitNotice:         iabconsent.MspaYes,
+				OptOutSale:             iabconsent.MspaYes,
+				LSPACoveredTransaction: iabconsent.MspaYes,
+			},
+		},
+		{
+			usPrivacy: "1---",
+			expected: &iabconsent.CCPAConsent{
+				Version:                1,
+				ExplicitNotice:         iabconsent.MspaNotApplicable,
+				OptOutSale:             iabconsent.MspaNotApplicable,
+				LSPACoveredTransaction: iabconsent.MspaNotApplicable,
+			},
+		},
+		{
+			usPrivacy: "1nY-",
+			expected: &iabconsent.CCPAConsent{
+				Version:                1,
+				ExplicitNotice:         iabconsent.MspaNo,
+				OptOutSale:             iabconsent.MspaYes,
+				LSPACoveredTransaction: iabconsent.MspaNotApplicable,
+			},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.usPrivacy)
+
+		var p, err = iabconsent.ParseCCPA(tc.usPrivacy)
+		c.Check(err, check.IsNil)
+		c.Check(p, check.DeepEquals, tc.expected)
+	}
+}
+
+func (s *UsPrivacySuite) TestParseCCPAError(c *check.C) {
+	var tcs = []struct {
+		usPrivacy string
+		expected  string
+	}{
+		{usPrivacy: "", expected: "invalid us privacy string length 0"},
+		{usPrivacy: "1YNNN", expected: "invalid us privacy string length 5"},
+		{usPrivacy: "2YNN", expected: "unsupported us privacy version 2"},
+		{usPrivacy: "1YXN", expected: "invalid us privacy value X"},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.usPrivacy)
+
+		var p, err = iabconsent.ParseCCPA(tc.usPrivacy)
+		c.Check(p, check.IsNil)
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+}
+
+func (s *UsPrivacySuite) TestCCPAToUsnatDecision(c *check.C) {
+	var notices = map[byte]iabconsent.MspaNotice{
+		'-': iabconsent.NoticeNotApplicable,
+		'Y': iabconsent.NoticeProvided,
+		'N': iabconsent.NoticeNotProvided,
+	}
+	var optOuts = map[byte]iabconsent.MspaOptout{
+		'-': iabconsent.OptOutNotApplicable,
+		'Y': iabconsent.OptedOut,
+		'N': iabconsent.NotOptedOut,
+	}
+	var covered = map[byte]iabconsent.MspaNaYesNo{
+		'-': iabconsent.MspaNotApplicable,
+		'Y': iabconsent.MspaYes,
+		'N': iabconsent.MspaNo,
+	}
+	// Every combination of the US Privacy String fields.
+	for _, n := range "-YN" {
+		for _, o := range "-YN" {
+			for _, l := range "-YN" {
+				var usPrivacy = "1" + string(n) + string(o) + string(l)
+				c.Log(usPrivacy)
+
+				var p, err = iabconsent.ParseCCPA(usPrivacy)
+				c.Assert(err, check.IsNil)
+				var d = iabconsent.CCPAToUsnatDecision(*p)
+				c.Check(d, check.Equals, iabconsent.UsPrivacyDecision{
+					SaleOptOutNotice:   notices[byte(n)],
+					SaleOptOut:         optOuts[byte(o)],
+					CoveredTransaction: covered[byte(l)],
+				})
+				c.Check(d.SaleAllowed(), check.Equals, o != 'Y')
+			}
+		}
+	}
+}
+
+func (s *UsPrivacySuite) TestUsPrivacyDecisionMatchesUsnat(c *check.C) {
+	// A usnat section which signals the same sale decision as "1YYY".
+	var usnat, err = iabconsent.NewMspaConsentBuilder().
+		SetSaleOptOutNotice(iabconsent.NoticeProvided).
+		SetSaleOptOut(iabconsent.OptedOut).
+		SetMspaCoveredTransaction(iabconsent.MspaYes).
+		Build(iabconsent.UsNationalSID)
+	c.Assert(err, check.IsNil)
+	var m iabconsent.GppParsedConsent
+	m, err = iabconsent.NewMspa(iabconsent.UsNationalSID, usnat).ParseConsent()
+	c.Assert(err, check.IsNil)
+
+	var p *iabconsent.CCPAConsent
+	p, err = iabconsent.ParseCCPA("1YYY")
+	c.Assert(err, check.IsNil)
+	c.Check(iabconsent.CCPAToUsnatDecision(*p), check.Equals, m.(*iabconsent.MspaParsedConsent).UsPrivacyDecision())
+}
+
+func (s *UsPrivacySuite) TestMinimalGppForDecision(c *check.C) {
+	var tcs = []struct {
+		desc     string
+		sid      int
+		decision iabconsent.UsPrivacyDecision
+		expected string
+	}{
+		{
+			desc:     "Sale opted out in California.",
+			sid:      iabconsent.UsCaliforniaSID,
+			decision: iabconsent.UsPrivacyDecision{SaleOptOutNotice: iabconsent.NoticeProvided, SaleOptOut: iabconsent.OptedOut},
+			expected: "DBABBg~BQQAAAAA",
+		},
+		{
+			desc: "Sale not opted out in a covered transaction, in US National.",
+			sid:  iabconsent.UsNationalSID,
+			decision: iabconsent.UsPrivacyDecision{SaleOptOutNotice: iabconsent.NoticeProvided, SaleOptOut: iabconsent.NotOptedOut,
+				CoveredTransaction: iabconsent.MspaYes},
+			expected: "DBABLA~BEAgAAAAAQA",
+		},
+		{
+			desc:     "Not Applicable in Virginia.",
+			sid:      iabconsent.UsVirginiaSID,
+			expected: "DBABRg~BAAAAAA",
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var gpp, err = iabconsent.MinimalGppForDecision(tc.sid, tc.decision)
+		c.Assert(err, check.IsNil)
+		c.Check(gpp, check.Equals, tc.expected)
+
+		// The string parses to the decision, with every other field Not Applicable.
+		var r *iabconsent.GppResult
+		r, err = iabconsent.ParseGpp(gpp)
+		c.Assert(err, check.IsNil)
+		c.Assert(r.Sections, check.HasLen, 1)
+		var m = r.Sections[tc.sid].(*iabconsent.MspaParsedConsent)
+		c.Check(m.UsPrivacyDecision(), check.Equals, tc.decision)
+		var fields = m.NonDefaultFields()
+		delete(fields, "Version")
+		delete(fields, "SaleOptOutNotice")
+		delete(fields, "SaleOptOut")
+		delete(fields, "MspaCoveredTransaction")
+		c.Check(fields, check.HasLen, 0)
+	}
+
+	var errs = []struct {
+		desc     string
+		sid      int
+		decision iabconsent.UsPrivacyDecision
+		expected string
+	}{
+		{
+			desc:     "Unsupported section.",
+			sid:      2,
+			expected: "minimal gpp for decision: build mspa consent string: unsupported section id: 2",
+		},
+		{
+			desc:     "Opted out without notice.",
+			sid:      iabconsent.UsCaliforniaSID,
+			decision: iabconsent.UsPrivacyDecision{SaleOptOutNotice: iabconsent.NoticeNotProvided, SaleOptOut: iabconsent.OptedOut},
+			expected: "minimal gpp for decision: sale opt-out must be not applicable when its notice was not provided, got 1",
+		},
+		{
+			desc:     "Invalid value.",
+			sid:      iabconsent.UsCaliforniaSID,
+			decision: iabconsent.UsPrivacyDecision{SaleOptOut: iabconsent.InvalidOptOutValue},
+			expected: "minimal gpp for decision: build mspa consent string: invalid opt-out value: 3",
+		},
+	}
+	for _, tc := range errs {
+		c.Log(tc.desc)
+
+		var _, err = iabconsent.MinimalGppForDecision(tc.sid, tc.decision)
+		c.Check(err, check.ErrorMatches, tc.expected)
+	}
+}
@@ -1,11 +1,15 @@
 package iabconsent
 
 import (
+	"sort"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+// TcfEuV2SID is the GPP Section ID of the EU TCF v2 (tcfeuv2) section.
+const TcfEuV2SID = 2
+
 // V2ParsedConsent represents data extracted from an v2 TCF Consent String.
 type V2ParsedConsent struct {
 	// Version number of the encoding format.
@@ -28,19 +32,24 @@ type V2ParsedConsent struct {
 	// specific. The number is used for identifying on which screen a user gave consent
 	// as a record.
 	ConsentScreen int
-	// Two-letter ISO 639-1 language code in which the CMP UI was presented.
+	// Two-letter ISO 639-1 language code in which the CMP UI was presented. Each letter is
+	// decoded from 6 bits, where 0 is 'A', so the code is upper case, e.g. "EN".
 	ConsentLanguage string
 	// Number corresponds to the Global Vendor List (GVL) vendorListVersion.
 	VendorListVersion int
-	// Version of policy used within GVL.
+	// Version of policy used within GVL. Use MinorVersion to determine which minor version of
+	// TCF v2 (e.g. v2.0 or v2.2) the policy version indicates.
 	TCFPolicyVersion int
 	// Whether the signals encoded in this TC String were from service-specific storage
-	// (true) versus ‘global’ consensu.org shared storage (false).
+	// (true) versus ‘global’ consensu.org shared storage (false). Publisher restrictions
+	// are set by the publisher of the service, so they only apply to that service when
+	// true, and global TC Strings are deprecated as of TCF v2.2.
 	IsServiceSpecific bool
 	// Setting this to 1 means that a publisher-run CMP – that is still IAB Europe
 	// registered – is using customized Stack descriptions and not the standard stack
 	// descriptions defined in the Policies. A CMP that services multiple publishers sets
-	// this value to 0.
+	// this value to 0. TCF v2.2 renamed this field to UseNonStandardTexts, see
+	// the UseNonStandardTexts method.
 	UseNonStandardStacks bool
 	// The TCF Policies designates certain Features as “special” which means a CMP must
 	// afford the user a means to opt in to their use. These “Special Features” are
@@ -64,9 +73,14 @@ type V2ParsedConsent struct {
 	// globally-scoped TC string, this field must always have a value of 0. When a CMP
 	// encounters a globally-scoped TC String with PurposeOneTreatment=1 then it is considered
 	// invalid and the CMP must discard it and re-establish transparency and consent.
+	// 1 Purpose 1 was not disclosed at all, e.g. because the publisher's jurisdiction does
+	// not require consent for storage and access.
+	// 0 Purpose 1 was disclosed commonly as consent, as expected by the Policies.
 	PurposeOneTreatment bool
 	// The country code of the country that determines legislation of reference. Commonly,
 	// this corresponds to the country in which the publisher’s business entity is established.
+	// It is decoded from two 6 bit letters, where 0 is 'A', into an ISO 3166-1 alpha-2 code,
+	// e.g. "FR".
 	PublisherCC string
 
 	// The maximum Vendor ID that is represented in the following bit field or range encoding.
@@ -172,6 +186,10 @@ type PublisherTCEntry struct {
 	CustomPurposesLITransparency map[int]bool
 }
 
+// NumPurposes is the number of Purposes encoded in the PurposesConsent and PurposesLITransparency
+// fields of a TC String. Purposes that are not yet defined in the Global Vendor List are reserved.
+const NumPurposes = 24
+
 // SegmentType is an enum type of possible Out-of-Band (OOB) legal bases.
 type SegmentType int
 
@@ -278,6 +296,35 @@ func (p *V2ParsedConsent) EveryPurposeAllowed(ps []int) bool {
 	return true
 }
 
+// UseNonStandardTexts returns the value of the UseNonStandardStacks field, which was renamed
+// to UseNonStandardTexts in TCF v2.2, when it was extended to cover all customized texts,
+// rather than only Stack descriptions.
+func (p *V2ParsedConsent) UseNonStandardTexts() bool {
+	return p.UseNonStandardStacks
+}
+
+// PurposeConsents returns the user's consent value for every Purpose, keyed by Purpose
+// number from 1 to NumPurposes. Unlike PurposesConsent, Purposes without consent are
+// included with a false value.
+func (p *V2ParsedConsent) PurposeConsents() map[int]bool {
+	return purposeVector(p.PurposesConsent)
+}
+
+// PurposeLegitimateInterests returns whether legitimate interest is established for every
+// Purpose, keyed by Purpose number from 1 to NumPurposes. Unlike PurposesLITransparency,
+// Purposes without legitimate interest are included with a false value.
+func (p *V2ParsedConsent) PurposeLegitimateInterests() map[int]bool {
+	return purposeVector(p.PurposesLITransparency)
+}
+
+func purposeVector(m map[int]bool) map[int]bool {
+	var v = make(map[int]bool, NumPurposes)
+	for i := 1; i <= NumPurposes; i++ {
+		v[i] = m[i]
+	}
+	return v
+}
+
 // PurposeAllowed returns true if the passed purpose number exists in
 // the V2ParsedConsent, otherwise false.
 func (p *V2ParsedConsent) PurposeAllowed(ps int) bool {
@@ -287,6 +334,14 @@ func (p *V2ParsedConsent) PurposeAllowed(ps int) bool {
 	return true
 }
 
+// SpecialFeatureOptIn returns true if the user has opted in to Special Feature |sf|,
+// e.g. UsePreciseGeolocation. Special Purposes and Features are not included, as the
+// TC String does not encode them; users can not opt out of Special Purposes, and Features
+// are disclosed by vendors in the Global Vendor List.
+func (p *V2ParsedConsent) SpecialFeatureOptIn(sf SpecialFeature) bool {
+	return p.SpecialFeaturesOptIn[int(sf)]
+}
+
 // VendorAllowed returns true if the ParsedConsent contains affirmative consent
 // for VendorID |v|.
 func (p *V2ParsedConsent) VendorAllowed(v int) bool {
@@ -297,6 +352,32 @@ func (p *V2ParsedConsent) VendorAllowed(v int) bool {
 	return p.ConsentedVendors[v]
 }
 
+// AllowedVendor returns true if the AllowedVendors segment is present and allows VendorID |v|
+// to use out-of-band legal bases.
+func (p *V2ParsedConsent) AllowedVendor(v int) bool {
+	var l = p.OOBAllowedVendors
+	if l == nil {
+		return false
+	}
+	if l.IsRangeEncoding {
+		return inRangeEntries(v, l.VendorEntries)
+	}
+	return l.Vendors[v]
+}
+
+// AllowedVendorCount returns the number of vendors the AllowedVendors segment allows to use
+// out-of-band legal bases, or 0 if the segment is not present.
+func (p *V2ParsedConsent) AllowedVendorCount() int {
+	var l = p.OOBAllowedVendors
+	if l == nil {
+		return 0
+	}
+	if l.IsRangeEncoding {
+		return vendorSetFromRangeEntries(l.VendorEntries).Len()
+	}
+	return vendorSetFromBitField(l.Vendors).Len()
+}
+
 // PublisherRestricted returns true if any purpose in |ps| is
 // Flatly Not Allowed and |v| is covered by that restriction.
 func (p *V2ParsedConsent) PublisherRestricted(ps []int, v int) bool {
@@ -319,6 +400,94 @@ func (p *V2ParsedConsent) PublisherRestricted(ps []int, v int) bool {
 	return false
 }
 
+// RestrictedPurposes returns the Vendor IDs, in ascending order, that the publisher has
+// restricted to PurposeFlatlyNotAllowed, keyed by Purpose ID. These vendors may not process data
+// for the purpose, regardless of consent. Purposes without such vendors are not included.
+func (p *V2ParsedConsent) RestrictedPurposes() map[int][]int {
+	var ranges = make(map[int][]*RangeEntry)
+	for _, re := range p.PubRestrictionEntries {
+		if re.RestrictionType == PurposeFlatlyNotAllowed {
+			ranges[re.PurposeID] = append(ranges[re.PurposeID], re.RestrictionsRange...)
+		}
+	}
+	var restricted = make(map[int][]int, len(ranges))
+	for ps, entries := range ranges {
+		if ids := rangeEntryIDs(entries); len(ids) > 0 {
+			restricted[ps] = ids
+		}
+	}
+	return restricted
+}
+
+// rangeEntryIDs returns the unique Vendor IDs within entries, in ascending order. Overlapping
+// entries are merged before they are expanded, so that the work is bounded by the largest
+// Vendor ID, rather than by the number of entries.
+func rangeEntryIDs(entries []*RangeEntry) []int {
+	var sorted = append([]*RangeEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].StartVendorID < sorted[j].StartVendorID })
+	var ids []int
+	for _, re := range sorted {
+		var start = re.StartVendorID
+		if len(ids) > 0 && start <= ids[len(ids)-1] {
+			start = ids[len(ids)-1] + 1
+		}
+		for id := start; id <= re.EndVendorID; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// VendorPurposeAllowed returns true if vendor |v| may process data for purpose |ps|,
+// combining purpose and vendor signals with any Publisher Restriction for the pair:
+//   - PurposeFlatlyNotAllowed: never allowed.
+//   - RequireConsent: allowed only on the legal basis of consent.
+//   - RequireLegitimateInterest: allowed only on the legal basis of legitimate interest.
+//   - No restriction: allowed on the legal basis of either consent or legitimate interest.
+//
+// A legal basis is established when both the purpose and the vendor have that signal set.
+// Purpose 1 can only be processed on the legal basis of consent, per the TCF Policies.
+func (p *V2ParsedConsent) VendorPurposeAllowed(ps, v int) bool {
+	var consent = p.PurposesConsent[ps] && p.VendorAllowed(v)
+	var interests = ps != 1 && p.PurposesLITransparency[ps] && p.vendorInterestsAllowed(v)
+
+	var restricted, requireConsent, requireInterests bool
+	for _, re := range p.PubRestrictionEntries {
+		if re.PurposeID != ps || !inRangeEntries(v, re.RestrictionsRange) {
+			continue
+		}
+		switch re.RestrictionType {
+		case PurposeFlatlyNotAllowed:
+			restricted = true
+		case RequireConsent:
+			requireConsent = true
+		case RequireLegitimateInterest:
+			requireInterests = true
+		}
+	}
+
+	switch {
+	case restricted:
+		return false
+	case requireConsent:
+		return consent
+	case requireInterests:
+		return interests
+	default:
+		return consent || interests
+	}
+}
+
+// vendorInterestsAllowed returns true if the ParsedConsent has established transparency
+// for the legitimate interest of VendorID |v|.
+func (p *V2ParsedConsent) vendorInterestsAllowed(v int) bool {
+	if p.IsInterestsRangeEncoding {
+		return inRangeEntries(v, p.InterestsVendorsRange)
+	}
+
+	return p.InterestsVendors[v]
+}
+
 // inRangeEntries returns whether |v| is found within |entries|.
 func inRangeEntries(v int, entries []*RangeEntry) bool {
 	for _, re := range entries {
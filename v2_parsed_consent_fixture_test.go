@@ -508,6 +508,35 @@ var v2ConsentFixtures = map[string]*iabconsent.V2ParsedConsent{
 		},
 		PublisherTCEntry: nil,
 	},
+	// Bit field encodings of both vendor sections, with a different MaxVendorID each, so that
+	// decoding the legitimate interests bit field with the consent MaxVendorID would misread
+	// it and the publisher restrictions that follow. Hand encoded.
+	"COvzTO5OvzTO5AKABBENAUCgAMAAAEAAAAYgAFCBAARIAAA": {
+		Version:                  2,
+		Created:                  v2TestTime,
+		LastUpdated:              v2TestTime,
+		CMPID:                    10,
+		CMPVersion:               1,
+		ConsentScreen:            1,
+		ConsentLanguage:          "EN",
+		VendorListVersion:        20,
+		TCFPolicyVersion:         2,
+		IsServiceSpecific:        true,
+		UseNonStandardStacks:     false,
+		SpecialFeaturesOptIn:     map[int]bool{},
+		PurposesConsent:          map[int]bool{1: true, 2: true},
+		PurposesLITransparency:   map[int]bool{2: true},
+		PurposeOneTreatment:      false,
+		PublisherCC:              "DE",
+		MaxConsentVendorID:       10,
+		IsConsentRangeEncoding:   false,
+		ConsentedVendors:         map[int]bool{3: true, 10: true},
+		MaxInterestsVendorID:     4,
+		IsInterestsRangeEncoding: false,
+		InterestsVendors:         map[int]bool{1: true, 4: true},
+		NumPubRestrictions:       0,
+		PubRestrictionEntries:    make([]*iabconsent.PubRestrictionEntry, 0),
+	},
 }
 
 var v2InvalidConsentFixtures = map[string]string{
@@ -523,4 +552,10 @@ var v2InvalidConsentFixtures = map[string]string{
 	"COvwooAOvwooAB7ABCENAPEYAIAAAAQAAIqIAAoAAoAA.QAAo.IAAo": "TCF String Version 2.2 or higher has invalid PurposesLIT 6 not set to 0.",
 	// Policy Version 5, still checking for LIT 6.
 	"COvwooAOvwooAB7ABCENAPFYAIAAAAQAAIqIAAoAAoAA.QAAo.IAAo": "TCF String Version 2.2 or higher has invalid PurposesLIT 6 not set to 0.",
+	// MaxConsentVendorID 10, with a consent range of 1-65535.
+	"COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAYAA__-AAAAA": "vendor range out of bounds",
+	// MaxConsentVendorID 10, with a consent for vendor 11.
+	"COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAQAFgAAAA": "vendor range out of bounds",
+	// MaxConsentVendorID 10, with a consent range of 5-3.
+	"COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAYACgAGAAAAA": "vendor range out of bounds",
 }
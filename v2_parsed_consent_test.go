@@ -1,6 +1,8 @@
 package iabconsent_test
 
 import (
+	"encoding/base64"
+
 	"github.com/go-check/check"
 
 	"github.com/openx/iabconsent"
@@ -19,6 +21,140 @@ func (v *V2ParsedConsentSuite) TestParseV2(c *check.C) {
 	}
 }
 
+func (v *V2ParsedConsentSuite) TestParseV2Standalone(c *check.C) {
+	// A TC string that is not wrapped in a GPP string, with only the core segment, and with the
+	// disclosed vendors segment.
+	var core = "COvzTO5OvzTO5B7ABCENAPCYAKdAADkAAIqIFhwBAAGAAXAFGAsMAhYAgAMAAegBYAEKAAA"
+	var disclosed = "IFoEUQQgAIQwgIwQABAEAAAAOIAACAIAAAAQAIAgEAACEAAAAAgAQBAAAAAAAGBAAgAAAAAAAFAAECAAAgAAQARAEQAAAAAJAAIAAgAAAYQEAAAQmAgBC3ZAYzUw"
+	for _, s := range []string{core, core + "." + disclosed} {
+		c.Log(s)
+
+		c.Check(iabconsent.DetectFormat(s), check.Equals, iabconsent.FormatTCFv2Raw)
+		var p, err = iabconsent.ParseV2(s)
+		c.Assert(err, check.IsNil)
+		c.Check(p.PurposeAllowed(1), check.Equals, true)
+		c.Check(p.PurposeAllowed(2), check.Equals, false)
+		c.Check(p.EveryPurposeAllowed([]int{1, 3, 6}), check.Equals, true)
+		c.Check(p.VendorAllowed(12), check.Equals, true)
+		c.Check(p.VendorAllowed(13), check.Equals, false)
+		c.Check(p.VendorPurposeAllowed(1, 12), check.Equals, true)
+		c.Check(p.ConsentedVendorSet().Slice(), check.DeepEquals, []int{12, 23, 163, 707})
+		c.Check(p.OOBAllowedVendors, check.IsNil)
+		c.Check(p.PublisherTCEntry, check.IsNil)
+		if s == core {
+			c.Check(p.OOBDisclosedVendors, check.IsNil)
+		} else {
+			c.Assert(p.OOBDisclosedVendors, check.NotNil)
+			c.Check(p.OOBDisclosedVendors.SegmentType, check.Equals, iabconsent.DisclosedVendors)
+			c.Check(p.OOBDisclosedVendors.MaxVendorID, check.Equals, 720)
+			c.Check(p.OOBDisclosedVendors.Vendors[12], check.Equals, true)
+			c.Check(p.OOBDisclosedVendors.Vendors[13], check.Equals, false)
+		}
+	}
+}
+
+func (v *V2ParsedConsentSuite) TestAllowedVendors(c *check.C) {
+	var core = "COvzTO5OvzTO5B7ABCENAPCYAKdAADkAAIqIFhwBAAGAAXAFGAsMAhYAgAMAAegBYAEKAAA"
+	var tcs = []struct {
+		desc        string
+		tc          string
+		expected    []int
+		notExpected []int
+	}{
+		{
+			desc: "No AllowedVendors segment.",
+			tc:   core,
+		},
+		{
+			desc:        "Bit field.",
+			tc:          "COvzTO5OvzTO5B7ABCENAPEYAIAAAIAAAIqIAAoAAoAA.QAAo.IAAo",
+			expected:    []int{1},
+			notExpected: []int{0, 2},
+		},
+		{
+			desc:        "Range entries of single vendors.",
+			tc:          "COvzTO5OvzTO5B7ABCENAPCYAKdAADkAAIqIFhwBAAGAAXAFGAsMAhYAgAMAAegBYAEKAAA.IFoEUQQgAIQwgIwQABAEAAAAOIAACAIAAAAQAIAgEAACEAAAAAgAQBAAAAAAAGBAAgAAAAAAAFAAECAAAgAAQARAEQAAAAAJAAIAAgAAAYQEAAAQmAgBC3ZAYzUw.QE5QAwCvgHyATkA",
+			expected:    []int{351, 498, 626},
+			notExpected: []int{12, 350, 627},
+		},
+		{
+			desc:        "Range entries of vendors 10 to 20, and 35.",
+			tc:          core + ".QARwAoAFAAoACMA",
+			expected:    []int{10, 15, 20, 35},
+			notExpected: []int{9, 21, 34, 36},
+		},
+	}
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var p, err = iabconsent.ParseV2(tc.tc)
+		c.Assert(err, check.IsNil)
+		for _, id := range tc.expected {
+			c.Check(p.AllowedVendor(id), check.Equals, true, check.Commentf("vendor %d", id))
+		}
+		for _, id := range tc.notExpected {
+			c.Check(p.AllowedVendor(id), check.Equals, false, check.Commentf("vendor %d", id))
+		}
+	}
+
+	var counts = map[string]int{tcs[0].tc: 0, tcs[1].tc: 1, tcs[2].tc: 3, tcs[3].tc: 12}
+	for tc, expected := range counts {
+		var p, err = iabconsent.ParseV2(tc)
+		c.Assert(err, check.IsNil)
+		c.Check(p.AllowedVendorCount(), check.Equals, expected)
+	}
+}
+
+func (v *V2ParsedConsentSuite) TestParseV2WithAssumedMaxVendorID(c *check.C) {
+	// A range encoded TC string with consent for vendors 12, 23, 163 and 707, truncated after
+	// the first two range entries.
+	var full = "COvzTO5OvzTO5B7ABCENAPCYAKdAADkAAIqIFhwBAAGAAXAFGAsMAhYAgAMAAegBYAEKAAA"
+	var truncated = full[:48]
+	var _, err = iabconsent.ParseV2(truncated)
+	c.Check(err, check.NotNil)
+
+	var p *iabconsent.V2ParsedConsent
+	p, err = iabconsent.ParseV2WithOptions(truncated, iabconsent.WithAssumedMaxVendorID(1000))
+	c.Assert(err, check.IsNil)
+	c.Check(p.EveryPurposeAllowed([]int{1, 3, 6}), check.Equals, true)
+	c.Check(p.PurposeAllowed(2), check.Equals, false)
+	c.Check(p.ConsentedVendorSet().Slice(), check.DeepEquals, []int{12, 23})
+	c.Check(p.VendorAllowed(163), check.Equals, false)
+	c.Check(p.VendorAllowed(707), check.Equals, false)
+	c.Check(p.InterestsVendorSet().Len(), check.Equals, 0)
+
+	// Vendors past the assumed MaxVendorID are not read from a string that is not truncated.
+	p, err = iabconsent.ParseV2WithOptions(full, iabconsent.WithAssumedMaxVendorID(100))
+	c.Assert(err, check.IsNil)
+	c.Check(p.MaxConsentVendorID, check.Equals, 100)
+	c.Check(p.ConsentedVendorSet().Slice(), check.DeepEquals, []int{12, 23})
+	c.Check(p.MaxInterestsVendorID, check.Equals, 100)
+
+	// A bit field encoded TC string with consent for 720 vendors, truncated after vendor 106.
+	var bitField = "COvzTO5OvzTO5BZAFMENAPCgAAAAAAAAAAwIFoEUQQgAIQwgIwQABAEAAAAOIAACAIAAAAQAIAgEAACEAAAAAgAQBAAAAAAAGBAAgAAAAAAAFAAECAAAgAAQARAEQAAAAAJAAIAAgAAAYQEAAAQmAgBC3ZAYzUwLQIoghAAQhhARggACAIAAAAcQAAEAQAAAAgAQBAIAAEIAAAABAAgCAAAAAAAMCABAAAAAAAAKAAIEAABAAAgAiAIgAAAAASAAQABAAAAwgIAAAhMBACFuyAxmpgAA"
+	_, err = iabconsent.ParseV2(bitField[:56])
+	c.Check(err, check.NotNil)
+	p, err = iabconsent.ParseV2WithOptions(bitField[:56], iabconsent.WithAssumedMaxVendorID(1000))
+	c.Assert(err, check.IsNil)
+	c.Check(p.PublisherCC, check.Equals, "GB")
+	c.Check(p.ConsentedVendorSet().Slice(), check.DeepEquals, []int{2, 6, 8, 12, 18, 23, 37, 42, 47, 48, 53, 61, 65, 66, 72, 88, 98})
+	c.Check(p.VendorAllowed(127), check.Equals, false)
+
+	// Truncated core fields are not tolerated.
+	_, err = iabconsent.ParseV2WithOptions(full[:20], iabconsent.WithAssumedMaxVendorID(1000))
+	c.Check(err, check.NotNil)
+
+	// Strings that are not truncated, with no vendors past the assumed MaxVendorID, parse as with
+	// ParseV2.
+	for k, f := range v2ConsentFixtures {
+		c.Log(k)
+
+		p, err = iabconsent.ParseV2WithOptions(k, iabconsent.WithAssumedMaxVendorID(65535))
+		c.Assert(err, check.IsNil)
+		c.Check(p, check.DeepEquals, f)
+	}
+}
+
 func (v *V2ParsedConsentSuite) TestParseV2Error(c *check.C) {
 	for k, v := range v2InvalidConsentFixtures {
 		c.Log(k)
@@ -29,6 +165,52 @@ func (v *V2ParsedConsentSuite) TestParseV2Error(c *check.C) {
 	}
 }
 
+func (v *V2ParsedConsentSuite) TestVendorRangeOutOfBounds(c *check.C) {
+	// MaxConsentVendorID 10, with a consent range of 1-3, and a consent for vendor 7.
+	var p, err = iabconsent.ParseV2("COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAoAAgAGAAcAAAAAA")
+	c.Assert(err, check.IsNil)
+	c.Check(p.MaxConsentVendorID, check.Equals, 10)
+	c.Check(p.ConsentedVendorsRange, check.DeepEquals, []*iabconsent.RangeEntry{
+		{StartVendorID: 1, EndVendorID: 3},
+		{StartVendorID: 7, EndVendorID: 7},
+	})
+
+	// The largest possible range end does not expand into the vendors of the range.
+	_, err = iabconsent.ParseV2("COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAYAA__-AAAAA")
+	c.Check(err, check.Equals, iabconsent.ErrVendorRangeOutOfBounds)
+
+	// Publisher restrictions do not declare a max Vendor ID, but their ranges must not end
+	// before they start.
+	for _, end := range []int{5, 3} {
+		c.Log(end)
+
+		var w = iabconsent.NewConsentWriter()
+		w.WriteInt(2, 6)
+		w.WritePadding(6 + 36 + 36 + 12 + 12 + 6 + 12 + 12 + 6 + 1 + 1 + 12 + 24 + 24 + 1 + 12)
+		// No consented or legitimate interest vendors.
+		w.WritePadding(w.Size() + 2*(16+1))
+		// One restriction of purpose 1, with a range of vendors 4 to end.
+		w.WriteInt(1, 12)
+		w.WriteInt(1, 6)
+		w.WriteInt(1, 2)
+		w.WriteInt(1, 12)
+		w.WriteBool(true)
+		w.WriteInt(4, 16)
+		w.WriteInt(end, 16)
+		c.Assert(w.Err, check.IsNil)
+
+		var p, err = iabconsent.ParseV2(base64.RawURLEncoding.EncodeToString(w.Bytes()))
+		if end < 4 {
+			c.Check(err, check.Equals, iabconsent.ErrVendorRangeOutOfBounds)
+			continue
+		}
+		c.Assert(err, check.IsNil)
+		c.Check(p.PubRestrictionEntries[0].RestrictionsRange, check.DeepEquals, []*iabconsent.RangeEntry{
+			{StartVendorID: 4, EndVendorID: end},
+		})
+	}
+}
+
 func (v *V2ParsedConsentSuite) TestNonV2Input(c *check.C) {
 	var _, err = iabconsent.ParseV2("BONMj34ONMj34ABACDENALqAAAAAplY") // V1 string.
 	c.Check(err, check.ErrorMatches, "non-v2 string passed to v2 parse method")
@@ -421,6 +603,231 @@ func (v *V2ParsedConsentSuite) TestSuitableToProcess(c *check.C) {
 	}
 }
 
+func (v *V2ParsedConsentSuite) TestRestrictedPurposes(c *check.C) {
+	var pc = &iabconsent.V2ParsedConsent{
+		NumPubRestrictions: 4,
+		PubRestrictionEntries: []*iabconsent.PubRestrictionEntry{
+			{
+				PurposeID:       2,
+				RestrictionType: iabconsent.PurposeFlatlyNotAllowed,
+				NumEntries:      3,
+				RestrictionsRange: []*iabconsent.RangeEntry{
+					{StartVendorID: 5, EndVendorID: 7},
+					{StartVendorID: 6, EndVendorID: 9},
+					{StartVendorID: 1, EndVendorID: 1},
+				},
+			},
+			{
+				PurposeID:       3,
+				RestrictionType: iabconsent.RequireConsent,
+				NumEntries:      1,
+				RestrictionsRange: []*iabconsent.RangeEntry{
+					{StartVendorID: 5, EndVendorID: 5},
+				},
+			},
+			{
+				PurposeID:       2,
+				RestrictionType: iabconsent.PurposeFlatlyNotAllowed,
+				NumEntries:      1,
+				RestrictionsRange: []*iabconsent.RangeEntry{
+					{StartVendorID: 20, EndVendorID: 20},
+				},
+			},
+			{
+				PurposeID:         4,
+				RestrictionType:   iabconsent.PurposeFlatlyNotAllowed,
+				RestrictionsRange: []*iabconsent.RangeEntry{},
+			},
+		},
+	}
+	c.Check(pc.RestrictedPurposes(), check.DeepEquals, map[int][]int{2: {1, 5, 6, 7, 8, 9, 20}})
+	for ps, vendors := range pc.RestrictedPurposes() {
+		for _, v := range vendors {
+			c.Check(pc.PublisherRestricted([]int{ps}, v), check.Equals, true)
+		}
+	}
+
+	// The purpose 2 restriction of the fixture has no vendors.
+	var p, err = iabconsent.ParseV2("COvzTO5OvzTO5BRAAAENAPCoALIAADgAAAAAAewAwABAAlAB6ABBFADBQAQA9hAAAcAA")
+	c.Assert(err, check.IsNil)
+	c.Check(p.RestrictedPurposes(), check.DeepEquals, map[int][]int{})
+	c.Check((&iabconsent.V2ParsedConsent{}).RestrictedPurposes(), check.DeepEquals, map[int][]int{})
+}
+
+func (v *V2ParsedConsentSuite) TestVendorPurposeAllowed(c *check.C) {
+	var restriction = func(ps int, rt iabconsent.RestrictionType) *iabconsent.PubRestrictionEntry {
+		return &iabconsent.PubRestrictionEntry{
+			PurposeID:         ps,
+			RestrictionType:   rt,
+			NumEntries:        1,
+			RestrictionsRange: []*iabconsent.RangeEntry{{StartVendorID: 100, EndVendorID: 200}},
+		}
+	}
+	var tcs = []struct {
+		desc         string
+		purpose      int
+		vendor       int
+		restrictions []*iabconsent.PubRestrictionEntry
+		exp          bool
+	}{
+		{
+			desc:    "Consent basis without restrictions.",
+			purpose: 2,
+			vendor:  123,
+			exp:     true,
+		},
+		{
+			desc:    "Legitimate interest basis without restrictions.",
+			purpose: 7,
+			vendor:  5,
+			exp:     true,
+		},
+		{
+			desc:    "No legal basis for vendor.",
+			purpose: 2,
+			vendor:  5,
+			exp:     false,
+		},
+		{
+			desc:    "No legal basis for purpose.",
+			purpose: 3,
+			vendor:  123,
+			exp:     false,
+		},
+		{
+			desc:    "Purpose 1 can not use legitimate interest.",
+			purpose: 1,
+			vendor:  5,
+			exp:     false,
+		},
+		{
+			desc:         "Purpose flatly not allowed.",
+			purpose:      2,
+			vendor:       123,
+			restrictions: []*iabconsent.PubRestrictionEntry{restriction(2, iabconsent.PurposeFlatlyNotAllowed)},
+			exp:          false,
+		},
+		{
+			desc:         "Purpose flatly not allowed, vendor not in range.",
+			purpose:      7,
+			vendor:       5,
+			restrictions: []*iabconsent.PubRestrictionEntry{restriction(7, iabconsent.PurposeFlatlyNotAllowed)},
+			exp:          true,
+		},
+		{
+			desc:         "Require consent with consent.",
+			purpose:      2,
+			vendor:       123,
+			restrictions: []*iabconsent.PubRestrictionEntry{restriction(2, iabconsent.RequireConsent)},
+			exp:          true,
+		},
+		{
+			desc:         "Require consent with only legitimate interest.",
+			purpose:      7,
+			vendor:       150,
+			restrictions: []*iabconsent.PubRestrictionEntry{restriction(7, iabconsent.RequireConsent)},
+			exp:          false,
+		},
+		{
+			desc:         "Require legitimate interest with legitimate interest.",
+			purpose:      7,
+			vendor:       150,
+			restrictions: []*iabconsent.PubRestrictionEntry{restriction(7, iabconsent.RequireLegitimateInterest)},
+			exp:          true,
+		},
+		{
+			desc:         "Require legitimate interest with only consent.",
+			purpose:      2,
+			vendor:       123,
+			restrictions: []*iabconsent.PubRestrictionEntry{restriction(2, iabconsent.RequireLegitimateInterest)},
+			exp:          false,
+		},
+		{
+			desc:    "Flatly not allowed takes precedence.",
+			purpose: 2,
+			vendor:  123,
+			restrictions: []*iabconsent.PubRestrictionEntry{
+				restriction(2, iabconsent.RequireConsent),
+				restriction(2, iabconsent.PurposeFlatlyNotAllowed),
+			},
+			exp: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		c.Log(tc.desc)
+
+		var pc = &iabconsent.V2ParsedConsent{
+			PurposesConsent:        map[int]bool{1: true, 2: true},
+			PurposesLITransparency: map[int]bool{1: true, 7: true},
+			IsConsentRangeEncoding: true,
+			ConsentedVendorsRange:  []*iabconsent.RangeEntry{{StartVendorID: 100, EndVendorID: 200}},
+			InterestsVendors:       map[int]bool{5: true, 150: true},
+			NumPubRestrictions:     len(tc.restrictions),
+			PubRestrictionEntries:  tc.restrictions,
+		}
+
+		c.Check(pc.VendorPurposeAllowed(tc.purpose, tc.vendor), check.Equals, tc.exp)
+	}
+}
+
+func (v *V2ParsedConsentSuite) TestSpecialFeatureOptIn(c *check.C) {
+	var tcs = []struct {
+		consentString   string
+		geolocation     bool
+		activelyScanned bool
+	}{
+		{
+			consentString:   "COvzTO5OvzTO5B7ABCENAPCYAKdAADkAAIqIFhwBAAGAAXAFGAsMAhYAgAMAAegBYAEKAAA.IFoEUQQgAIQwgIwQABAEAAAAOIAACAIAAAAQAIAgEAACEAAAAAgAQBAAAAAAAGBAAgAAAAAAAFAAECAAAgAAQARAEQAAAAAJAAIAAgAAAYQEAAAQmAgBC3ZAYzUw.QE5QAwCvgHyATkA",
+			geolocation:     true,
+			activelyScanned: false,
+		},
+		{
+			consentString:   "CPu5aAAPu5aAAPoABABGCyAAAAAAAAAAAAAAAAAAAAAA.QAAA.IAAA",
+			geolocation:     false,
+			activelyScanned: false,
+		},
+	}
+
+	for _, tc := range tcs {
+		c.Log(tc.consentString)
+
+		var parsed, err = iabconsent.ParseV2(tc.consentString)
+		c.Check(err, check.IsNil)
+		c.Check(parsed.SpecialFeatureOptIn(iabconsent.UsePreciseGeolocation), check.Equals, tc.geolocation)
+		c.Check(parsed.SpecialFeatureOptIn(iabconsent.ActivelyScanDevice), check.Equals, tc.activelyScanned)
+	}
+}
+
+func (v *V2ParsedConsentSuite) TestPurposeVectors(c *check.C) {
+	for k, e := range v2ConsentFixtures {
+		c.Log(k)
+
+		var parsed, err = iabconsent.ParseV2(k)
+		c.Assert(err, check.IsNil)
+
+		var consents = parsed.PurposeConsents()
+		var interests = parsed.PurposeLegitimateInterests()
+		c.Check(consents, check.HasLen, iabconsent.NumPurposes)
+		c.Check(interests, check.HasLen, iabconsent.NumPurposes)
+		for i := 1; i <= iabconsent.NumPurposes; i++ {
+			c.Check(consents[i], check.Equals, e.PurposesConsent[i])
+			c.Check(interests[i], check.Equals, e.PurposesLITransparency[i])
+		}
+	}
+}
+
+func (v *V2ParsedConsentSuite) TestServiceSpecificAndNonStandardTexts(c *check.C) {
+	for k, e := range v2ConsentFixtures {
+		c.Log(k)
+
+		var parsed, err = iabconsent.ParseV2(k)
+		c.Assert(err, check.IsNil)
+		c.Check(parsed.IsServiceSpecific, check.Equals, e.IsServiceSpecific)
+		c.Check(parsed.UseNonStandardTexts(), check.Equals, e.UseNonStandardStacks)
+	}
+}
+
 func (v *V2ParsedConsentSuite) TestMinorVersion(c *check.C) {
 	var tcs = []struct {
 		desc          string
@@ -482,4 +889,37 @@ func (v *V2ParsedConsentSuite) TestMinorVersion(c *check.C) {
 	}
 }
 
+func (v *V2ParsedConsentSuite) TestVersionFields(c *check.C) {
+	var tcs = []struct {
+		consentString     string
+		vendorListVersion int
+		tcfPolicyVersion  int
+		minorVersion      int
+	}{
+		{
+			consentString:     "CPu5aAAPu5aAAPoABABGCyAAAAAAAAAAAAAAAAAAAAAA.QAAA.IAAA",
+			vendorListVersion: 178,
+			tcfPolicyVersion:  0,
+			minorVersion:      0,
+		},
+		{
+			consentString:     "CPuy0IAPuy0IAPoABABGCyEAAAAAAAAAAAAAAAAAAAAA.QAAA.IAAA",
+			vendorListVersion: 178,
+			tcfPolicyVersion:  4,
+			minorVersion:      2,
+		},
+	}
+
+	for _, tc := range tcs {
+		c.Log(tc.consentString)
+
+		var parsed, err = iabconsent.ParseV2(tc.consentString)
+		c.Check(err, check.IsNil)
+		c.Check(parsed.VendorListVersion, check.Equals, tc.vendorListVersion)
+		c.Check(parsed.TCFPolicyVersion, check.Equals, tc.tcfPolicyVersion)
+		var mv, _ = parsed.MinorVersion()
+		c.Check(mv, check.Equals, tc.minorVersion)
+	}
+}
+
 var _ = check.Suite(&V2ParsedConsentSuite{})
@@ -0,0 +1,137 @@
+package iabconsent
+
+import (
+	"sort"
+)
+
+// VendorSet is an immutable set of Vendor IDs, e.g. the vendors with consent in a TC string,
+// which supports set operations for comparing the vendors of multiple strings. The zero value
+// is an empty set.
+type VendorSet struct {
+	// ids are the sorted, unique Vendor IDs in the set.
+	ids []int
+}
+
+// NewVendorSet returns a VendorSet of the given Vendor IDs. Duplicate IDs are ignored.
+func NewVendorSet(ids ...int) VendorSet {
+	var sorted = append([]int(nil), ids...)
+	sort.Ints(sorted)
+	var unique = sorted[:0]
+	for i, id := range sorted {
+		if i == 0 || id != sorted[i-1] {
+			unique = append(unique, id)
+		}
+	}
+	return VendorSet{ids: unique}
+}
+
+// vendorSetFromBitField returns a VendorSet of the keys of m that are true.
+func vendorSetFromBitField(m map[int]bool) VendorSet {
+	var ids = make([]int, 0, len(m))
+	for id, ok := range m {
+		if ok {
+			ids = append(ids, id)
+		}
+	}
+	return NewVendorSet(ids...)
+}
+
+// vendorSetFromRangeEntries returns a VendorSet of every Vendor ID within entries.
+func vendorSetFromRangeEntries(entries []*RangeEntry) VendorSet {
+	var ids []int
+	for _, re := range entries {
+		for id := re.StartVendorID; id <= re.EndVendorID; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return NewVendorSet(ids...)
+}
+
+// Contains returns true if Vendor ID id is in the set.
+func (s VendorSet) Contains(id int) bool {
+	var i = sort.SearchInts(s.ids, id)
+	return i < len(s.ids) && s.ids[i] == id
+}
+
+// Len returns the number of Vendor IDs in the set.
+func (s VendorSet) Len() int {
+	return len(s.ids)
+}
+
+// Union returns a VendorSet of the Vendor IDs that are in either s or o.
+func (s VendorSet) Union(o VendorSet) VendorSet {
+	var ids = make([]int, 0, len(s.ids)+len(o.ids))
+	var i, j = 0, 0
+	for i < len(s.ids) && j < len(o.ids) {
+		switch {
+		case s.ids[i] < o.ids[j]:
+			ids = append(ids, s.ids[i])
+			i++
+		case s.ids[i] > o.ids[j]:
+			ids = append(ids, o.ids[j])
+			j++
+		default:
+			ids = append(ids, s.ids[i])
+			i++
+			j++
+		}
+	}
+	ids = append(ids, s.ids[i:]...)
+	return VendorSet{ids: append(ids, o.ids[j:]...)}
+}
+
+// Intersect returns a VendorSet of the Vendor IDs that are in both s and o.
+func (s VendorSet) Intersect(o VendorSet) VendorSet {
+	var ids []int
+	var i, j = 0, 0
+	for i < len(s.ids) && j < len(o.ids) {
+		switch {
+		case s.ids[i] < o.ids[j]:
+			i++
+		case s.ids[i] > o.ids[j]:
+			j++
+		default:
+			ids = append(ids, s.ids[i])
+			i++
+			j++
+		}
+	}
+	return VendorSet{ids: ids}
+}
+
+// Slice returns the Vendor IDs in the set in ascending order. The slice is a copy, and may be
+// modified.
+func (s VendorSet) Slice() []int {
+	return append([]int(nil), s.ids...)
+}
+
+// ConsentedVendorSet returns the vendors with consent, from either the bit field or range
+// encoding.
+func (p *V2ParsedConsent) ConsentedVendorSet() VendorSet {
+	if p.IsConsentRangeEncoding {
+		return vendorSetFromRangeEntries(p.ConsentedVendorsRange)
+	}
+	return vendorSetFromBitField(p.ConsentedVendors)
+}
+
+// InterestsVendorSet returns the vendors with established transparency for their legitimate
+// interest, from either the bit field or range encoding.
+func (p *V2ParsedConsent) InterestsVendorSet() VendorSet {
+	if p.IsInterestsRangeEncoding {
+		return vendorSetFromRangeEntries(p.InterestsVendorsRange)
+	}
+	return vendorSetFromBitField(p.InterestsVendors)
+}
+
+// ConsentedVendorSet returns the vendors with consent, from either the bit field or range
+// encoding. Range entries of v1.1 strings are exceptions to DefaultConsent, so the set is
+// built from every Vendor ID up to MaxVendorID.
+func (p *ParsedConsent) ConsentedVendorSet() VendorSet {
+	var ids []int
+	for id := 1; id <= p.MaxVendorID; id++ {
+		if p.VendorAllowed(id) {
+			ids = append(ids, id)
+		}
+	}
+	return VendorSet{ids: ids}
+}
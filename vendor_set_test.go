@@ -0,0 +1,89 @@
+package iabconsent_test
+
+import (
+	"github.com/go-check/check"
+
+	"github.com/openx/iabconsent"
+)
+
+type VendorSetSuite struct{}
+
+var _ = check.Suite(&VendorSetSuite{})
+
+func (s *VendorSetSuite) TestSetOperations(c *check.C) {
+	var a = iabconsent.NewVendorSet(7, 2, 5, 2)
+	var b = iabconsent.NewVendorSet(5, 9, 7)
+	var empty iabconsent.VendorSet
+
+	c.Check(a.Slice(), check.DeepEquals, []int{2, 5, 7})
+	c.Check(a.Len(), check.Equals, 3)
+	c.Check(a.Contains(5), check.Equals, true)
+	c.Check(a.Contains(9), check.Equals, false)
+
+	c.Check(a.Union(b).Slice(), check.DeepEquals, []int{2, 5, 7, 9})
+	c.Check(b.Union(a).Slice(), check.DeepEquals, []int{2, 5, 7, 9})
+	c.Check(a.Intersect(b).Slice(), check.DeepEquals, []int{5, 7})
+	c.Check(b.Intersect(a).Slice(), check.DeepEquals, []int{5, 7})
+
+	c.Check(a.Union(empty).Slice(), check.DeepEquals, a.Slice())
+	c.Check(a.Intersect(empty).Len(), check.Equals, 0)
+	c.Check(empty.Contains(0), check.Equals, false)
+	c.Check(empty.Slice(), check.HasLen, 0)
+
+	// Slice returns a copy.
+	var ids = a.Slice()
+	ids[0] = 3
+	c.Check(a.Contains(2), check.Equals, true)
+	c.Check(a.Contains(3), check.Equals, false)
+}
+
+func (s *VendorSetSuite) TestV2VendorSets(c *check.C) {
+	// Vendors 2 and 7, with range and bit field encodings.
+	var ranged, err = iabconsent.ParseV2("COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAgABAAHAAAAAA")
+	c.Assert(err, check.IsNil)
+	var bitField *iabconsent.V2ParsedConsent
+	bitField, err = iabconsent.ParseV2("COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAEEIAAAAAA")
+	c.Assert(err, check.IsNil)
+	c.Check(ranged.ConsentedVendorSet().Slice(), check.DeepEquals, []int{2, 7})
+	c.Check(bitField.ConsentedVendorSet().Slice(), check.DeepEquals, []int{2, 7})
+
+	// Vendors 2 and 8.
+	var other *iabconsent.V2ParsedConsent
+	other, err = iabconsent.ParseV2("COEB7cAOEB7cAAKABBENAyCAAOAAAAAAAAAAAFQAgABAAIAAAAAA")
+	c.Assert(err, check.IsNil)
+	c.Check(ranged.ConsentedVendorSet().Intersect(other.ConsentedVendorSet()).Slice(), check.DeepEquals, []int{2})
+	c.Check(ranged.ConsentedVendorSet().Union(other.ConsentedVendorSet()).Slice(), check.DeepEquals, []int{2, 7, 8})
+
+	for k, p := range v2ConsentFixtures {
+		c.Log(k)
+
+		var consented, interests = p.ConsentedVendorSet(), p.InterestsVendorSet()
+		for v := 1; v <= p.MaxConsentVendorID; v++ {
+			c.Check(consented.Contains(v), check.Equals, p.VendorAllowed(v))
+		}
+		for v := 1; v <= p.MaxInterestsVendorID; v++ {
+			c.Check(interests.Contains(v), check.Equals, p.InterestsVendors[v] || inRanges(v, p.InterestsVendorsRange))
+		}
+	}
+}
+
+func (s *VendorSetSuite) TestV1VendorSet(c *check.C) {
+	for k, p := range v1ConsentFixtures {
+		c.Log(k)
+
+		var consented = p.ConsentedVendorSet()
+		for v := 1; v <= p.MaxVendorID; v++ {
+			c.Check(consented.Contains(v), check.Equals, p.VendorAllowed(v))
+		}
+		c.Check(consented.Contains(p.MaxVendorID+1), check.Equals, false)
+	}
+}
+
+func inRanges(v int, entries []*iabconsent.RangeEntry) bool {
+	for _, re := range entries {
+		if re.StartVendorID <= v && v <= re.EndVendorID {
+			return true
+		}
+	}
+	return false
+}